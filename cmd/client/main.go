@@ -1,55 +1,203 @@
+// Command client drives the audio pipeline from the command line: it starts
+// AudioProcessingWorkflow runs and queries or cancels them, and exports the
+// features stored for an asset. See usage for the subcommands.
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 
 	"github.com/pphelan007/davidAI/internal/config"
+	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/storage"
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
 	"github.com/pphelan007/davidAI/internal/temporal/workflows"
+	"github.com/pphelan007/davidAI/internal/tracing"
 )
 
+// usage describes the subcommands; each one documents its own flags with -h
+const usage = `Usage: client <command> [flags] [args]
+
+Commands:
+  process <file>        process an audio file with AudioProcessingWorkflow ("-" reads stdin)
+  query <workflow-id>   print the progress of a running AudioProcessingWorkflow
+  cancel <workflow-id>  ask an AudioProcessingWorkflow to stop after its current step
+  export <asset-id>     write every feature of an asset as CSV or JSON
+
+process is the default, so "client <file>" processes the file.
+Run "client <command> -h" for the flags of a command.
+`
+
+// commands maps each subcommand to the function that runs it with the
+// arguments after its name
+var commands = map[string]func(args []string){
+	"process": runProcess,
+	"query":   runQuery,
+	"cancel":  runCancel,
+	"export":  runExport,
+}
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			fmt.Fprint(os.Stderr, usage)
+			return
+		}
+		if run, ok := commands[args[0]]; ok {
+			run(args[1:])
+			return
+		}
+	}
+	runProcess(args)
+}
+
+// newFlagSet returns the flag set of a subcommand, whose usage names the
+// subcommand and its positional argument
+func newFlagSet(name, argument string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: client %s [flags] %s\n", name, argument)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// requireArg returns the single positional argument of a subcommand, exiting
+// with its usage if there isn't exactly one
+func requireArg(flags *flag.FlagSet) string {
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return flags.Arg(0)
+}
+
+// runProcess starts an AudioProcessingWorkflow for a file and waits for it
+func runProcess(args []string) {
+	// The client config holds the defaults of the flags below
+	cfg, err := config.LoadClient()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Get file path from command line args or use default
-	filePath := "data/sine440.wav"
-	if len(os.Args) > 1 {
-		filePath = os.Args[1]
+	flags := newFlagSet("process", "[file]")
+	output := flags.String("output", "text", "result format: text (log lines) or json (single object on stdout)")
+	silenceThreshold := flags.Float64("silence-threshold", workflows.DefaultSilenceThreshold, "trim silence threshold (0.0-1.0 of full scale)")
+	silenceMode := flags.String("silence-mode", activities.ThresholdModeAbsolute,
+		"trim threshold mode: absolute, relative_peak (fraction of the file's peak) or noise_floor (just above the measured noise floor, ignores -silence-threshold)")
+	allowDuplicate := flags.Bool("allow-duplicate", false, "reprocess the file even if its content was already ingested")
+	datasetID := flags.String("dataset", "", "dataset to store the assets in; duplicates are only detected within a dataset")
+	minSilence := flags.Float64("min-silence", workflows.DefaultMinSilenceDuration, "minimum silence duration in seconds to trim")
+	trimFormat := flags.String("trim-format", activities.OutputFormatWAV, "trimmed file format: wav or flac (lossless, smaller)")
+	workflowIDPrefix := flags.String("workflow-id-prefix", cfg.WorkflowIDPrefix,
+		"prefix of the workflow ID (CLIENT_WORKFLOW_ID_PREFIX); resubmitted files only attach to a run started with the same prefix")
+	executionTimeout := flags.Duration("execution-timeout", cfg.ExecutionTimeout,
+		"time the workflow may run including retries before Temporal fails it (CLIENT_EXECUTION_TIMEOUT), 0 for no limit")
+	wait := flags.Bool("wait", cfg.Wait, "wait for the workflow to complete and print its result (CLIENT_WAIT); -wait=false only starts it")
+	timeout := flags.Duration("timeout", 0, "stop waiting for the result after this long and exit non-zero, leaving the workflow running; 0 waits forever")
+	cancelOnTimeout := flags.Bool("cancel-on-timeout", false, "ask the workflow to stop after its current step when -timeout passes")
+	if err = flags.Parse(args); err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
 	}
 
-	// Create Temporal client
-	temporalClient, err := client.Dial(client.Options{
-		HostPort:  cfg.Temporal.Address,
-		Namespace: cfg.Temporal.Namespace,
-	})
-	if err != nil {
-		log.Fatalf("Failed to create Temporal client: %v", err)
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Invalid -output %q: must be text or json", *output)
+	}
+	if *trimFormat != activities.OutputFormatWAV && *trimFormat != activities.OutputFormatFLAC {
+		log.Fatalf("Invalid -trim-format %q: must be wav or flac", *trimFormat)
+	}
+	switch *silenceMode {
+	case activities.ThresholdModeAbsolute, activities.ThresholdModeRelativePeak, activities.ThresholdModeNoiseFloor:
+	default:
+		log.Fatalf("Invalid -silence-mode %q: must be absolute, relative_peak or noise_floor", *silenceMode)
 	}
-	defer temporalClient.Close()
+	if *workflowIDPrefix == "" {
+		log.Fatalf("Invalid -workflow-id-prefix: must not be empty")
+	}
+	if *executionTimeout < 0 {
+		log.Fatalf("Invalid -execution-timeout %s: must not be negative", *executionTimeout)
+	}
+	if *timeout < 0 {
+		log.Fatalf("Invalid -timeout %s: must not be negative", *timeout)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Get file path from command line args or use the default file. A
+	// relative argument is taken from the working directory like any other
+	// command line path, and sent as an absolute path so the worker doesn't
+	// resolve it against its own data directory. "-" reads the audio from stdin.
+	filePath := cfg.DefaultFile
+	if flags.NArg() > 0 && flags.Arg(0) == "-" {
+		if filePath, err = saveStdin(cfg.UploadDir); err != nil {
+			log.Fatalf("Failed to read audio from stdin: %v", err)
+		}
+	} else if flags.NArg() > 0 {
+		filePath = flags.Arg(0)
+		if !storage.IsS3(filePath) {
+			if filePath, err = filepath.Abs(filePath); err != nil {
+				log.Fatalf("Invalid file path %q: %v", flags.Arg(0), err)
+			}
+		}
+	}
+
+	temporalClient, closeClient := dialTemporal(cfg)
+	defer closeClient()
 
 	// Prepare workflow input
 	workflowInput := workflows.AudioProcessingWorkflowInput{
-		FilePath: filePath,
+		FilePath:           filePath,
+		SilenceThreshold:   *silenceThreshold,
+		ThresholdMode:      *silenceMode,
+		MinSilenceDuration: *minSilence,
+		AllowDuplicate:     *allowDuplicate,
+		DatasetID:          *datasetID,
+		TrimOutputFormat:   *trimFormat,
+		ActivityOptions: &workflows.ActivityOptions{
+			StartToCloseTimeout: cfg.Activity.StartToCloseTimeout,
+			InitialInterval:     cfg.Activity.InitialInterval,
+			MaximumInterval:     cfg.Activity.MaximumInterval,
+			MaximumAttempts:     cfg.Activity.MaximumAttempts,
+		},
+	}
+
+	// Derive the workflow ID from the content so resubmitting the same file
+	// attaches to the existing run, falling back to a timestamp when the file
+	// can't be hashed locally (e.g. s3:// paths)
+	workflowID := fmt.Sprintf("%s-%d", *workflowIDPrefix, time.Now().Unix())
+	if !*allowDuplicate {
+		if contentHash, hashErr := activities.HashFile(filePath, cfg.HashAlgorithm); hashErr == nil {
+			workflowID = workflows.AudioProcessingWorkflowID(*workflowIDPrefix, *datasetID, contentHash)
+		}
 	}
 
 	// Start workflow execution
 	workflowOptions := client.StartWorkflowOptions{
-		ID:        fmt.Sprintf("audio-processing-%d", time.Now().Unix()),
-		TaskQueue: cfg.Temporal.TaskQueue,
+		ID:                       workflowID,
+		TaskQueue:                cfg.Temporal.TaskQueueFor(filePath),
+		WorkflowExecutionTimeout: *executionTimeout,
 	}
 
 	log.Printf("Starting AudioProcessingWorkflow with file: %s", filePath)
 	log.Printf("Workflow ID: %s", workflowOptions.ID)
-	log.Printf("Task Queue: %s", cfg.Temporal.TaskQueue)
+	log.Printf("Task Queue: %s", workflowOptions.TaskQueue)
 
 	workflowRun, err := temporalClient.ExecuteWorkflow(context.Background(), workflowOptions, workflows.AudioProcessingWorkflow, workflowInput)
 	if err != nil {
@@ -57,20 +205,253 @@ func main() {
 	}
 
 	log.Printf("Workflow started! Workflow ID: %s, Run ID: %s", workflowRun.GetID(), workflowRun.GetRunID())
+	if !*wait {
+		if *output == "json" {
+			printJSON(map[string]string{"workflow_id": workflowRun.GetID(), "run_id": workflowRun.GetRunID()})
+		}
+		return
+	}
 
-	// Wait for workflow to complete
+	// Wait for workflow to complete, giving up after the timeout if one is set
+	waitCtx := context.Background()
+	if *timeout > 0 {
+		var cancelWait context.CancelFunc
+		waitCtx, cancelWait = context.WithTimeout(waitCtx, *timeout)
+		defer cancelWait()
+	}
 	var result workflows.AudioProcessingWorkflowOutput
-	err = workflowRun.Get(context.Background(), &result)
+	err = workflowRun.Get(waitCtx, &result)
+	if err != nil && waitCtx.Err() != nil {
+		if *cancelOnTimeout {
+			signalErr := temporalClient.SignalWorkflow(context.Background(), workflowRun.GetID(), workflowRun.GetRunID(),
+				workflows.CancelSignalName, nil)
+			if signalErr != nil {
+				log.Fatalf("Workflow %s still running after %s, and cancelling it failed: %v", workflowRun.GetID(), *timeout, signalErr)
+			}
+			log.Fatalf("Workflow %s still running after %s, cancel requested; check it with: client query %s",
+				workflowRun.GetID(), *timeout, workflowRun.GetID())
+		}
+		log.Fatalf("Workflow %s still running after %s; check it with: client query %s",
+			workflowRun.GetID(), *timeout, workflowRun.GetID())
+	}
 	if err != nil {
+		if activities.IsInvalidAudio(err) || activities.IsEmptyAudio(err) {
+			log.Fatalf("%s is not an audio file that can be processed: %v", filePath, err)
+		}
 		log.Fatalf("Workflow execution failed: %v", err)
 	}
 
-	// Print results
+	// Print results (logs go to stderr, so stdout only carries the JSON)
+	if *output == "json" {
+		printJSON(result)
+		return
+	}
+	if result.Cancelled {
+		log.Println("Workflow was cancelled")
+		log.Printf("Ingested Asset ID: %s", result.IngestedAsset.AssetID)
+		return
+	}
 	log.Println("✅ Workflow completed successfully!")
+	if result.Duplicate {
+		log.Println("Content was already ingested, returning the existing asset")
+	}
 	log.Printf("Ingested Asset ID: %s", result.IngestedAsset.AssetID)
 	log.Printf("Ingested Asset Path: %s", result.IngestedAsset.FilePath)
 	log.Printf("Ingested Asset Duration: %.2f seconds", result.IngestedAsset.Metadata.Duration)
 	log.Printf("Trimmed Output Path: %s", result.TrimmedOutput.OutputPath)
 	log.Printf("Was Trimmed: %v", result.TrimmedOutput.WasTrimmed)
 	log.Printf("No Op: %v", result.TrimmedOutput.NoOp)
+	log.Printf("All Silent: %v", result.TrimmedOutput.AllSilent)
+}
+
+// runQuery prints the progress of a running AudioProcessingWorkflow
+func runQuery(args []string) {
+	flags := newFlagSet("query", "<workflow-id>")
+	output := flags.String("output", "text", "result format: text (log lines) or json (single object on stdout)")
+	if err := flags.Parse(args); err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	workflowID := requireArg(flags)
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Invalid -output %q: must be text or json", *output)
+	}
+
+	cfg, err := config.LoadClient()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	temporalClient, closeClient := dialTemporal(cfg)
+	defer closeClient()
+
+	printProgress(temporalClient, workflowID, *output)
+}
+
+// runCancel signals an AudioProcessingWorkflow to stop after its current step
+func runCancel(args []string) {
+	flags := newFlagSet("cancel", "<workflow-id>")
+	if err := flags.Parse(args); err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	workflowID := requireArg(flags)
+
+	cfg, err := config.LoadClient()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	temporalClient, closeClient := dialTemporal(cfg)
+	defer closeClient()
+
+	err = temporalClient.SignalWorkflow(context.Background(), workflowID, "", workflows.CancelSignalName, nil)
+	if err != nil {
+		log.Fatalf("Failed to cancel workflow: %v", err)
+	}
+	log.Printf("Cancel requested for workflow %s", workflowID)
+}
+
+// runExport writes every feature stored for an asset as CSV or JSON, reading
+// the database directly rather than going through Temporal
+func runExport(args []string) {
+	flags := newFlagSet("export", "<asset-id>")
+	format := flags.String("format", database.ExportFormatCSV, "output format: csv (one section per feature type) or json")
+	outputPath := flags.String("output", "", "file to write to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		log.Fatalf("Invalid arguments: %v", err)
+	}
+	assetID := requireArg(flags)
+	if *format != database.ExportFormatCSV && *format != database.ExportFormatJSON {
+		log.Fatalf("Invalid -format %q: must be csv or json", *format)
+	}
+
+	// Exporting only reads, so it needs no more than the DB_* settings and
+	// leaves migrating the schema to the worker or cmd/migrate
+	dbConfig, err := config.LoadDatabase()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	dbClient, err := database.Open(dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer dbClient.Close()
+
+	out := os.Stdout
+	if *outputPath != "" {
+		if out, err = os.Create(*outputPath); err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+	}
+	writer := bufio.NewWriter(out)
+	if err = dbClient.ExportAssetFeatures(context.Background(), assetID, writer, *format); err != nil {
+		log.Fatalf("Failed to export features of asset %s: %v", assetID, err)
+	}
+	if err = writer.Flush(); err != nil {
+		log.Fatalf("Failed to write features: %v", err)
+	}
+	if *outputPath != "" {
+		if err = out.Close(); err != nil {
+			log.Fatalf("Failed to write features: %v", err)
+		}
+	}
+}
+
+// dialTemporal sets up tracing, so a started workflow is the root span of its
+// trace, and connects to Temporal. The returned function closes both.
+func dialTemporal(cfg *config.ClientConfig) (client.Client, func()) {
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint, cfg.App.Name+"-client")
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+
+	tracingInterceptor, err := tracing.NewTemporalInterceptor()
+	if err != nil {
+		log.Fatalf("Failed to create tracing interceptor: %v", err)
+	}
+
+	// Create Temporal client
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:     cfg.Temporal.Address,
+		Namespace:    cfg.Temporal.Namespace,
+		Interceptors: []interceptor.ClientInterceptor{tracingInterceptor},
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Temporal client: %v", err)
+	}
+	return temporalClient, func() {
+		temporalClient.Close()
+		shutdownTracing(context.Background())
+	}
+}
+
+// printProgress queries a running AudioProcessingWorkflow and prints its progress
+func printProgress(temporalClient client.Client, workflowID, output string) {
+	value, err := temporalClient.QueryWorkflow(context.Background(), workflowID, "", workflows.ProgressQueryName)
+	if err != nil {
+		log.Fatalf("Failed to query workflow progress: %v", err)
+	}
+
+	var progress workflows.AudioProcessingProgress
+	if err := value.Get(&progress); err != nil {
+		log.Fatalf("Failed to decode workflow progress: %v", err)
+	}
+
+	if output == "json" {
+		printJSON(progress)
+		return
+	}
+
+	log.Printf("Workflow ID: %s", workflowID)
+	log.Printf("Stage: %s", progress.Stage)
+	log.Printf("Ingested Asset ID: %s", progress.IngestedAssetID)
+	log.Printf("Trimmed Asset ID: %s", progress.TrimmedAssetID)
+}
+
+// saveStdin copies stdin into a new file in dir and returns its absolute path.
+// The worker reads its input from storage rather than from the client, and the
+// audio decoders need to seek, so piped audio is buffered to a file either way.
+// The file is named after the container the audio is in, as task queues are
+// routed by extension. dir must be readable by the worker, like the API's
+// upload directory.
+func saveStdin(dir string) (path string, err error) {
+	stdin := bufio.NewReader(os.Stdin)
+	header, err := stdin.Peek(activities.ContainerHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	ext, err := activities.ContainerExtension(header)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	savePath, err := filepath.Abs(filepath.Join(dir, "stdin_"+uuid.New().String()+ext))
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(savePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close upload file: %w", closeErr)
+			path = ""
+		}
+		if err != nil {
+			os.Remove(savePath)
+		}
+	}()
+
+	if _, err = io.Copy(file, stdin); err != nil {
+		return "", fmt.Errorf("failed to save stdin: %w", err)
+	}
+	return savePath, nil
+}
+
+// printJSON writes v to stdout as a single JSON object
+func printJSON(v interface{}) {
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		log.Fatalf("Failed to encode JSON output: %v", err)
+	}
 }
@@ -0,0 +1,28 @@
+// Command migrate applies pending database schema migrations and exits. It
+// lets migrations run out of band, with a user that has DDL privileges, while
+// the worker runs as a restricted user with DB_AUTO_MIGRATE=false.
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/pphelan007/davidAI/internal/config"
+	"github.com/pphelan007/davidAI/internal/database"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+
+	// Migrating is the whole point here, whatever the worker's setting
+	cfg.Database.AutoMigrate = true
+	dbClient, err := database.NewClient(&cfg.Database)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to migrate database")
+	}
+	defer dbClient.Close()
+
+	log.Info().Str("database", cfg.Database.DBName).Msg("Database schema is up to date")
+}
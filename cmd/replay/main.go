@@ -0,0 +1,69 @@
+// Command replay replays captured workflow histories against the current
+// workflow code. A replay fails when the code would make different decisions
+// than the ones recorded in the history, which means deploying it would break
+// workflows that are still running.
+//
+// Histories are exported from a Temporal server with
+//
+//	temporal workflow show --workflow-id <id> --output json > <dir>/<name>.json
+//
+// (or make capture-history WORKFLOW_ID=<id>).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"go.temporal.io/sdk/worker"
+
+	"github.com/pphelan007/davidAI/internal/temporal/workflows"
+)
+
+// defaultHistoryDir holds the histories CI replays on every change
+const defaultHistoryDir = "internal/temporal/workflows/testdata/histories"
+
+func main() {
+	dir := flag.String("dir", defaultHistoryDir, "directory of history JSON files to replay when no files are given")
+	flag.Parse()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		var err error
+		files, err = filepath.Glob(filepath.Join(*dir, "*.json"))
+		if err != nil {
+			log.Fatalf("Failed to list histories in %s: %v", *dir, err)
+		}
+	}
+	// An empty directory would pass without checking anything
+	if len(files) == 0 {
+		log.Fatalf("No workflow histories found in %s", *dir)
+	}
+
+	failed := 0
+	for _, file := range files {
+		if err := replay(file); err != nil {
+			log.Printf("❌ %s: %v", file, err)
+			failed++
+			continue
+		}
+		log.Printf("✅ %s", file)
+	}
+	if failed > 0 {
+		log.Printf("%d of %d histories failed to replay", failed, len(files))
+		os.Exit(1)
+	}
+}
+
+// replay replays one history file with a fresh replayer, so a workflow that
+// panics or blocks in one history can't affect the next
+func replay(file string) error {
+	replayer := worker.NewWorkflowReplayer()
+	workflows.RegisterWorkflows(replayer)
+	if err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, file); err != nil {
+		return fmt.Errorf("non-deterministic or failed replay: %w", err)
+	}
+	return nil
+}
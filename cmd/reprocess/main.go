@@ -0,0 +1,241 @@
+// Command reprocess recomputes features for every asset in the database, e.g.
+// after the SNR algorithm changed. It starts a FeatureExtractionWorkflow per
+// asset on its stored file_path, with a bounded number running at once, and
+// skips assets whose file no longer exists.
+//
+// Relative file paths are resolved against DATA_DIR, so it must see the same
+// files as the workers (run it next to them or against S3 paths).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+
+	"github.com/pphelan007/davidAI/internal/config"
+	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/storage"
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
+	"github.com/pphelan007/davidAI/internal/temporal/workflows"
+	"github.com/pphelan007/davidAI/internal/tracing"
+)
+
+// pageSize is how many assets are read from the database at a time
+const pageSize = 100
+
+func main() {
+	features := flag.String("features", "snr", "comma-separated feature types to recompute")
+	concurrency := flag.Int("concurrency", 4, "maximum number of workflows running at once")
+	dryRun := flag.Bool("dry-run", false, "only report which assets would be reprocessed or skipped")
+	flag.Parse()
+
+	if *concurrency < 1 {
+		log.Fatalf("Invalid -concurrency %d: must be at least 1", *concurrency)
+	}
+	// Catch a typo before every workflow fails on it
+	featureList := strings.Split(*features, ",")
+	for _, feature := range featureList {
+		if _, ok := activities.LookupFeature(feature); !ok {
+			log.Fatalf("Unknown feature %q: supported features are %s", feature, strings.Join(activities.FeatureNames(), ", "))
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	dbClient, err := database.NewClient(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to create database client: %v", err)
+	}
+	defer dbClient.Close()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint, cfg.App.Name+"-reprocess")
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// A dry run only reads the database and storage, so it works without a
+	// Temporal server
+	var temporalClient client.Client
+	if !*dryRun {
+		temporalClient = dialTemporal(cfg)
+		defer temporalClient.Close()
+	}
+
+	r := &reprocessor{
+		db:           dbClient,
+		storage:      storage.NewRouter(cfg.Data.Dir),
+		temporal:     temporalClient,
+		taskQueueFor: cfg.Temporal.TaskQueueFor,
+		features:     featureList,
+		activityOptions: &workflows.ActivityOptions{
+			StartToCloseTimeout: cfg.Activity.StartToCloseTimeout,
+			InitialInterval:     cfg.Activity.InitialInterval,
+			MaximumInterval:     cfg.Activity.MaximumInterval,
+			MaximumAttempts:     cfg.Activity.MaximumAttempts,
+		},
+		dryRun: *dryRun,
+		// Workflow IDs are unique per run of the command, so rerunning it
+		// reprocesses everything again
+		batchID: time.Now().UTC().Format("20060102T150405"),
+	}
+	if err = r.run(context.Background(), *concurrency); err != nil {
+		log.Fatalf("Reprocessing stopped: %v", err)
+	}
+
+	r.report()
+	if len(r.failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+// dialTemporal connects to Temporal with the tracing interceptor, so each
+// workflow start is the root span of its trace
+func dialTemporal(cfg *config.Config) client.Client {
+	tracingInterceptor, err := tracing.NewTemporalInterceptor()
+	if err != nil {
+		log.Fatalf("Failed to create tracing interceptor: %v", err)
+	}
+
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:     cfg.Temporal.Address,
+		Namespace:    cfg.Temporal.Namespace,
+		Interceptors: []interceptor.ClientInterceptor{tracingInterceptor},
+	})
+	if err != nil {
+		log.Fatalf("Failed to create Temporal client: %v", err)
+	}
+	return temporalClient
+}
+
+// reprocessor runs feature extraction over all assets and collects the outcome
+// of each one
+type reprocessor struct {
+	db              *database.Client
+	storage         storage.Storage
+	temporal        client.Client
+	taskQueueFor    func(filePath string) string
+	features        []string
+	activityOptions *workflows.ActivityOptions
+	dryRun          bool
+	batchID         string
+
+	mu        sync.Mutex
+	succeeded int
+	missing   []string // assets skipped because their file is gone
+	failed    []string // assets whose workflow or file check failed
+}
+
+// run pages through the assets and reprocesses each one, with at most
+// concurrency workflows in flight. It only returns an error if the assets
+// can't be listed; failures of single assets are recorded and reported.
+func (r *reprocessor) run(ctx context.Context, concurrency int) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sem := make(chan struct{}, concurrency)
+
+	// Oldest first, so assets ingested while this runs land on later pages
+	// instead of shifting the ones not read yet
+	for offset := 0; ; offset += pageSize {
+		assets, err := r.db.ListAssets(pageSize, offset, "created_at ASC")
+		if err != nil {
+			return err
+		}
+		for _, asset := range assets {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(asset *database.Asset) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				r.reprocess(ctx, asset)
+			}(asset)
+		}
+		if len(assets) < pageSize {
+			return nil
+		}
+	}
+}
+
+// reprocess runs feature extraction for one asset and waits for it to finish
+func (r *reprocessor) reprocess(ctx context.Context, asset *database.Asset) {
+	exists, err := r.storage.Exists(ctx, asset.FilePath)
+	if err != nil {
+		r.fail(asset, fmt.Errorf("failed to check file: %w", err))
+		return
+	}
+	if !exists {
+		r.mu.Lock()
+		r.missing = append(r.missing, fmt.Sprintf("%s (%s)", asset.ID, asset.FilePath))
+		r.mu.Unlock()
+		return
+	}
+	if r.dryRun {
+		log.Printf("Would reprocess asset %s (%s)", asset.ID, asset.FilePath)
+		r.succeed()
+		return
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("reprocess-%s-%s", r.batchID, asset.ID),
+		TaskQueue: r.taskQueueFor(asset.FilePath),
+	}
+	run, err := r.temporal.ExecuteWorkflow(ctx, workflowOptions, workflows.FeatureExtractionWorkflow,
+		workflows.FeatureExtractionWorkflowInput{
+			AssetID:         asset.ID,
+			FilePath:        asset.FilePath,
+			Features:        r.features,
+			ActivityOptions: r.activityOptions,
+		})
+	if err != nil {
+		r.fail(asset, fmt.Errorf("failed to start workflow: %w", err))
+		return
+	}
+
+	var result workflows.FeatureExtractionWorkflowOutput
+	if err = run.Get(ctx, &result); err != nil {
+		r.fail(asset, err)
+		return
+	}
+	if len(result.Errors) > 0 {
+		r.fail(asset, fmt.Errorf("features failed: %v", result.Errors))
+		return
+	}
+	log.Printf("✅ Reprocessed asset %s", asset.ID)
+	r.succeed()
+}
+
+func (r *reprocessor) succeed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.succeeded++
+}
+
+func (r *reprocessor) fail(asset *database.Asset, err error) {
+	log.Printf("❌ Asset %s: %v", asset.ID, err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, fmt.Sprintf("%s: %v", asset.ID, err))
+}
+
+// report prints the totals followed by the skipped and failed assets
+func (r *reprocessor) report() {
+	log.Printf("Reprocessed %d assets, skipped %d with missing files, %d failed",
+		r.succeeded, len(r.missing), len(r.failed))
+	for _, missing := range r.missing {
+		log.Printf("Missing file: %s", missing)
+	}
+	for _, failed := range r.failed {
+		log.Printf("Failed: %s", failed)
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"github.com/rs/zerolog/log"
 
 	"github.com/pphelan007/davidAI/internal"
 	"github.com/pphelan007/davidAI/internal/config"
@@ -11,11 +11,11 @@ func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Fatal().Err(err).Msg("Failed to load config")
 	}
 
 	// Run the worker
 	if err := internal.Run(cfg); err != nil {
-		log.Fatalf("Worker error: %v", err)
+		log.Fatal().Err(err).Msg("Worker error")
 	}
 }
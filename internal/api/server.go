@@ -0,0 +1,301 @@
+// Package api provides an HTTP API for submitting audio files for processing
+// and fetching workflow results.
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+
+	"github.com/pphelan007/davidAI/internal/config"
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
+	"github.com/pphelan007/davidAI/internal/temporal/workflows"
+	"github.com/pphelan007/davidAI/internal/utils"
+)
+
+// maxUploadBytes caps the size of an uploaded audio file
+const maxUploadBytes = 1 << 30 // 1GB
+
+// ProcessRequest is the JSON body for POST /process when referencing an existing file
+type ProcessRequest struct {
+	FilePath  string `json:"file_path"`            // local file inside the input directory, relative paths being under it
+	DatasetID string `json:"dataset_id,omitempty"` // dataset the assets are stored in
+}
+
+// ProcessResponse is returned when a workflow is started
+type ProcessResponse struct {
+	WorkflowID string `json:"workflow_id"`
+	RunID      string `json:"run_id"`
+}
+
+// ResultResponse is returned by GET /result/{workflowID}
+type ResultResponse struct {
+	WorkflowID string                                   `json:"workflow_id"`
+	Status     string                                   `json:"status"`
+	Result     *workflows.AudioProcessingWorkflowOutput `json:"result,omitempty"`
+	Error      string                                   `json:"error,omitempty"`
+}
+
+// handler serves the API endpoints
+type handler struct {
+	temporalClient  client.Client
+	taskQueueFor    func(filePath string) string
+	uploadDir       string
+	inputDir        string
+	hashAlgorithm   string
+	activityOptions workflows.ActivityOptions
+}
+
+// NewServer creates a routine serving the API on cfg.Port. Uploaded files are
+// stored in cfg.UploadDir, which must be readable by the worker, and file
+// paths sent in a JSON body must be inside cfg.InputDir. Workflow IDs are
+// derived from the content hashed with hashAlgorithm, so the same content maps
+// to the same workflow. Workflows are started on the task queue taskQueueFor
+// returns for their file, and apply activityOptions to their activities.
+func NewServer(cfg *config.APIConfig, hashAlgorithm string, temporalClient client.Client, taskQueueFor func(filePath string) string,
+	activityOptions workflows.ActivityOptions) *utils.HTTPServerRoutine {
+	h := &handler{
+		temporalClient:  temporalClient,
+		taskQueueFor:    taskQueueFor,
+		uploadDir:       cfg.UploadDir,
+		inputDir:        cfg.InputDir,
+		hashAlgorithm:   hashAlgorithm,
+		activityOptions: activityOptions,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /process", h.process)
+	mux.HandleFunc("GET /result/{workflowID}", h.result)
+
+	return utils.NewHTTPServerRoutine("api", &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	})
+}
+
+// process starts an AudioProcessingWorkflow for a file path (JSON body) or an
+// uploaded file (multipart form field "file", with an optional "dataset_id").
+// Requests for content whose workflow is still running get that workflow back.
+func (h *handler) process(w http.ResponseWriter, r *http.Request) {
+	var filePath, datasetID, contentHash string
+	uploaded := false
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		path, hash, err := h.saveUpload(w, r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filePath, contentHash, uploaded = path, hash, true
+		datasetID = r.FormValue("dataset_id")
+	} else {
+		var req ProcessRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if req.FilePath == "" {
+			writeError(w, http.StatusBadRequest, "file_path is required")
+			return
+		}
+		path, err := resolveInputPath(h.inputDir, req.FilePath)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		hash, err := activities.HashFile(path, h.hashAlgorithm)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to read file_path: %v", err))
+			return
+		}
+		filePath, contentHash = path, hash
+		datasetID = req.DatasetID
+	}
+
+	// The same content maps to the same workflow
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                                       workflows.AudioProcessingWorkflowID(workflows.AudioProcessingWorkflowIDPrefix, datasetID, contentHash),
+		TaskQueue:                                h.taskQueueFor(filePath),
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	run, err := h.temporalClient.ExecuteWorkflow(r.Context(), workflowOptions, workflows.AudioProcessingWorkflow,
+		workflows.AudioProcessingWorkflowInput{FilePath: filePath, DatasetID: datasetID, ActivityOptions: &h.activityOptions})
+	if err != nil {
+		// The running workflow reads the file it was started with, so this
+		// request's upload is never used
+		if uploaded {
+			if removeErr := os.Remove(filePath); removeErr != nil {
+				log.Warn().Err(removeErr).Str("file_path", filePath).Msg("Failed to remove unused upload")
+			}
+		}
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if errors.As(err, &alreadyStarted) {
+			log.Info().Str("workflow_id", workflowOptions.ID).Msg("Workflow already running for this content")
+			writeJSON(w, http.StatusAccepted, ProcessResponse{
+				WorkflowID: workflowOptions.ID,
+				RunID:      alreadyStarted.RunId,
+			})
+			return
+		}
+		log.Error().Err(err).Str("file_path", filePath).Msg("Failed to start workflow")
+		writeError(w, http.StatusInternalServerError, "failed to start workflow")
+		return
+	}
+
+	log.Info().Str("workflow_id", run.GetID()).Str("file_path", filePath).Msg("Workflow started via API")
+	writeJSON(w, http.StatusAccepted, ProcessResponse{
+		WorkflowID: run.GetID(),
+		RunID:      run.GetRunID(),
+	})
+}
+
+// resolveInputPath returns the absolute path of a file_path from a request,
+// relative paths being under inputDir. Paths outside inputDir are rejected, so
+// the API can't be used to process (or probe for) arbitrary files.
+func resolveInputPath(inputDir, filePath string) (string, error) {
+	path := filepath.Clean(filePath)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(inputDir, path)
+	}
+	rel, err := filepath.Rel(inputDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file_path %q is outside the input directory", filePath)
+	}
+	return path, nil
+}
+
+// saveUpload stores the uploaded "file" form field in the upload directory and
+// returns its path and content hash
+func (h *handler) saveUpload(w http.ResponseWriter, r *http.Request) (path, contentHash string, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	src, header, err := r.FormFile("file")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(h.uploadDir, 0o750); err != nil {
+		return "", "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	// Prefix with a UUID so concurrent uploads with the same name don't collide
+	savePath := filepath.Join(h.uploadDir, uuid.New().String()+"_"+filepath.Base(header.Filename))
+	dst, err := os.Create(savePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create upload file: %w", err)
+	}
+	defer func() {
+		if closeErr := dst.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close upload file: %w", closeErr)
+			path, contentHash = "", ""
+		}
+		if err != nil {
+			os.Remove(savePath)
+		}
+	}()
+
+	// Hash while saving so the content isn't read twice
+	hash, prefix := activities.NewContentHash(h.hashAlgorithm)
+	if _, err = io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+		return "", "", fmt.Errorf("failed to save uploaded file: %w", err)
+	}
+	return savePath, prefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// result returns the workflow result: 200 when completed, 202 while running,
+// 404 for unknown workflows, and 500 when the workflow failed
+func (h *handler) result(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.PathValue("workflowID")
+
+	desc, err := h.temporalClient.DescribeWorkflowExecution(r.Context(), workflowID, "")
+	if err != nil {
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("workflow %s not found", workflowID))
+			return
+		}
+		log.Error().Err(err).Str("workflow_id", workflowID).Msg("Failed to describe workflow")
+		writeError(w, http.StatusInternalServerError, "failed to describe workflow")
+		return
+	}
+
+	status := desc.GetWorkflowExecutionInfo().GetStatus()
+	response := ResultResponse{
+		WorkflowID: workflowID,
+		Status:     workflowStatusName(status),
+	}
+
+	switch status {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		writeJSON(w, http.StatusAccepted, response)
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		var result workflows.AudioProcessingWorkflowOutput
+		if err = h.getResult(r.Context(), workflowID, &result); err != nil {
+			log.Error().Err(err).Str("workflow_id", workflowID).Msg("Failed to fetch workflow result")
+			writeError(w, http.StatusInternalServerError, "failed to fetch workflow result")
+			return
+		}
+		response.Result = &result
+		writeJSON(w, http.StatusOK, response)
+	default:
+		// Failed, cancelled, terminated, or timed out: surface the failure
+		if err = h.getResult(r.Context(), workflowID, nil); err != nil {
+			response.Error = err.Error()
+		}
+		writeJSON(w, http.StatusInternalServerError, response)
+	}
+}
+
+func (h *handler) getResult(ctx context.Context, workflowID string, result interface{}) error {
+	return h.temporalClient.GetWorkflow(ctx, workflowID, "").Get(ctx, result)
+}
+
+// workflowStatusName converts a Temporal status to a short lowercase name (e.g. "running")
+func workflowStatusName(status enumspb.WorkflowExecutionStatus) string {
+	switch status {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		return "running"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		return "completed"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_FAILED:
+		return "failed"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED:
+		return "canceled"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+		return "terminated"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW:
+		return "continued_as_new"
+	case enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:
+		return "timed_out"
+	default:
+		return "unknown"
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
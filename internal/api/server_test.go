@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInputPath(t *testing.T) {
+	const inputDir = "/data/input"
+	tests := []struct {
+		filePath string
+		want     string // empty if the path is rejected
+	}{
+		{"song.wav", "/data/input/song.wav"},
+		{"raw/../song.wav", "/data/input/song.wav"},
+		{"/data/input/raw/song.wav", "/data/input/raw/song.wav"},
+		{"../song.wav", ""},
+		{"raw/../../song.wav", ""},
+		{"/data/input/../secret.wav", ""},
+		{"/data/inputs/song.wav", ""},
+		{"/etc/passwd", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filePath, func(t *testing.T) {
+			path, err := resolveInputPath(inputDir, tt.filePath)
+			if tt.want == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, path)
+		})
+	}
+}
@@ -2,8 +2,15 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,17 +22,87 @@ type Config struct {
 	Worker   WorkerConfig
 	Temporal TemporalConfig
 	Database DatabaseConfig
+	Metrics  MetricsConfig
+	Tracing  TracingConfig
+	API      APIConfig
+	Activity ActivityConfig
+	Health   HealthConfig
+	Data     DataConfig
 }
 
 // WorkerConfig holds worker configuration
 type WorkerConfig struct {
+	ShutdownGracePeriod time.Duration // how long in-flight activities may run after a shutdown signal
+	MaxRestarts         int           // times a failed HTTP routine is restarted before the process exits
 }
 
 // TemporalConfig holds Temporal configuration
 type TemporalConfig struct {
 	Address   string
 	Namespace string
-	TaskQueue string
+	TaskQueue string // queue workflows start on unless TaskQueueRoutes routes their file elsewhere
+
+	// Workflows can be routed to task queues by the extension of the file
+	// they process, e.g. to run compressed files on workers with more CPU.
+	// A worker polls every queue in WorkerTaskQueues.
+	TaskQueueRoutes  map[string]string // lowercase extension without the dot -> task queue
+	WorkerTaskQueues []string          // defaults to TaskQueue and every routed queue
+
+	// The worker reports its identity and build ID to Temporal, which shows
+	// them in the UI and records the build ID in each workflow task it
+	// completes, so a workflow's history tells which build processed it
+	Identity      string // worker identity, Temporal's "pid@hostname" when empty
+	BuildID       string // defaults to the git commit the binary was built from, if known
+	UseVersioning bool   // if true, only take tasks the server's build ID rules assign to BuildID
+
+	// Connecting at startup is retried so the worker waits for a Temporal
+	// server that is still starting (e.g. under docker-compose) instead of
+	// exiting. The delay doubles after each failed attempt.
+	DialAttempts      int           // attempts including the first, 1 disables retries
+	DialRetryInterval time.Duration // delay before the first retry
+	CheckHealth       bool          // if true, the server's health check must pass too
+}
+
+// MetricsConfig holds Prometheus metrics server configuration
+type MetricsConfig struct {
+	Port int // port for the /metrics endpoint, 0 disables the server
+}
+
+// HealthConfig holds health check server configuration
+type HealthConfig struct {
+	Port int // port for the /livez, /readyz and /healthz endpoints, 0 disables the server
+}
+
+// DataConfig holds the location of audio files
+type DataConfig struct {
+	Dir             string // absolute base directory that relative audio paths are resolved against
+	OverwritePolicy string // what activities do when an output file exists: unique, overwrite or error
+	HashAlgorithm   string // content hash used for dedup and caching: sha256 or xxhash
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	OTLPEndpoint string // OTLP gRPC endpoint URL, tracing is disabled when empty
+}
+
+// APIConfig holds HTTP API server configuration
+type APIConfig struct {
+	Port      int    // port for the REST API, 0 disables the server
+	UploadDir string // directory where uploaded files are stored for processing, defaults to DATA_DIR/uploads
+	InputDir  string // absolute directory file paths sent to the API must be inside, defaults to DATA_DIR
+}
+
+// ActivityConfig holds the timeout and retry policy for workflow activities
+type ActivityConfig struct {
+	StartToCloseTimeout  time.Duration // maximum time a single activity attempt may run
+	InitialInterval      time.Duration // delay before the first retry
+	MaximumInterval      time.Duration // cap on the exponential retry delay
+	MaximumAttempts      int           // attempts including the first
+	RetryJitter          time.Duration // maximum random delay before a retried attempt starts, 0 disables it
+	HangDetection        bool          // if false, sample-processing loops are never cancelled as hung
+	HangTimeout          time.Duration // time after which they are, 0 leaves it to each attempt's deadline
+	MaxFileSize          int64         // largest audio file in bytes activities decode into memory, 0 disables the limit
+	MaxConcurrentDecodes int           // activities decoding audio at once, defaults to the number of CPUs, 0 disables the limit
 }
 
 // AppConfig holds application configuration
@@ -36,7 +113,34 @@ type AppConfig struct {
 
 // LogConfig holds logging configuration
 type LogConfig struct {
-	Level string
+	Level string // one of debug, info, warn, error
+}
+
+// validLogLevels are the accepted values for LOG_LEVEL
+var validLogLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+// validOverwritePolicies are the accepted values for OUTPUT_OVERWRITE_POLICY
+var validOverwritePolicies = map[string]bool{
+	"unique":    true,
+	"overwrite": true,
+	"error":     true,
+}
+
+// validHashAlgorithms are the accepted values for HASH_ALGORITHM
+var validHashAlgorithms = map[string]bool{
+	"sha256": true,
+	"xxhash": true,
+}
+
+// validDBFailurePolicies are the accepted values for DB_FAILURE_POLICY
+var validDBFailurePolicies = map[string]bool{
+	"continue": true,
+	"fail":     true,
 }
 
 // DatabaseConfig holds database configuration
@@ -47,6 +151,23 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Certificate files for TLS connections, e.g. with SSLMode verify-full.
+	// Unset fields are left out of the connection string, so the driver's
+	// defaults apply.
+	SSLRootCert string // CA certificate the server's certificate is verified against
+	SSLCert     string // client certificate, for servers that require one
+	SSLKey      string // private key of SSLCert
+
+	// AutoMigrate applies pending schema migrations when the client connects.
+	// Turn it off when migrations are run out of band (e.g. with cmd/migrate)
+	// and the runtime user has no DDL privileges.
+	AutoMigrate bool
+
+	// FailurePolicy is what activities do when a database call fails at
+	// runtime: "continue" keeps their audio results and flags the skipped
+	// call in the output, "fail" fails the attempt so Temporal retries it
+	FailurePolicy string
 }
 
 // Load reads configuration from environment variables
@@ -54,9 +175,71 @@ func Load() (*Config, error) {
 	// Try to load .env file (ignore error if it doesn't exist)
 	_ = godotenv.Load()
 
-	dbPort, err := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	metricsPort, err := strconv.Atoi(getEnv("METRICS_PORT", "9090"))
 	if err != nil {
-		dbPort = 5432
+		return nil, fmt.Errorf("invalid METRICS_PORT: %w", err)
+	}
+
+	apiPort, err := strconv.Atoi(getEnv("API_PORT", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_PORT: %w", err)
+	}
+
+	healthPort, err := strconv.Atoi(getEnv("HEALTH_PORT", "8080"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HEALTH_PORT: %w", err)
+	}
+
+	shutdownGracePeriod, err := time.ParseDuration(getEnv("WORKER_SHUTDOWN_GRACE_PERIOD", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WORKER_SHUTDOWN_GRACE_PERIOD: %w", err)
+	}
+
+	maxRestarts, err := strconv.Atoi(getEnv("ROUTINE_MAX_RESTARTS", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROUTINE_MAX_RESTARTS: %w", err)
+	}
+
+	activityConfig, err := loadActivityConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the data directory once at startup so later path handling
+	// doesn't depend on the working directory
+	dataDir, err := filepath.Abs(getEnv("DATA_DIR", "data"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATA_DIR: %w", err)
+	}
+
+	apiInputDir, err := filepath.Abs(getEnv("API_INPUT_DIR", dataDir))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_INPUT_DIR: %w", err)
+	}
+
+	temporalConfig, err := loadTemporalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	overwritePolicy := strings.ToLower(strings.TrimSpace(getEnv("OUTPUT_OVERWRITE_POLICY", "unique")))
+	if !validOverwritePolicies[overwritePolicy] {
+		return nil, fmt.Errorf("invalid OUTPUT_OVERWRITE_POLICY %q: must be one of unique, overwrite, error", overwritePolicy)
+	}
+
+	hashAlgorithm, err := loadHashAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	databaseConfig, err := loadDatabaseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel := strings.ToLower(strings.TrimSpace(getEnv("LOG_LEVEL", "info")))
+	if !validLogLevels[logLevel] {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %q: must be one of debug, info, warn, error", logLevel)
 	}
 
 	return &Config{
@@ -65,22 +248,336 @@ func Load() (*Config, error) {
 			Env:  getEnv("ENV", "development"),
 		},
 		Log: LogConfig{
-			Level: getEnv("LOG_LEVEL", "info"),
+			Level: logLevel,
 		},
-		Worker: WorkerConfig{},
-		Temporal: TemporalConfig{
-			Address:   getEnv("TEMPORAL_ADDRESS", "localhost:7233"),
-			Namespace: getEnv("TEMPORAL_NAMESPACE", "default"),
-			TaskQueue: getEnv("TEMPORAL_TASK_QUEUE", "davidai-task-queue"),
+		Worker: WorkerConfig{
+			ShutdownGracePeriod: shutdownGracePeriod,
+			MaxRestarts:         maxRestarts,
+		},
+		Temporal: *temporalConfig,
+		Database: *databaseConfig,
+		Metrics: MetricsConfig{
+			Port: metricsPort,
+		},
+		Tracing: TracingConfig{
+			OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		},
+		API: APIConfig{
+			Port:      apiPort,
+			UploadDir: getEnv("API_UPLOAD_DIR", filepath.Join(dataDir, "uploads")),
+			InputDir:  apiInputDir,
+		},
+		Activity: *activityConfig,
+		Health: HealthConfig{
+			Port: healthPort,
+		},
+		Data: DataConfig{
+			Dir:             dataDir,
+			OverwritePolicy: overwritePolicy,
+			HashAlgorithm:   hashAlgorithm,
+		},
+	}, nil
+}
+
+// loadHashAlgorithm reads HASH_ALGORITHM
+func loadHashAlgorithm() (string, error) {
+	hashAlgorithm := strings.ToLower(strings.TrimSpace(getEnv("HASH_ALGORITHM", "sha256")))
+	if !validHashAlgorithms[hashAlgorithm] {
+		return "", fmt.Errorf("invalid HASH_ALGORITHM %q: must be one of sha256, xxhash", hashAlgorithm)
+	}
+	return hashAlgorithm, nil
+}
+
+// LoadDatabase reads only the database configuration from environment
+// variables, for tools that read the database without running a worker
+func LoadDatabase() (*DatabaseConfig, error) {
+	// Try to load .env file (ignore error if it doesn't exist)
+	_ = godotenv.Load()
+	return loadDatabaseConfig()
+}
+
+// loadDatabaseConfig reads the DB_* variables
+func loadDatabaseConfig() (*DatabaseConfig, error) {
+	dbPort, err := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	if err != nil {
+		dbPort = 5432
+	}
+
+	autoMigrate, err := strconv.ParseBool(getEnv("DB_AUTO_MIGRATE", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_AUTO_MIGRATE %q: must be true or false", os.Getenv("DB_AUTO_MIGRATE"))
+	}
+
+	dbFailurePolicy := strings.ToLower(strings.TrimSpace(getEnv("DB_FAILURE_POLICY", "continue")))
+	if !validDBFailurePolicies[dbFailurePolicy] {
+		return nil, fmt.Errorf("invalid DB_FAILURE_POLICY %q: must be one of continue, fail", dbFailurePolicy)
+	}
+
+	return &DatabaseConfig{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     dbPort,
+		User:     getEnv("DB_USER", "davidai"),
+		Password: getEnv("DB_PASSWORD", "davidai"),
+		DBName:   getEnv("DB_NAME", "davidai"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		SSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+		SSLCert:     getEnv("DB_SSLCERT", ""),
+		SSLKey:      getEnv("DB_SSLKEY", ""),
+
+		AutoMigrate:   autoMigrate,
+		FailurePolicy: dbFailurePolicy,
+	}, nil
+}
+
+// ClientConfig holds the configuration of the command-line client that
+// submits workflows. It has no database settings, so submitting a workflow
+// needs only the Temporal server and the activity policy to send along.
+type ClientConfig struct {
+	App      AppConfig
+	Temporal TemporalConfig
+	Tracing  TracingConfig
+	Activity ActivityConfig
+
+	DefaultFile      string        // file processed when none is given, defaults to DATA_DIR/sine440.wav
+	UploadDir        string        // directory audio read from stdin is saved to, must be readable by the worker
+	WorkflowIDPrefix string        // prefix of the IDs of started workflows
+	HashAlgorithm    string        // content hash workflow IDs are derived from, the worker's HASH_ALGORITHM
+	ExecutionTimeout time.Duration // time a started workflow may run including retries, 0 for no limit
+	Wait             bool          // if true, wait for a started workflow to complete and print its result
+}
+
+// LoadClient reads the client configuration from environment variables
+func LoadClient() (*ClientConfig, error) {
+	// Try to load .env file (ignore error if it doesn't exist)
+	_ = godotenv.Load()
+
+	temporalConfig, err := loadTemporalConfig()
+	if err != nil {
+		return nil, err
+	}
+	activityConfig, err := loadActivityConfig()
+	if err != nil {
+		return nil, err
+	}
+	dataDir, err := filepath.Abs(getEnv("DATA_DIR", "data"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATA_DIR: %w", err)
+	}
+
+	hashAlgorithm, err := loadHashAlgorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	workflowIDPrefix := strings.TrimSpace(getEnv("CLIENT_WORKFLOW_ID_PREFIX", "audio-processing"))
+	executionTimeout, err := time.ParseDuration(getEnv("CLIENT_EXECUTION_TIMEOUT", "0s"))
+	if err != nil || executionTimeout < 0 {
+		return nil, fmt.Errorf("invalid CLIENT_EXECUTION_TIMEOUT %q: must be a non-negative duration",
+			os.Getenv("CLIENT_EXECUTION_TIMEOUT"))
+	}
+	wait, err := strconv.ParseBool(getEnv("CLIENT_WAIT", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CLIENT_WAIT %q: must be true or false", os.Getenv("CLIENT_WAIT"))
+	}
+
+	return &ClientConfig{
+		App: AppConfig{
+			Name: getEnv("APP_NAME", "gostarter"),
+			Env:  getEnv("ENV", "development"),
 		},
-		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     dbPort,
-			User:     getEnv("DB_USER", "davidai"),
-			Password: getEnv("DB_PASSWORD", "davidai"),
-			DBName:   getEnv("DB_NAME", "davidai"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Temporal: *temporalConfig,
+		Tracing: TracingConfig{
+			OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
 		},
+		Activity:         *activityConfig,
+		DefaultFile:      getEnv("CLIENT_DEFAULT_FILE", filepath.Join(dataDir, "sine440.wav")),
+		UploadDir:        getEnv("API_UPLOAD_DIR", filepath.Join(dataDir, "uploads")),
+		WorkflowIDPrefix: workflowIDPrefix,
+		HashAlgorithm:    hashAlgorithm,
+		ExecutionTimeout: executionTimeout,
+		Wait:             wait,
+	}, nil
+}
+
+// TaskQueueFor returns the task queue a workflow processing filePath is
+// started on: the queue routed to its extension, or TaskQueue
+func (c *TemporalConfig) TaskQueueFor(filePath string) string {
+	if queue, ok := c.TaskQueueRoutes[strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))]; ok {
+		return queue
+	}
+	return c.TaskQueue
+}
+
+// parseTaskQueueRoutes parses TEMPORAL_TASK_QUEUE_ROUTES, a comma-separated
+// list of ext=queue pairs such as "flac=compressed,ogg=compressed"
+func parseTaskQueueRoutes(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		ext, queue, ok := strings.Cut(pair, "=")
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		queue = strings.TrimSpace(queue)
+		if !ok || ext == "" || queue == "" {
+			return nil, fmt.Errorf("invalid route %q: must be ext=queue", pair)
+		}
+		if _, dup := routes[ext]; dup {
+			return nil, fmt.Errorf("extension %q is routed twice", ext)
+		}
+		routes[ext] = queue
+	}
+	return routes, nil
+}
+
+// loadTemporalConfig reads the Temporal server address, task queues and connection retries
+func loadTemporalConfig() (*TemporalConfig, error) {
+	dialAttempts, err := strconv.Atoi(getEnv("TEMPORAL_DIAL_ATTEMPTS", "10"))
+	if err != nil || dialAttempts < 1 {
+		return nil, fmt.Errorf("invalid TEMPORAL_DIAL_ATTEMPTS %q: must be a positive integer", os.Getenv("TEMPORAL_DIAL_ATTEMPTS"))
+	}
+	dialRetryInterval, err := time.ParseDuration(getEnv("TEMPORAL_DIAL_RETRY_INTERVAL", "1s"))
+	if err != nil || dialRetryInterval < 0 {
+		return nil, fmt.Errorf("invalid TEMPORAL_DIAL_RETRY_INTERVAL %q: must be a non-negative duration",
+			os.Getenv("TEMPORAL_DIAL_RETRY_INTERVAL"))
+	}
+	checkHealth, err := strconv.ParseBool(getEnv("TEMPORAL_CHECK_HEALTH", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEMPORAL_CHECK_HEALTH %q: must be true or false", os.Getenv("TEMPORAL_CHECK_HEALTH"))
+	}
+
+	taskQueue := getEnv("TEMPORAL_TASK_QUEUE", "davidai-task-queue")
+	routes, err := parseTaskQueueRoutes(os.Getenv("TEMPORAL_TASK_QUEUE_ROUTES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEMPORAL_TASK_QUEUE_ROUTES: %w", err)
+	}
+	// By default a worker polls every queue, so a single deployment handles
+	// all files; dedicated deployments list their queues instead
+	var workerQueues []string
+	for _, queue := range strings.Split(os.Getenv("TEMPORAL_WORKER_TASK_QUEUES"), ",") {
+		if queue = strings.TrimSpace(queue); queue != "" && !slices.Contains(workerQueues, queue) {
+			workerQueues = append(workerQueues, queue)
+		}
+	}
+	if len(workerQueues) == 0 {
+		workerQueues = []string{taskQueue}
+		for _, queue := range routes {
+			if !slices.Contains(workerQueues, queue) {
+				workerQueues = append(workerQueues, queue)
+			}
+		}
+		slices.Sort(workerQueues[1:])
+	}
+
+	buildID := getEnv("TEMPORAL_BUILD_ID", vcsRevision())
+	useVersioning, err := strconv.ParseBool(getEnv("TEMPORAL_USE_VERSIONING", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TEMPORAL_USE_VERSIONING %q: must be true or false", os.Getenv("TEMPORAL_USE_VERSIONING"))
+	}
+	if useVersioning && buildID == "" {
+		return nil, fmt.Errorf("TEMPORAL_USE_VERSIONING requires TEMPORAL_BUILD_ID, as the binary has no git commit to default to")
+	}
+
+	return &TemporalConfig{
+		Address:           getEnv("TEMPORAL_ADDRESS", "localhost:7233"),
+		Namespace:         getEnv("TEMPORAL_NAMESPACE", "default"),
+		TaskQueue:         taskQueue,
+		TaskQueueRoutes:   routes,
+		WorkerTaskQueues:  workerQueues,
+		Identity:          getEnv("TEMPORAL_IDENTITY", ""),
+		BuildID:           buildID,
+		UseVersioning:     useVersioning,
+		DialAttempts:      dialAttempts,
+		DialRetryInterval: dialRetryInterval,
+		CheckHealth:       checkHealth,
+	}, nil
+}
+
+// vcsRevision returns the git commit the binary was built from, with a
+// "-dirty" suffix if the tree had uncommitted changes, or "" if the build
+// didn't record it (e.g. built outside a git checkout or with -buildvcs=false)
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// defaultMaxFileSize is the default ACTIVITY_MAX_FILE_SIZE_BYTES (1 GiB). A
+// file that size decodes to several GiB of samples.
+const defaultMaxFileSize = 1 << 30
+
+// loadActivityConfig reads the activity timeout and retry policy
+func loadActivityConfig() (*ActivityConfig, error) {
+	startToCloseTimeout, err := time.ParseDuration(getEnv("ACTIVITY_START_TO_CLOSE_TIMEOUT", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTIVITY_START_TO_CLOSE_TIMEOUT: %w", err)
+	}
+	initialInterval, err := time.ParseDuration(getEnv("ACTIVITY_RETRY_INITIAL_INTERVAL", "1s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTIVITY_RETRY_INITIAL_INTERVAL: %w", err)
+	}
+	maximumInterval, err := time.ParseDuration(getEnv("ACTIVITY_RETRY_MAX_INTERVAL", "1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACTIVITY_RETRY_MAX_INTERVAL: %w", err)
+	}
+	maximumAttempts, err := strconv.Atoi(getEnv("ACTIVITY_MAX_ATTEMPTS", "3"))
+	if err != nil || maximumAttempts < 1 {
+		return nil, fmt.Errorf("invalid ACTIVITY_MAX_ATTEMPTS %q: must be a positive integer", os.Getenv("ACTIVITY_MAX_ATTEMPTS"))
+	}
+	retryJitter, err := time.ParseDuration(getEnv("ACTIVITY_RETRY_JITTER", "1s"))
+	if err != nil || retryJitter < 0 {
+		return nil, fmt.Errorf("invalid ACTIVITY_RETRY_JITTER %q: must be a non-negative duration", os.Getenv("ACTIVITY_RETRY_JITTER"))
+	}
+	// The start-to-close timeout that applies comes from whoever starts the
+	// workflow, so by default the hang detector follows each attempt's own
+	// deadline. A set timeout applies on top of that, and 0 turns it off.
+	hangDetection, hangTimeout := true, time.Duration(0)
+	if value := os.Getenv("ACTIVITY_HANG_TIMEOUT"); value != "" {
+		hangTimeout, err = time.ParseDuration(value)
+		if err != nil || hangTimeout < 0 {
+			return nil, fmt.Errorf("invalid ACTIVITY_HANG_TIMEOUT %q: must be a non-negative duration", value)
+		}
+		hangDetection = hangTimeout > 0
+	}
+
+	maxFileSize, err := strconv.ParseInt(getEnv("ACTIVITY_MAX_FILE_SIZE_BYTES", strconv.Itoa(defaultMaxFileSize)), 10, 64)
+	if err != nil || maxFileSize < 0 {
+		return nil, fmt.Errorf("invalid ACTIVITY_MAX_FILE_SIZE_BYTES %q: must be a non-negative integer",
+			os.Getenv("ACTIVITY_MAX_FILE_SIZE_BYTES"))
+	}
+
+	// Decoding is CPU-bound, so more concurrent decodes than CPUs only add memory
+	maxConcurrentDecodes, err := strconv.Atoi(getEnv("ACTIVITY_MAX_CONCURRENT_DECODES", strconv.Itoa(runtime.NumCPU())))
+	if err != nil || maxConcurrentDecodes < 0 {
+		return nil, fmt.Errorf("invalid ACTIVITY_MAX_CONCURRENT_DECODES %q: must be a non-negative integer",
+			os.Getenv("ACTIVITY_MAX_CONCURRENT_DECODES"))
+	}
+
+	return &ActivityConfig{
+		StartToCloseTimeout:  startToCloseTimeout,
+		InitialInterval:      initialInterval,
+		MaximumInterval:      maximumInterval,
+		MaximumAttempts:      maximumAttempts,
+		RetryJitter:          retryJitter,
+		HangDetection:        hangDetection,
+		HangTimeout:          hangTimeout,
+		MaxFileSize:          maxFileSize,
+		MaxConcurrentDecodes: maxConcurrentDecodes,
 	}, nil
 }
 
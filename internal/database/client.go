@@ -2,22 +2,42 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/pphelan007/davidAI/internal/config"
 )
 
-// Client wraps the database connection
+// tracer emits spans around database calls
+var tracer = otel.Tracer("github.com/pphelan007/davidAI/internal/database")
+
+// Client wraps the database connection. It is safe for concurrent use: *sql.DB
+// is a connection pool, and every method uses only its arguments and the pool.
 type Client struct {
 	DB *sql.DB
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
-// Asset represents an asset record in the database
+// Asset represents an asset record in the database. An asset derived from a
+// single source records it in ParentAssetID. An asset combined from several
+// sources (e.g. concatenated files) stores the first one in ParentAssetID, so
+// lineage queries still reach it, and all of them in order in asset_parents.
 type Asset struct {
 	ID            string
 	WorkflowID    string
@@ -26,6 +46,8 @@ type Asset struct {
 	FilePath      string
 	ContentHash   string
 	CreatedAt     time.Time
+	DeletedAt     *time.Time // set when the asset has been soft-deleted
+	DatasetID     string     // dataset the asset belongs to, empty for none; derived assets inherit their parent's
 }
 
 // Feature represents a feature record in the database
@@ -38,12 +60,55 @@ type Feature struct {
 	ComputedAt        time.Time
 }
 
-// NewClient creates a new database client
+// ActivityRun represents one execution attempt of an activity in the
+// activity_runs audit table
+type ActivityRun struct {
+	ID            string
+	WorkflowID    string
+	WorkflowRunID string
+	ActivityID    string
+	ActivityType  string
+	Attempt       int32
+	StartedAt     time.Time
+	Duration      time.Duration
+	Succeeded     bool
+	ErrorMessage  string // empty when the attempt succeeded
+}
+
+// NewClient creates a new database client. Pending schema migrations are
+// applied first unless cfg.AutoMigrate is off, in which case the schema is
+// assumed to be up to date.
 func NewClient(cfg *config.DatabaseConfig) (*Client, error) {
+	client, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize schema
+	if !cfg.AutoMigrate {
+		log.Info().Msg("Schema migrations disabled (DB_AUTO_MIGRATE=false), assuming the schema is up to date")
+		return client, nil
+	}
+	if err := client.InitSchema(); err != nil {
+		client.DB.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return client, nil
+}
+
+// Open connects to the database without touching the schema, whatever
+// cfg.AutoMigrate says, for tools that only read it
+func Open(cfg *config.DatabaseConfig) (*Client, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
+	sslParams, err := sslCertParams(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dsn += sslParams
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -52,6 +117,7 @@ func NewClient(cfg *config.DatabaseConfig) (*Client, error) {
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -60,61 +126,54 @@ func NewClient(cfg *config.DatabaseConfig) (*Client, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	client := &Client{DB: db}
+	return &Client{DB: db}, nil
+}
 
-	// Initialize schema
-	if err := client.InitSchema(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+// sslCertParams returns the DSN parameters for the configured SSL certificate
+// files, each preceded by a space. lib/pq skips certificate files that don't
+// exist and connects without them, so a configured file that is missing is an
+// error instead.
+func sslCertParams(cfg *config.DatabaseConfig) (string, error) {
+	var params string
+	for _, param := range []struct{ key, env, path string }{
+		{"sslrootcert", "DB_SSLROOTCERT", cfg.SSLRootCert},
+		{"sslcert", "DB_SSLCERT", cfg.SSLCert},
+		{"sslkey", "DB_SSLKEY", cfg.SSLKey},
+	} {
+		if param.path == "" {
+			continue
+		}
+		if _, err := os.Stat(param.path); err != nil {
+			return "", fmt.Errorf("invalid %s: %w", param.env, err)
+		}
+		params += " " + param.key + "=" + param.path
 	}
-
-	return client, nil
+	return params, nil
 }
 
-// InitSchema creates the database tables if they don't exist
+// InitSchema brings the database schema up to date by applying any pending
+// migrations
 func (c *Client) InitSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS assets (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		workflow_id VARCHAR(255) NOT NULL,
-		workflow_run_id VARCHAR(255) NOT NULL,
-		parent_asset_id UUID REFERENCES assets(id),
-		file_path TEXT NOT NULL,
-		content_hash VARCHAR(64) NOT NULL,
-		created_at TIMESTAMP DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_workflow_run ON assets(workflow_run_id);
-	CREATE INDEX IF NOT EXISTS idx_parent_asset ON assets(parent_asset_id);
-	CREATE INDEX IF NOT EXISTS idx_content_hash ON assets(content_hash);
-	CREATE INDEX IF NOT EXISTS idx_workflow_id ON assets(workflow_id);
-
-	CREATE TABLE IF NOT EXISTS features (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
-		feature_type VARCHAR(50) NOT NULL,
-		feature_data JSONB NOT NULL,
-		computation_params JSONB,
-		computed_at TIMESTAMP DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_features_asset ON features(asset_id);
-	CREATE INDEX IF NOT EXISTS idx_features_type ON features(feature_type);
-	CREATE INDEX IF NOT EXISTS idx_features_computed_at ON features(computed_at);
-	`
-
-	_, err := c.DB.Exec(query)
-	return err
+	return c.Migrate(context.Background())
 }
 
 // InsertAsset inserts a new asset record into the database
 func (c *Client) InsertAsset(asset *Asset) error {
+	return c.InsertAssetContext(context.Background(), asset)
+}
+
+// InsertAssetContext is like InsertAsset but traces the insert as a child of ctx
+func (c *Client) InsertAssetContext(ctx context.Context, asset *Asset) error {
+	// A derived asset always lands in its parent's dataset, so only root
+	// assets need DatasetID set
 	query := `
-	INSERT INTO assets (id, workflow_id, workflow_run_id, parent_asset_id, file_path, content_hash, created_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	INSERT INTO assets (id, workflow_id, workflow_run_id, parent_asset_id, file_path, content_hash, created_at, dataset_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE((SELECT dataset_id FROM assets WHERE id = $4), $8))
 	`
 
-	_, err := c.DB.Exec(
+	return c.execContext(
+		ctx,
+		"InsertAsset",
 		query,
 		asset.ID,
 		asset.WorkflowID,
@@ -123,52 +182,812 @@ func (c *Client) InsertAsset(asset *Asset) error {
 		asset.FilePath,
 		asset.ContentHash,
 		asset.CreatedAt,
+		asset.DatasetID,
 	)
+}
 
-	return err
+// InsertAssetParents records the ordered source assets of an asset combined
+// from several sources. parentAssetIDs[0] should match the asset's ParentAssetID.
+func (c *Client) InsertAssetParents(ctx context.Context, assetID string, parentAssetIDs []string) (err error) {
+	ctx, span := startSpan(ctx, "InsertAssetParents")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i, parentID := range parentAssetIDs {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO asset_parents (asset_id, parent_asset_id, position) VALUES ($1, $2, $3)", assetID, parentID, i)
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("failed to insert asset parent: %w (rollback failed: %v)", err, rollbackErr)
+			}
+			return fmt.Errorf("failed to insert asset parent: %w", err)
+		}
+	}
+	return tx.Commit()
 }
 
-// InsertFeature inserts a new feature record into the database
-func (c *Client) InsertFeature(feature *Feature) error {
-	// Convert feature data to JSON
-	featureDataJSON, err := json.Marshal(feature.FeatureData)
+// GetAssetParents returns the IDs of the source assets of an asset in order:
+// every entry from asset_parents, or just parent_asset_id for assets derived
+// from a single source. Root assets have none.
+func (c *Client) GetAssetParents(ctx context.Context, assetID string) (parentIDs []string, err error) {
+	ctx, span := startSpan(ctx, "GetAssetParents")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	rows, err := c.DB.QueryContext(ctx, `
+	SELECT parent_asset_id::text, position FROM asset_parents WHERE asset_id = $1
+	UNION ALL
+	SELECT parent_asset_id::text, 0 FROM assets
+	WHERE id = $1 AND parent_asset_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM asset_parents WHERE asset_id = $1)
+	ORDER BY position
+	`, assetID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal feature data: %w", err)
+		return nil, fmt.Errorf("failed to query asset parents: %w", err)
 	}
+	defer rows.Close()
 
-	// Convert computation params to JSON (may be nil)
-	var computationParamsJSON []byte
-	if len(feature.ComputationParams) > 0 {
-		computationParamsJSON, err = json.Marshal(feature.ComputationParams)
+	for rows.Next() {
+		var parentID string
+		var position int
+		if err = rows.Scan(&parentID, &position); err != nil {
+			return nil, fmt.Errorf("failed to scan asset parent: %w", err)
+		}
+		parentIDs = append(parentIDs, parentID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read asset parent rows: %w", err)
+	}
+	return parentIDs, nil
+}
+
+// ErrAssetNotFound is returned when an update targets an asset that doesn't exist
+var ErrAssetNotFound = errors.New("asset not found")
+
+// SoftDeleteAsset retires an asset by setting deleted_at, keeping the row and
+// its features for history. Soft-deleted assets are excluded from queries
+// unless IncludeDeleted is passed. Deleting an asset that is already deleted
+// returns ErrAssetNotFound.
+func (c *Client) SoftDeleteAsset(assetID string) error {
+	return c.updateAsset(context.Background(), "SoftDeleteAsset",
+		"UPDATE assets SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", assetID)
+}
+
+// ErrContentHashMismatch is returned by UpdateAsset when the record's content
+// hash differs from the stored one. Content hashes identify the audio itself,
+// so correcting a record must never change them.
+var ErrContentHashMismatch = errors.New("content hash does not match the stored asset")
+
+// UpdateAssetFilePath points an asset at a new location, e.g. after the file
+// was moved. Only file_path is changed.
+func (c *Client) UpdateAssetFilePath(assetID, newPath string) error {
+	return c.updateAsset(context.Background(), "UpdateAssetFilePath",
+		"UPDATE assets SET file_path = $2 WHERE id = $1", assetID, newPath)
+}
+
+// ErrLineageCycle is returned by UpdateAsset when the new parent is the asset
+// itself or one of its descendants, which would make the asset its own ancestor
+var ErrLineageCycle = errors.New("parent would make the asset its own ancestor")
+
+// lineageLockID is the Postgres advisory lock key held while an asset's new
+// parent is checked and stored, so two updates can't each pass the check and
+// close a cycle between them
+const lineageLockID = 7_341_002
+
+// UpdateAsset corrects the workflow IDs, parent, and file path of an existing
+// asset. asset.ContentHash must match the stored hash, otherwise
+// ErrContentHashMismatch is returned and nothing is changed. A parent that is
+// the asset itself or one of its descendants is rejected with ErrLineageCycle.
+func (c *Client) UpdateAsset(asset *Asset) (err error) {
+	if asset.ParentAssetID != nil && *asset.ParentAssetID == asset.ID {
+		return fmt.Errorf("asset %s: %w", asset.ID, ErrLineageCycle)
+	}
+
+	ctx, span := startSpan(context.Background(), "UpdateAsset")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
 		if err != nil {
-			return fmt.Errorf("failed to marshal computation params: %w", err)
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+			}
+		}
+	}()
+
+	if asset.ParentAssetID != nil {
+		// Held until the transaction ends
+		if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lineageLockID); err != nil {
+			return fmt.Errorf("failed to acquire lineage lock: %w", err)
+		}
+		var descendant bool
+		err = tx.QueryRowContext(ctx, descendantsCTE+`
+		SELECT EXISTS(SELECT 1 FROM lineage WHERE id = $2)`, asset.ID, *asset.ParentAssetID).Scan(&descendant)
+		if err != nil {
+			return fmt.Errorf("failed to check descendants of asset %s: %w", asset.ID, err)
+		}
+		if descendant {
+			err = fmt.Errorf("asset %s: parent %s is its descendant: %w", asset.ID, *asset.ParentAssetID, ErrLineageCycle)
+			return err
 		}
 	}
 
 	query := `
-	INSERT INTO features (id, asset_id, feature_type, feature_data, computation_params, computed_at)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	UPDATE assets
+	SET workflow_id = $2, workflow_run_id = $3, parent_asset_id = $4, file_path = $5
+	WHERE id = $1 AND content_hash = $6
 	`
+	err = execUpdate(ctx, tx, query,
+		asset.ID,
+		asset.WorkflowID,
+		asset.WorkflowRunID,
+		asset.ParentAssetID,
+		asset.FilePath,
+		asset.ContentHash,
+	)
+	if errors.Is(err, ErrAssetNotFound) {
+		// Nothing matched: tell a missing asset apart from a changed hash
+		var exists bool
+		if err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM assets WHERE id = $1)", asset.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check asset %s: %w", asset.ID, err)
+		}
+		if exists {
+			err = fmt.Errorf("asset %s: %w", asset.ID, ErrContentHashMismatch)
+		} else {
+			err = fmt.Errorf("asset %s: %w", asset.ID, ErrAssetNotFound)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	var computationParams interface{}
-	if len(computationParamsJSON) > 0 {
-		computationParams = string(computationParamsJSON)
+// execer runs a statement on the database or inside a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// updateAsset runs an UPDATE whose first argument is the asset ID, returning
+// ErrAssetNotFound if no row was changed
+func (c *Client) updateAsset(ctx context.Context, operation, query string, args ...interface{}) error {
+	ctx, span := startSpan(ctx, operation)
+	defer span.End()
+
+	err := execUpdate(ctx, c.DB, query, args...)
+	if err != nil && !errors.Is(err, ErrAssetNotFound) {
+		recordSpanError(span, err)
 	}
+	return err
+}
+
+// execUpdate runs updateAsset's UPDATE on db
+func execUpdate(ctx context.Context, db execer, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update asset: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update asset: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("asset %v: %w", args[0], ErrAssetNotFound)
+	}
+	return nil
+}
+
+// InsertFeature inserts a new feature record into the database
+func (c *Client) InsertFeature(feature *Feature) error {
+	return c.InsertFeatureContext(context.Background(), feature)
+}
+
+// InsertFeatureContext is like InsertFeature but traces the insert as a child of ctx
+func (c *Client) InsertFeatureContext(ctx context.Context, feature *Feature) error {
+	featureData, computationParams, err := featureJSON(feature)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO features (id, asset_id, feature_type, feature_data, computation_params, computed_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
 
-	_, err = c.DB.Exec(
+	return c.execContext(
+		ctx,
+		"InsertFeature",
 		query,
 		feature.ID,
 		feature.AssetID,
 		feature.FeatureType,
-		string(featureDataJSON),
+		featureData,
 		computationParams,
 		feature.ComputedAt,
 	)
+}
+
+// InsertActivityRun records one execution attempt of an activity
+func (c *Client) InsertActivityRun(ctx context.Context, run *ActivityRun) error {
+	query := `
+	INSERT INTO activity_runs (id, workflow_id, workflow_run_id, activity_id, activity_type, attempt, started_at, duration_ms, succeeded, error_message)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+	`
+
+	return c.execContext(
+		ctx,
+		"InsertActivityRun",
+		query,
+		run.ID,
+		run.WorkflowID,
+		run.WorkflowRunID,
+		run.ActivityID,
+		run.ActivityType,
+		run.Attempt,
+		run.StartedAt,
+		float64(run.Duration)/float64(time.Millisecond),
+		run.Succeeded,
+		run.ErrorMessage,
+	)
+}
+
+// InsertFeatures inserts many feature records at once, e.g. per-frame values
+// from a profile activity. The rows are streamed with a single COPY inside a
+// transaction rather than one INSERT round trip each, and either all of them
+// are stored or none are.
+func (c *Client) InsertFeatures(features []*Feature) error {
+	return c.InsertFeaturesContext(context.Background(), features)
+}
+
+// InsertFeaturesContext is like InsertFeatures but traces the insert as a child of ctx
+func (c *Client) InsertFeaturesContext(ctx context.Context, features []*Feature) (err error) {
+	if len(features) == 0 {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "InsertFeatures")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+			}
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("features",
+		"id", "asset_id", "feature_type", "feature_data", "computation_params", "computed_at"))
+	if err != nil {
+		return fmt.Errorf("failed to start feature copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, feature := range features {
+		featureData, computationParams, jsonErr := featureJSON(feature)
+		if jsonErr != nil {
+			return jsonErr
+		}
+		_, err = stmt.ExecContext(ctx,
+			feature.ID,
+			feature.AssetID,
+			feature.FeatureType,
+			featureData,
+			computationParams,
+			feature.ComputedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy feature: %w", err)
+		}
+	}
+
+	// An Exec without arguments flushes the buffered rows
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to insert features: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit features: %w", err)
+	}
+	return nil
+}
+
+// featureJSON encodes a feature's data and optional computation params for
+// their JSONB columns. params is nil (NULL) when there are none.
+func featureJSON(feature *Feature) (data string, params interface{}, err error) {
+	featureDataJSON, err := json.Marshal(feature.FeatureData)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal feature data: %w", err)
+	}
+
+	if len(feature.ComputationParams) > 0 {
+		var computationParamsJSON []byte
+		computationParamsJSON, err = json.Marshal(feature.ComputationParams)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal computation params: %w", err)
+		}
+		params = string(computationParamsJSON)
+	}
+	return string(featureDataJSON), params, nil
+}
+
+// ErrFeatureNotFound is returned when an asset has no feature of the requested type
+var ErrFeatureNotFound = errors.New("feature not found")
+
+// CompareFeatures compares two computations of the same feature, e.g. before
+// and after reprocessing with new parameters. It picks the feature rows of the
+// asset computed nearest to a and to b and returns, for every top-level numeric
+// field present in both, the value at b minus the value at a. Non-numeric
+// fields and fields missing from either row are left out.
+func (c *Client) CompareFeatures(assetID, featureType string, a, b time.Time) (deltas map[string]float64, err error) {
+	ctx, span := startSpan(context.Background(), "CompareFeatures")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	before, err := c.nearestFeatureData(ctx, assetID, featureType, a)
+	if err != nil {
+		return nil, err
+	}
+	after, err := c.nearestFeatureData(ctx, assetID, featureType, b)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas = make(map[string]float64)
+	for field, value := range before {
+		beforeValue, ok := value.(float64)
+		if !ok {
+			continue
+		}
+		afterValue, ok := after[field].(float64)
+		if !ok {
+			continue
+		}
+		deltas[field] = afterValue - beforeValue
+	}
+	return deltas, nil
+}
+
+// nearestFeatureData returns the feature_data of the asset's feature of the
+// given type computed closest to t
+func (c *Client) nearestFeatureData(ctx context.Context, assetID, featureType string, t time.Time) (map[string]interface{}, error) {
+	// computed_at is stored without a time zone, so compare t the same way
+	query := `
+	SELECT feature_data FROM features
+	WHERE asset_id = $1 AND feature_type = $2
+	ORDER BY ABS(EXTRACT(EPOCH FROM computed_at - $3::timestamp)), computed_at
+	LIMIT 1
+	`
+
+	var raw []byte
+	err := c.DB.QueryRowContext(ctx, query, assetID, featureType, t).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s feature of asset %s: %w", featureType, assetID, ErrFeatureNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s feature of asset %s: %w", featureType, assetID, err)
+	}
+
+	var data map[string]interface{}
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s feature of asset %s: %w", featureType, assetID, err)
+	}
+	return data, nil
+}
+
+// GetFeatureByHashAndType returns the most recent feature of the given type
+// computed with exactly params for any asset whose content hash is
+// contentHash, or ErrFeatureNotFound. Params are compared as JSONB, so key
+// order doesn't matter. Features of soft-deleted assets are skipped unless
+// IncludeDeleted is passed.
+func (c *Client) GetFeatureByHashAndType(ctx context.Context, contentHash, featureType string, params map[string]interface{}, opts ...QueryOption) (feature *Feature, err error) {
+	ctx, span := startSpan(ctx, "GetFeatureByHashAndType")
+	defer span.End()
+	defer func() {
+		if !errors.Is(err, ErrFeatureNotFound) {
+			recordSpanError(span, err)
+		}
+	}()
+
+	var paramsJSON interface{}
+	if len(params) > 0 {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal computation params: %w", err)
+		}
+		paramsJSON = string(raw)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT f.id, f.asset_id, f.feature_type, f.feature_data, f.computation_params, f.computed_at
+	FROM features f
+	JOIN assets a ON a.id = f.asset_id
+	WHERE a.content_hash = $1 AND f.feature_type = $2
+	AND f.computation_params IS NOT DISTINCT FROM $3::jsonb AND %s
+	ORDER BY f.computed_at DESC
+	LIMIT 1
+	`, notDeletedFilter("a", opts))
+
+	feature, err = scanFeature(c.DB.QueryRowContext(ctx, query, contentHash, featureType, paramsJSON))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s feature of content %s: %w", featureType, contentHash, ErrFeatureNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s feature of content %s: %w", featureType, contentHash, err)
+	}
+	return feature, nil
+}
+
+// GetFeaturesByAssetID returns every feature stored for an asset, grouped by
+// feature type and oldest first within a type
+func (c *Client) GetFeaturesByAssetID(ctx context.Context, assetID string) (features []*Feature, err error) {
+	ctx, span := startSpan(ctx, "GetFeaturesByAssetID")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	rows, err := c.DB.QueryContext(ctx, `
+	SELECT `+featureColumns+` FROM features
+	WHERE asset_id = $1
+	ORDER BY feature_type, computed_at
+	`, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query features of asset %s: %w", assetID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		feature, err := scanFeature(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feature of asset %s: %w", assetID, err)
+		}
+		features = append(features, feature)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feature rows: %w", err)
+	}
+	return features, nil
+}
+
+// featureColumns is the column list selected into a Feature by scanFeature
+const featureColumns = "id, asset_id, feature_type, feature_data, computation_params, computed_at"
+
+// scanFeature reads a single row selected with featureColumns, decoding its
+// JSONB columns
+func scanFeature(row rowScanner) (*Feature, error) {
+	var feature Feature
+	var featureData, computationParams []byte
+	err := row.Scan(
+		&feature.ID,
+		&feature.AssetID,
+		&feature.FeatureType,
+		&featureData,
+		&computationParams,
+		&feature.ComputedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(featureData, &feature.FeatureData); err != nil {
+		return nil, fmt.Errorf("failed to decode %s feature %s: %w", feature.FeatureType, feature.ID, err)
+	}
+	if computationParams != nil {
+		if err = json.Unmarshal(computationParams, &feature.ComputationParams); err != nil {
+			return nil, fmt.Errorf("failed to decode computation params of feature %s: %w", feature.ID, err)
+		}
+	}
+	return &feature, nil
+}
+
+// assetColumns is the column list selected into an Asset by scanAsset
+const assetColumns = "id, workflow_id, workflow_run_id, parent_asset_id, file_path, content_hash, created_at, deleted_at, dataset_id"
+
+// QueryOption adjusts which assets a query returns
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	includeDeleted bool
+}
+
+// IncludeDeleted makes a query return soft-deleted assets too
+func IncludeDeleted() QueryOption {
+	return func(o *queryOptions) {
+		o.includeDeleted = true
+	}
+}
+
+// notDeletedFilter returns a SQL condition excluding soft-deleted rows of the
+// given table alias (empty for none), or "TRUE" if IncludeDeleted was passed
+func notDeletedFilter(alias string, opts []QueryOption) string {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.includeDeleted {
+		return "TRUE"
+	}
+	if alias != "" {
+		return alias + ".deleted_at IS NULL"
+	}
+	return "deleted_at IS NULL"
+}
+
+// assetOrderColumns maps the orderBy values accepted by ListAssets to columns.
+// orderBy is interpolated into the query, so only these values are allowed.
+var assetOrderColumns = map[string]string{
+	"created_at":   "created_at",
+	"file_path":    "file_path",
+	"content_hash": "content_hash",
+	"workflow_id":  "workflow_id",
+}
+
+// ListAssets returns a page of assets. orderBy is a column name with an optional
+// " ASC" or " DESC" suffix (e.g. "file_path ASC"); empty means created_at DESC.
+func (c *Client) ListAssets(limit, offset int, orderBy string, opts ...QueryOption) ([]*Asset, error) {
+	orderClause, err := assetOrderClause(orderBy)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive, got %d", limit)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+	}
+
+	ctx, span := startSpan(context.Background(), "ListAssets")
+	defer span.End()
+
+	// #nosec G201 -- orderClause is built from the assetOrderColumns allowlist
+	query := fmt.Sprintf("SELECT %s FROM assets WHERE %s ORDER BY %s, id LIMIT $1 OFFSET $2",
+		assetColumns, notDeletedFilter("", opts), orderClause)
+	assets, err := c.queryAssets(ctx, query, limit, offset)
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, fmt.Errorf("failed to list assets: %w", err)
+	}
+	return assets, nil
+}
+
+// CountAssets returns the total number of assets, for paginating ListAssets
+func (c *Client) CountAssets(opts ...QueryOption) (int, error) {
+	ctx, span := startSpan(context.Background(), "CountAssets")
+	defer span.End()
+
+	var count int
+	query := "SELECT COUNT(*) FROM assets WHERE " + notDeletedFilter("", opts)
+	if err := c.DB.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		recordSpanError(span, err)
+		return 0, fmt.Errorf("failed to count assets: %w", err)
+	}
+	return count, nil
+}
+
+// descendantsCTE defines lineage as every descendant of the asset $1, with
+// its depth below it. Each row carries the path of IDs leading to it, and the
+// recursion stops at an asset already on its path, so a parent_asset_id cycle
+// (which nothing should create, but a hand edit can) ends the query instead of
+// recursing forever.
+const descendantsCTE = `
+	WITH RECURSIVE lineage AS (
+		SELECT id, 1 AS depth, ARRAY[parent_asset_id, id] AS path
+		FROM assets WHERE parent_asset_id = $1 AND id <> $1
+		UNION ALL
+		SELECT a.id, l.depth + 1, l.path || a.id
+		FROM assets a JOIN lineage l ON a.parent_asset_id = l.id
+		WHERE NOT a.id = ANY(l.path)
+	)`
+
+// GetAssetLineage returns the ancestors of an asset (nearest parent first, ending
+// at the raw asset) and all of its descendants (breadth-first by depth) by
+// following parent_asset_id. The asset itself is not included in either list.
+// Soft-deleted assets are still followed but left out of the results. Both
+// walks stop where parent_asset_id loops back on itself.
+func (c *Client) GetAssetLineage(assetID string, opts ...QueryOption) (ancestors []*Asset, descendants []*Asset, err error) {
+	ctx, span := startSpan(context.Background(), "GetAssetLineage")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	// The path holds the assets already visited; a parent already on it
+	// closes a cycle and is neither returned nor followed
+	ancestorsQuery := fmt.Sprintf(`
+	WITH RECURSIVE lineage AS (
+		SELECT parent_asset_id, 1 AS depth, ARRAY[id] AS path FROM assets WHERE id = $1
+		UNION ALL
+		SELECT a.parent_asset_id, l.depth + 1, l.path || a.id
+		FROM assets a JOIN lineage l ON a.id = l.parent_asset_id
+		WHERE NOT a.id = ANY(l.path)
+	)
+	SELECT %s FROM assets a JOIN lineage l ON a.id = l.parent_asset_id
+	WHERE NOT a.id = ANY(l.path) AND %s
+	ORDER BY l.depth
+	`, prefixColumns("a", assetColumns), notDeletedFilter("a", opts))
+
+	ancestors, err = c.queryAssets(ctx, ancestorsQuery, assetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query asset ancestors: %w", err)
+	}
+
+	descendantsQuery := fmt.Sprintf(descendantsCTE+`
+	SELECT %s FROM assets a JOIN lineage l ON a.id = l.id
+	WHERE %s
+	ORDER BY l.depth, a.created_at
+	`, prefixColumns("a", assetColumns), notDeletedFilter("a", opts))
+
+	descendants, err = c.queryAssets(ctx, descendantsQuery, assetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query asset descendants: %w", err)
+	}
+
+	return ancestors, descendants, nil
+}
+
+// queryAssets runs a query selecting assetColumns and scans the results
+func (c *Client) queryAssets(ctx context.Context, query string, args ...interface{}) ([]*Asset, error) {
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAssets(rows)
+}
+
+// prefixColumns qualifies each column in a comma-separated list with a table alias
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, part := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GetRootAssetByContentHash returns the earliest ingested (parentless) asset
+// with the given content hash in the dataset, or nil if there is none
+func (c *Client) GetRootAssetByContentHash(ctx context.Context, datasetID, contentHash string, opts ...QueryOption) (*Asset, error) {
+	ctx, span := startSpan(ctx, "GetRootAssetByContentHash")
+	defer span.End()
+
+	query := fmt.Sprintf(`
+	SELECT %s FROM assets
+	WHERE content_hash = $1 AND dataset_id = $2 AND parent_asset_id IS NULL AND %s
+	ORDER BY created_at
+	LIMIT 1
+	`, assetColumns, notDeletedFilter("", opts))
+
+	asset, err := scanAsset(c.DB.QueryRowContext(ctx, query, contentHash, datasetID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		recordSpanError(span, err)
+		return nil, err
+	}
+	return asset, nil
+}
+
+// assetOrderClause validates orderBy against the allowlist and returns the ORDER BY expression
+func assetOrderClause(orderBy string) (string, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		return "created_at DESC", nil
+	}
+
+	fields := strings.Fields(orderBy)
+	column, ok := assetOrderColumns[strings.ToLower(fields[0])]
+	if !ok || len(fields) > 2 {
+		return "", fmt.Errorf("invalid orderBy %q", orderBy)
+	}
+
+	direction := "ASC"
+	if len(fields) == 2 {
+		direction = strings.ToUpper(fields[1])
+		if direction != "ASC" && direction != "DESC" {
+			return "", fmt.Errorf("invalid orderBy direction %q", fields[1])
+		}
+	}
+	return column + " " + direction, nil
+}
+
+// scanAssets reads all rows selected with assetColumns
+func scanAssets(rows *sql.Rows) ([]*Asset, error) {
+	var assets []*Asset
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read asset rows: %w", err)
+	}
+	return assets, nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAsset reads a single row selected with assetColumns
+func scanAsset(row rowScanner) (*Asset, error) {
+	var asset Asset
+	var parentAssetID sql.NullString
+	var deletedAt sql.NullTime
+	err := row.Scan(
+		&asset.ID,
+		&asset.WorkflowID,
+		&asset.WorkflowRunID,
+		&parentAssetID,
+		&asset.FilePath,
+		&asset.ContentHash,
+		&asset.CreatedAt,
+		&deletedAt,
+		&asset.DatasetID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan asset: %w", err)
+	}
+	if parentAssetID.Valid {
+		asset.ParentAssetID = &parentAssetID.String
+	}
+	if deletedAt.Valid {
+		asset.DeletedAt = &deletedAt.Time
+	}
+	return &asset, nil
+}
+
+// execContext runs a statement inside a span named after the operation
+func (c *Client) execContext(ctx context.Context, operation, query string, args ...interface{}) error {
+	ctx, span := startSpan(ctx, operation)
+	defer span.End()
 
+	_, err := c.DB.ExecContext(ctx, query, args...)
+	recordSpanError(span, err)
 	return err
 }
 
-// Close closes the database connection
+// startSpan starts a client span for a database operation
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+		),
+	)
+}
+
+// recordSpanError marks the span as failed if err is non-nil
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Ping verifies the database connection is alive
+func (c *Client) Ping(ctx context.Context) error {
+	return c.DB.PingContext(ctx)
+}
+
+// Close closes the database connection. The client is owned by whoever
+// created it with NewClient, which closes it once nothing uses it any more;
+// further calls return the result of the first.
 func (c *Client) Close() error {
-	return c.DB.Close()
+	c.closeOnce.Do(func() {
+		c.closeErr = c.DB.Close()
+	})
+	return c.closeErr
 }
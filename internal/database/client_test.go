@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pphelan007/davidAI/internal/config"
+)
+
+// newMockClient returns a client on a sqlmock connection. Statements the test
+// didn't expect fail, and the expectations are checked when the test ends.
+func newMockClient(t *testing.T) (*Client, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return &Client{DB: db}, mock
+}
+
+// newTestClient connects to the Postgres database the DB_* variables point
+// at, migrating it first. Tests using it are skipped unless TEST_DATABASE is
+// set; CI runs them against a throwaway database.
+func newTestClient(tb testing.TB) *Client {
+	tb.Helper()
+	if os.Getenv("TEST_DATABASE") == "" {
+		tb.Skip("set TEST_DATABASE=1 and the DB_* variables to run against Postgres")
+	}
+	cfg, err := config.Load()
+	require.NoError(tb, err)
+	client, err := NewClient(&cfg.Database)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { client.Close() })
+	return client
+}
+
+// insertTestAsset stores an asset with a new ID under parentID, or a root
+// asset if it is nil, removed when the test ends
+func insertTestAsset(tb testing.TB, client *Client, parentID *string) *Asset {
+	tb.Helper()
+	asset := &Asset{
+		ID:            uuid.NewString(),
+		WorkflowID:    "workflow",
+		WorkflowRunID: "run",
+		ParentAssetID: parentID,
+		FilePath:      "raw/original.wav",
+		ContentHash:   "original-hash",
+		CreatedAt:     time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(tb, client.InsertAsset(asset))
+	tb.Cleanup(func() {
+		_, err := client.DB.Exec("DELETE FROM assets WHERE id = $1", asset.ID)
+		assert.NoError(tb, err)
+	})
+	return asset
+}
+
+func TestUpdateAssetNoMatch(t *testing.T) {
+	update := regexp.QuoteMeta("WHERE id = $1 AND content_hash = $6")
+	exists := regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM assets WHERE id = $1)")
+	asset := &Asset{ID: "asset", WorkflowID: "workflow", WorkflowRunID: "run", FilePath: "moved.wav", ContentHash: "changed-hash"}
+
+	tests := []struct {
+		name   string
+		exists bool
+		want   error
+	}{
+		{name: "content hash changed", exists: true, want: ErrContentHashMismatch},
+		{name: "asset missing", exists: false, want: ErrAssetNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mock := newMockClient(t)
+			// The update only matches the stored hash, and nothing is
+			// committed once it matched no row
+			mock.ExpectBegin()
+			mock.ExpectExec(update).
+				WithArgs(asset.ID, asset.WorkflowID, asset.WorkflowRunID, nil, asset.FilePath, asset.ContentHash).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(exists).WithArgs(asset.ID).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+			mock.ExpectRollback()
+
+			err := client.UpdateAsset(asset)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestUpdateAssetRejectsOwnParent(t *testing.T) {
+	// Rejected before anything is sent to the database
+	client, _ := newMockClient(t)
+	asset := &Asset{ID: "asset", FilePath: "raw/original.wav", ContentHash: "original-hash"}
+	asset.ParentAssetID = &asset.ID
+	assert.ErrorIs(t, client.UpdateAsset(asset), ErrLineageCycle)
+}
+
+func TestUpdateAssetRejectsDescendantParent(t *testing.T) {
+	client, mock := newMockClient(t)
+	parentID := "grandchild"
+	asset := &Asset{ID: "asset", ParentAssetID: &parentID, FilePath: "raw/original.wav", ContentHash: "original-hash"}
+
+	// The descendants are checked under the lineage lock in the update's
+	// transaction, and the update is never run
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lineageLockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM lineage WHERE id = $2)")).
+		WithArgs(asset.ID, parentID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, client.UpdateAsset(asset), ErrLineageCycle)
+}
+
+func TestUpdateAssetRejectsCycles(t *testing.T) {
+	client := newTestClient(t)
+	root := insertTestAsset(t, client, nil)
+	child := insertTestAsset(t, client, &root.ID)
+	grandchild := insertTestAsset(t, client, &child.ID)
+
+	for _, parent := range []*Asset{root, grandchild} {
+		reparented := *root
+		reparented.ParentAssetID = &parent.ID
+		require.ErrorIs(t, client.UpdateAsset(&reparented), ErrLineageCycle)
+	}
+
+	var parentID *string
+	require.NoError(t, client.DB.QueryRow("SELECT parent_asset_id FROM assets WHERE id = $1", root.ID).Scan(&parentID))
+	assert.Nil(t, parentID)
+
+	// Moving the grandchild up to the root is fine
+	moved := *grandchild
+	moved.ParentAssetID = &root.ID
+	require.NoError(t, client.UpdateAsset(&moved))
+}
+
+func TestUpdateAssetRejectsContentHashChange(t *testing.T) {
+	client := newTestClient(t)
+	stored := insertTestAsset(t, client, nil)
+
+	changed := *stored
+	changed.WorkflowID = "other-workflow"
+	changed.FilePath = "raw/moved.wav"
+	changed.ContentHash = "changed-hash"
+	require.ErrorIs(t, client.UpdateAsset(&changed), ErrContentHashMismatch)
+
+	var workflowID, filePath, contentHash string
+	err := client.DB.QueryRow("SELECT workflow_id, file_path, content_hash FROM assets WHERE id = $1", stored.ID).
+		Scan(&workflowID, &filePath, &contentHash)
+	require.NoError(t, err)
+	assert.Equal(t, stored.WorkflowID, workflowID)
+	assert.Equal(t, stored.FilePath, filePath)
+	assert.Equal(t, stored.ContentHash, contentHash)
+
+	// The same update with the stored hash goes through
+	changed.ContentHash = stored.ContentHash
+	require.NoError(t, client.UpdateAsset(&changed))
+}
+
+// assetIDs returns the IDs of assets in order
+func assetIDs(assets []*Asset) []string {
+	ids := make([]string, len(assets))
+	for i, asset := range assets {
+		ids[i] = asset.ID
+	}
+	return ids
+}
+
+func TestGetAssetLineageStopsAtCycle(t *testing.T) {
+	client := newTestClient(t)
+	a := insertTestAsset(t, client, nil)
+	b := insertTestAsset(t, client, &a.ID)
+	c := insertTestAsset(t, client, &b.ID)
+
+	// Close the loop A→B→A behind the client's back, and open it again
+	// before the assets are removed
+	_, err := client.DB.Exec("UPDATE assets SET parent_asset_id = $2 WHERE id = $1", a.ID, b.ID)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := client.DB.Exec("UPDATE assets SET parent_asset_id = NULL WHERE id = $1", a.ID)
+		assert.NoError(t, err)
+	})
+
+	type lineage struct {
+		ancestors, descendants []*Asset
+		err                    error
+	}
+	done := make(chan lineage, 1)
+	go func() {
+		var result lineage
+		result.ancestors, result.descendants, result.err = client.GetAssetLineage(a.ID)
+		done <- result
+	}()
+	select {
+	case result := <-done:
+		require.NoError(t, result.err)
+		assert.Equal(t, []string{b.ID}, assetIDs(result.ancestors))
+		assert.Equal(t, []string{b.ID, c.ID}, assetIDs(result.descendants))
+	case <-time.After(10 * time.Second):
+		t.Fatal("GetAssetLineage didn't return on a parent cycle")
+	}
+}
+
+// TestConcurrentInserts stores derived assets and their features from many
+// goroutines through one client, as concurrent activities do. CI runs it
+// under -race.
+func TestConcurrentInserts(t *testing.T) {
+	const (
+		goroutines = 16
+		batch      = 50
+	)
+	client := newTestClient(t)
+	root := insertTestAsset(t, client, nil)
+	// Runs before the root is removed, which the children reference
+	t.Cleanup(func() {
+		_, err := client.DB.Exec("DELETE FROM assets WHERE parent_asset_id = $1", root.ID)
+		assert.NoError(t, err)
+	})
+
+	children := make([]string, goroutines)
+	var wg sync.WaitGroup
+	for i := range children {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			child := &Asset{
+				ID:            uuid.NewString(),
+				WorkflowID:    fmt.Sprintf("workflow-%d", i),
+				WorkflowRunID: "run",
+				ParentAssetID: &root.ID,
+				FilePath:      fmt.Sprintf("processed/child-%d.wav", i),
+				ContentHash:   fmt.Sprintf("child-hash-%d", i),
+				CreatedAt:     time.Now(),
+			}
+			if !assert.NoError(t, client.InsertAssetContext(ctx, child)) {
+				return
+			}
+			children[i] = child.ID
+
+			assert.NoError(t, client.InsertFeatureContext(ctx, &Feature{
+				ID:          uuid.NewString(),
+				AssetID:     child.ID,
+				FeatureType: "snr",
+				FeatureData: map[string]interface{}{"snr": 40.0},
+				ComputedAt:  time.Now(),
+			}))
+			features := make([]*Feature, batch)
+			for j := range features {
+				features[j] = &Feature{
+					ID:          uuid.NewString(),
+					AssetID:     child.ID,
+					FeatureType: "rms_frame",
+					FeatureData: map[string]interface{}{"frame": j},
+					ComputedAt:  time.Now(),
+				}
+			}
+			assert.NoError(t, client.InsertFeaturesContext(ctx, features))
+		}()
+	}
+	wg.Wait()
+	require.False(t, t.Failed())
+
+	var assets int
+	require.NoError(t, client.DB.QueryRow("SELECT COUNT(*) FROM assets WHERE parent_asset_id = $1", root.ID).Scan(&assets))
+	assert.Equal(t, goroutines, assets)
+	for _, child := range children {
+		parents, err := client.GetAssetParents(context.Background(), child)
+		require.NoError(t, err)
+		assert.Equal(t, []string{root.ID}, parents)
+
+		var snr, frames int
+		err = client.DB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE feature_type = 'snr'), COUNT(*) FILTER (WHERE feature_type = 'rms_frame')
+		FROM features WHERE asset_id = $1`, child).Scan(&snr, &frames)
+		require.NoError(t, err)
+		assert.Equal(t, 1, snr)
+		assert.Equal(t, batch, frames)
+	}
+}
+
+// BenchmarkInsertFeatures compares storing a profile's worth of feature rows
+// with InsertFeatures' single COPY against one InsertFeature each
+func BenchmarkInsertFeatures(b *testing.B) {
+	const rows = 10_000
+	client := newTestClient(b)
+	asset := insertTestAsset(b, client, nil)
+	features := make([]*Feature, rows)
+	for i := range features {
+		features[i] = &Feature{
+			AssetID:     asset.ID,
+			FeatureType: "rms_frame",
+			FeatureData: map[string]interface{}{"frame": i, "rms": 0.25},
+			ComputedAt:  time.Now(),
+		}
+	}
+
+	inserts := []struct {
+		name   string
+		insert func() error
+	}{
+		{name: "copy", insert: func() error { return client.InsertFeatures(features) }},
+		{name: "per-row", insert: func() error {
+			for _, feature := range features {
+				if err := client.InsertFeature(feature); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+	for _, bm := range inserts {
+		b.Run(fmt.Sprintf("%s/%d", bm.name, rows), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for _, feature := range features {
+					feature.ID = uuid.NewString()
+				}
+				_, err := client.DB.Exec("DELETE FROM features WHERE asset_id = $1", asset.ID)
+				require.NoError(b, err)
+				b.StartTimer()
+
+				require.NoError(b, bm.insert())
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Formats accepted by ExportAssetFeatures
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// exportedFeature is the JSON form of a feature written by ExportAssetFeatures
+type exportedFeature struct {
+	ID                string                 `json:"id"`
+	FeatureType       string                 `json:"feature_type"`
+	ComputedAt        time.Time              `json:"computed_at"`
+	ComputationParams map[string]interface{} `json:"computation_params,omitempty"`
+	FeatureData       map[string]interface{} `json:"feature_data"`
+}
+
+// ExportAssetFeatures writes every feature stored for an asset to w, for
+// sharing outside the database. ExportFormatJSON writes one object with the
+// asset ID and the features as stored. ExportFormatCSV writes one section per
+// feature type, separated by a blank line, since feature types don't share a
+// schema: each section has its own header row and one row per computation,
+// with feature_data flattened into columns (nested objects as dotted names,
+// arrays as JSON text) and the computation params in "param." columns. Cells
+// for fields a row doesn't have are left empty. An asset without features
+// returns ErrFeatureNotFound.
+func (c *Client) ExportAssetFeatures(ctx context.Context, assetID string, w io.Writer, format string) error {
+	if format != ExportFormatCSV && format != ExportFormatJSON {
+		return fmt.Errorf("invalid export format %q: must be %q or %q", format, ExportFormatCSV, ExportFormatJSON)
+	}
+
+	features, err := c.GetFeaturesByAssetID(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	if len(features) == 0 {
+		return fmt.Errorf("features of asset %s: %w", assetID, ErrFeatureNotFound)
+	}
+
+	if format == ExportFormatJSON {
+		return exportFeaturesJSON(assetID, features, w)
+	}
+	return exportFeaturesCSV(features, w)
+}
+
+func exportFeaturesJSON(assetID string, features []*Feature, w io.Writer) error {
+	exported := make([]exportedFeature, len(features))
+	for i, feature := range features {
+		exported[i] = exportedFeature{
+			ID:                feature.ID,
+			FeatureType:       feature.FeatureType,
+			ComputedAt:        feature.ComputedAt,
+			ComputationParams: feature.ComputationParams,
+			FeatureData:       feature.FeatureData,
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		AssetID  string            `json:"asset_id"`
+		Features []exportedFeature `json:"features"`
+	}{assetID, exported})
+}
+
+// exportFeaturesCSV writes features, already grouped by type, as one CSV
+// section per type
+func exportFeaturesCSV(features []*Feature, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	for start := 0; start < len(features); {
+		end := start
+		for end < len(features) && features[end].FeatureType == features[start].FeatureType {
+			end++
+		}
+		if start > 0 {
+			// Blank line between sections
+			if err := writer.Write(nil); err != nil {
+				return err
+			}
+		}
+		if err := writeCSVSection(writer, features[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeCSVSection writes the header and rows of features of a single type.
+// The columns are the union of the fields of every row, sorted by name.
+func writeCSVSection(writer *csv.Writer, features []*Feature) error {
+	rows := make([]map[string]string, len(features))
+	columnSet := make(map[string]bool)
+	for i, feature := range features {
+		row := make(map[string]string)
+		flattenFeatureFields(row, "", feature.FeatureData)
+		flattenFeatureFields(row, "param.", feature.ComputationParams)
+		for column := range row {
+			columnSet[column] = true
+		}
+		rows[i] = row
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	header := append([]string{"feature_type", "feature_id", "computed_at"}, columns...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i, feature := range features {
+		record := []string{feature.FeatureType, feature.ID, feature.ComputedAt.UTC().Format(time.RFC3339)}
+		for _, column := range columns {
+			record = append(record, rows[i][column])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flattenFeatureFields adds the fields of a decoded JSONB object to row, with
+// nested objects flattened into dotted names under prefix. Arrays stay whole,
+// as JSON text, so per-frame values don't turn into thousands of columns.
+func flattenFeatureFields(row map[string]string, prefix string, fields map[string]interface{}) {
+	for key, value := range fields {
+		name := prefix + key
+		switch value := value.(type) {
+		case map[string]interface{}:
+			flattenFeatureFields(row, name+".", value)
+		case nil:
+			row[name] = ""
+		case string:
+			row[name] = value
+		case bool:
+			row[name] = strconv.FormatBool(value)
+		case float64:
+			row[name] = strconv.FormatFloat(value, 'g', -1, 64)
+		default:
+			raw, err := json.Marshal(value)
+			if err != nil {
+				raw = []byte(fmt.Sprint(value))
+			}
+			row[name] = string(raw)
+		}
+	}
+}
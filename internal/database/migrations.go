@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migrationLockID is the Postgres advisory lock key held while a migration is
+// checked and applied, so workers starting together don't apply it twice
+const migrationLockID = 7_341_001
+
+// migration is a schema change applied once per database, in version order
+type migration struct {
+	version     int
+	description string
+	query       string
+}
+
+// migrations is the ordered schema history. Applied migrations must never be
+// edited; change the schema by appending a new version. Migration 1 keeps
+// IF NOT EXISTS so databases created before versioning adopt it cleanly.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		query: `
+		CREATE TABLE IF NOT EXISTS assets (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			workflow_id VARCHAR(255) NOT NULL,
+			workflow_run_id VARCHAR(255) NOT NULL,
+			parent_asset_id UUID REFERENCES assets(id),
+			file_path TEXT NOT NULL,
+			content_hash VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_workflow_run ON assets(workflow_run_id);
+		CREATE INDEX IF NOT EXISTS idx_parent_asset ON assets(parent_asset_id);
+		CREATE INDEX IF NOT EXISTS idx_content_hash ON assets(content_hash);
+		CREATE INDEX IF NOT EXISTS idx_workflow_id ON assets(workflow_id);
+
+		CREATE TABLE IF NOT EXISTS features (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+			feature_type VARCHAR(50) NOT NULL,
+			feature_data JSONB NOT NULL,
+			computation_params JSONB,
+			computed_at TIMESTAMP DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_features_asset ON features(asset_id);
+		CREATE INDEX IF NOT EXISTS idx_features_type ON features(feature_type);
+		CREATE INDEX IF NOT EXISTS idx_features_computed_at ON features(computed_at);
+		`,
+	},
+	{
+		version:     2,
+		description: "add assets.deleted_at for soft delete",
+		query:       `ALTER TABLE assets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`,
+	},
+	{
+		version:     3,
+		description: "add asset_parents for assets derived from several sources",
+		query: `
+		CREATE TABLE asset_parents (
+			asset_id UUID NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+			parent_asset_id UUID NOT NULL REFERENCES assets(id),
+			position INTEGER NOT NULL,
+			PRIMARY KEY (asset_id, position)
+		);
+
+		CREATE INDEX idx_asset_parents_parent ON asset_parents(parent_asset_id);
+		`,
+	},
+	{
+		version:     4,
+		description: "add assets.dataset_id to separate datasets sharing the database",
+		query: `
+		ALTER TABLE assets ADD COLUMN dataset_id VARCHAR(255) NOT NULL DEFAULT '';
+
+		CREATE INDEX idx_assets_dataset_content_hash ON assets(dataset_id, content_hash);
+		`,
+	},
+	{
+		version:     5,
+		description: "add activity_runs to audit every activity execution",
+		query: `
+		CREATE TABLE activity_runs (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			workflow_id VARCHAR(255) NOT NULL,
+			workflow_run_id VARCHAR(255) NOT NULL,
+			activity_id VARCHAR(255) NOT NULL,
+			activity_type VARCHAR(255) NOT NULL,
+			attempt INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			duration_ms DOUBLE PRECISION NOT NULL,
+			succeeded BOOLEAN NOT NULL,
+			error_message TEXT
+		);
+
+		CREATE INDEX idx_activity_runs_type_started ON activity_runs(activity_type, started_at);
+		CREATE INDEX idx_activity_runs_workflow_run ON activity_runs(workflow_run_id);
+		`,
+	},
+}
+
+// Migrate applies every migration that hasn't been recorded in the
+// schema_migrations table yet. Each migration runs in its own transaction
+// together with its bookkeeping row, so a failure leaves it unapplied.
+func (c *Client) Migrate(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "Migrate")
+	defer span.End()
+	defer func() { recordSpanError(span, err) }()
+
+	_, err = c.DB.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	for _, m := range migrations {
+		if err = c.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration applies m unless it is already recorded
+func (c *Client) applyMigration(ctx context.Context, m migration) error {
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Rolling back after a successful commit is a harmless ErrTxDone
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			log.Warn().Err(rollbackErr).Int("version", m.version).Msg("Failed to roll back migration")
+		}
+	}()
+
+	// Held until the transaction ends
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", m.version).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("failed to check migration status: %w", err)
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err = tx.ExecContext(ctx, m.query); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, description) VALUES ($1, $2)", m.version, m.description); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Info().Int("version", m.version).Str("description", m.description).Msg("Applied database migration")
+	return nil
+}
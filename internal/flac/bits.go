@@ -0,0 +1,154 @@
+package flac
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// bitWriter accumulates bits most significant first into a byte slice
+type bitWriter struct {
+	buf   []byte
+	cur   uint64 // pending bits, right-aligned
+	count uint   // number of pending bits, always below 8 between calls
+}
+
+// write appends the low n bits of v, for n up to 56
+func (w *bitWriter) write(v uint64, n uint) {
+	if n == 0 {
+		return
+	}
+	w.cur = w.cur<<n | v&(1<<n-1)
+	w.count += n
+	for w.count >= 8 {
+		w.count -= 8
+		w.buf = append(w.buf, byte(w.cur>>w.count))
+	}
+	w.cur &= 1<<w.count - 1
+}
+
+// writeSigned appends v as an n-bit two's complement number
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.write(uint64(v), n) // #nosec G115 -- two's complement bit pattern
+}
+
+// writeUnary appends q zero bits followed by a one bit
+func (w *bitWriter) writeUnary(q uint64) {
+	for q >= 32 {
+		w.write(0, 32)
+		q -= 32
+	}
+	w.write(1, uint(q)+1)
+}
+
+// align pads the pending bits with zeros to a byte boundary
+func (w *bitWriter) align() {
+	if w.count > 0 {
+		w.write(0, 8-w.count)
+	}
+}
+
+// bytes returns the written bytes; the writer must be aligned
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+// bitReader reads bits most significant first. It only consumes bytes from
+// the underlying reader as their bits are needed, and runs every consumed
+// byte through the frame's CRC-16, so a frame's checksum can be verified.
+type bitReader struct {
+	r     *bufio.Reader
+	cur   uint64 // buffered bits, right-aligned
+	count uint   // number of buffered bits
+	crc16 uint16 // CRC-16 of the bytes consumed since resetCRC
+	crc8  byte   // CRC-8 of the bytes consumed since resetCRC
+}
+
+func newBitReader(r io.Reader) *bitReader {
+	return &bitReader{r: bufio.NewReader(r)}
+}
+
+// resetCRC restarts both checksums, at the start of a frame
+func (br *bitReader) resetCRC() {
+	br.crc16 = 0
+	br.crc8 = 0
+}
+
+func (br *bitReader) fill() error {
+	b, err := br.r.ReadByte()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	br.crc8 = crc8Table[br.crc8^b]
+	br.crc16 = br.crc16<<8 ^ crc16Table[byte(br.crc16>>8)^b]
+	br.cur = br.cur<<8 | uint64(b)
+	br.count += 8
+	return nil
+}
+
+// read returns the next n bits, for n up to 56
+func (br *bitReader) read(n uint) (uint64, error) {
+	for br.count < n {
+		if err := br.fill(); err != nil {
+			return 0, err
+		}
+	}
+	br.count -= n
+	v := br.cur >> br.count & (1<<n - 1)
+	br.cur &= 1<<br.count - 1
+	return v, nil
+}
+
+// readSigned returns the next n bits as a two's complement number
+func (br *bitReader) readSigned(n uint) (int64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	v, err := br.read(n)
+	if err != nil {
+		return 0, err
+	}
+	shift := 64 - n
+	return int64(v<<shift) >> shift, nil // #nosec G115 -- sign extension of the bit pattern
+}
+
+// readUnary counts the zero bits before the next one bit, consuming all of them
+func (br *bitReader) readUnary() (uint64, error) {
+	var q uint64
+	for {
+		if br.count == 0 {
+			if err := br.fill(); err != nil {
+				return 0, err
+			}
+		}
+		if br.cur == 0 {
+			q += uint64(br.count)
+			br.count = 0
+			continue
+		}
+		zeros := uint(bits.LeadingZeros64(br.cur)) - (64 - br.count)
+		q += uint64(zeros)
+		br.count -= zeros + 1
+		br.cur &= 1<<br.count - 1
+		return q, nil
+	}
+}
+
+// align discards the bits left in the current byte
+func (br *bitReader) align() {
+	br.count -= br.count % 8
+	br.cur &= 1<<br.count - 1
+}
+
+// atEOF reports whether the stream ends at the current byte boundary
+func (br *bitReader) atEOF() bool {
+	if br.count > 0 {
+		return false
+	}
+	_, err := br.r.Peek(1)
+	return errors.Is(err, io.EOF)
+}
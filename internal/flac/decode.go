@@ -0,0 +1,531 @@
+package flac
+
+import (
+	"crypto/md5" // #nosec G501 -- STREAMINFO checksum defined by the format
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// Decoder reads the samples of a FLAC stream frame by frame
+type Decoder struct {
+	br   *bitReader
+	info StreamInfo
+
+	pending []int // decoded interleaved samples not yet returned by Read
+	done    bool
+
+	// md5 checks the decoded samples against STREAMINFO once the stream ends;
+	// nil if the encoder didn't record a checksum
+	md5      hash.Hash
+	md5Width int
+	decoded  int64 // samples per channel decoded so far
+}
+
+// NewDecoder reads the stream's metadata and returns a decoder positioned at
+// its first frame
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	d := &Decoder{br: newBitReader(r)}
+	var header [4]byte
+	if _, err := io.ReadFull(d.br.r, header[:]); err != nil || header != marker {
+		return nil, errors.New("not a FLAC stream")
+	}
+
+	haveInfo := false
+	for last := false; !last; {
+		var blockHeader [4]byte
+		if _, err := io.ReadFull(d.br.r, blockHeader[:]); err != nil {
+			return nil, corruptf("truncated metadata: %v", err)
+		}
+		last = blockHeader[0]&0x80 != 0
+		kind := blockHeader[0] & 0x7F
+		size := int(blockHeader[1])<<16 | int(blockHeader[2])<<8 | int(blockHeader[3])
+		if kind != blockStreamInfo {
+			if _, err := d.br.r.Discard(size); err != nil {
+				return nil, corruptf("truncated metadata: %v", err)
+			}
+			continue
+		}
+		if size != streamInfoSize {
+			return nil, corruptf("STREAMINFO of %d bytes", size)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(d.br.r, body); err != nil {
+			return nil, corruptf("truncated STREAMINFO: %v", err)
+		}
+		d.info = parseStreamInfo(body)
+		haveInfo = true
+	}
+	if !haveInfo {
+		return nil, corruptf("no STREAMINFO block")
+	}
+
+	if d.info.MD5 != [16]byte{} {
+		d.md5 = md5.New() // #nosec G401 -- STREAMINFO checksum defined by the format
+		d.md5Width = (d.info.BitDepth + 7) / 8
+	}
+	return d, nil
+}
+
+// parseStreamInfo decodes the body of a STREAMINFO block
+func parseStreamInfo(body []byte) StreamInfo {
+	// Sample rate (20 bits), channels - 1 (3), bits per sample - 1 (5) and
+	// total samples (36) are packed after the block and frame sizes
+	packed := binary.BigEndian.Uint64(body[10:18])
+	info := StreamInfo{
+		SampleRate:   int(packed >> 44),
+		Channels:     int(packed>>41&0x7) + 1,
+		BitDepth:     int(packed>>36&0x1F) + 1,
+		TotalSamples: int64(packed & (1<<36 - 1)), // #nosec G115 -- 36-bit count
+	}
+	copy(info.MD5[:], body[18:34])
+	return info
+}
+
+// Info returns the stream's format
+func (d *Decoder) Info() StreamInfo {
+	return d.info
+}
+
+// Read decodes interleaved samples into samples and returns how many it
+// decoded. It returns 0 and io.EOF once the stream is exhausted.
+func (d *Decoder) Read(samples []int) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(samples, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// ReadAll decodes every remaining sample
+func (d *Decoder) ReadAll() ([]int, error) {
+	samples := make([]int, 0, d.info.TotalSamples*int64(d.info.Channels))
+	for {
+		if len(d.pending) == 0 {
+			if d.done {
+				return samples, nil
+			}
+			if err := d.nextFrame(); err != nil {
+				return nil, err
+			}
+		}
+		samples = append(samples, d.pending...)
+		d.pending = nil
+	}
+}
+
+// nextFrame decodes the next frame into d.pending, or sets d.done at the end
+// of the stream and verifies its checksum
+func (d *Decoder) nextFrame() error {
+	if d.br.atEOF() || (d.info.TotalSamples > 0 && d.decoded >= d.info.TotalSamples) {
+		d.done = true
+		if d.info.TotalSamples > 0 && d.decoded != d.info.TotalSamples {
+			return corruptf("stream ends after %d of %d samples", d.decoded, d.info.TotalSamples)
+		}
+		if d.md5 != nil && [16]byte(d.md5.Sum(nil)) != d.info.MD5 {
+			return corruptf("decoded samples don't match the STREAMINFO MD5")
+		}
+		return nil
+	}
+
+	channels, err := d.decodeFrame()
+	if err != nil {
+		return err
+	}
+	blockSize := len(channels[0])
+	d.pending = make([]int, 0, blockSize*len(channels))
+	for i := 0; i < blockSize; i++ {
+		for _, channel := range channels {
+			d.pending = append(d.pending, int(channel[i]))
+		}
+	}
+	d.decoded += int64(blockSize)
+	if d.md5 != nil {
+		var le [8]byte
+		for _, sample := range d.pending {
+			binary.LittleEndian.PutUint64(le[:], uint64(sample)) // #nosec G115 -- two's complement bit pattern
+			d.md5.Write(le[:d.md5Width])
+		}
+	}
+	return nil
+}
+
+// frameHeader holds the fields of a frame header the subframes need
+type frameHeader struct {
+	blockSize  int
+	channels   int
+	assignment uint64
+	bitDepth   uint
+}
+
+// decodeFrame decodes one frame and returns its samples per channel
+func (d *Decoder) decodeFrame() ([][]int64, error) {
+	br := d.br
+	br.resetCRC()
+	header, err := d.readFrameHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([][]int64, header.channels)
+	for c := range channels {
+		bitDepth := header.bitDepth
+		if isSideChannel(header.assignment, c) {
+			bitDepth++
+		}
+		if channels[c], err = readSubframe(br, header.blockSize, bitDepth); err != nil {
+			return nil, err
+		}
+	}
+
+	br.align()
+	crc := br.crc16
+	footer, err := br.read(16)
+	if err != nil {
+		return nil, err
+	}
+	if uint16(footer) != crc { // #nosec G115 -- 16-bit field
+		return nil, corruptf("frame CRC-16 mismatch")
+	}
+
+	// Undo the stereo decorrelation
+	if header.assignment >= channelsLeftSide {
+		left, right := channels[0], channels[1]
+		for i := range left {
+			switch header.assignment {
+			case channelsLeftSide:
+				right[i] = left[i] - right[i]
+			case channelsRightSide:
+				left[i] += right[i]
+			case channelsMidSide:
+				mid, side := left[i]<<1|right[i]&1, right[i]
+				left[i], right[i] = (mid+side)>>1, (mid-side)>>1
+			}
+		}
+	}
+	return channels, nil
+}
+
+// readFrameHeader reads and checks a frame header
+func (d *Decoder) readFrameHeader() (frameHeader, error) {
+	br := d.br
+	var h frameHeader
+	sync, err := br.read(14)
+	if err != nil {
+		return h, err
+	}
+	if sync != frameSync {
+		return h, corruptf("lost frame sync")
+	}
+	if _, err = br.read(2); err != nil { // reserved bit and blocking strategy
+		return h, err
+	}
+	fields, err := br.read(16)
+	if err != nil {
+		return h, err
+	}
+	blockSizeCode := fields >> 12
+	rateCode := fields >> 8 & 0xF
+	h.assignment = fields >> 4 & 0xF
+	sizeCode := fields >> 1 & 0x7
+
+	if _, err = readUTF8(br); err != nil {
+		return h, err
+	}
+
+	switch {
+	case blockSizeCode == 0:
+		return h, corruptf("reserved block size")
+	case blockSizeCode == 1:
+		h.blockSize = 192
+	case blockSizeCode <= 5:
+		h.blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6 || blockSizeCode == 7:
+		v, readErr := br.read(uint(blockSizeCode-5) * 8)
+		if readErr != nil {
+			return h, readErr
+		}
+		h.blockSize = int(v) + 1 // #nosec G115 -- at most 16 bits
+	default:
+		h.blockSize = 256 << (blockSizeCode - 8)
+	}
+
+	// The rate is only needed to skip its bits; samples carry the STREAMINFO rate
+	switch rateCode {
+	case 12:
+		_, err = br.read(8)
+	case 13, 14:
+		_, err = br.read(16)
+	case 15:
+		err = corruptf("invalid sample rate")
+	}
+	if err != nil {
+		return h, err
+	}
+
+	switch {
+	case h.assignment < channelsLeftSide:
+		h.channels = int(h.assignment) + 1 // #nosec G115 -- at most 8
+	case h.assignment <= channelsMidSide:
+		h.channels = 2
+	default:
+		return h, corruptf("reserved channel assignment %d", h.assignment)
+	}
+
+	switch sizeCode {
+	case 0:
+		h.bitDepth = uint(d.info.BitDepth) // #nosec G115 -- from a 5-bit field
+	case 3:
+		return h, corruptf("reserved sample size")
+	default:
+		for depth, code := range sampleSizeCodes {
+			if code == sizeCode {
+				h.bitDepth = uint(depth) // #nosec G115 -- from the table
+			}
+		}
+	}
+
+	crc := br.crc8
+	headerCRC, err := br.read(8)
+	if err != nil {
+		return h, err
+	}
+	if byte(headerCRC) != crc {
+		return h, corruptf("frame header CRC-8 mismatch")
+	}
+	return h, nil
+}
+
+// readUTF8 reads a frame or sample number in FLAC's extended UTF-8 coding
+func readUTF8(br *bitReader) (uint64, error) {
+	first, err := br.read(8)
+	if err != nil {
+		return 0, err
+	}
+	// The number of leading one bits is the total byte count
+	n := 0
+	for first&(0x80>>n) != 0 {
+		n++
+	}
+	switch {
+	case n == 0:
+		return first, nil
+	case n == 1 || n > 7:
+		return 0, corruptf("invalid UTF-8 frame number")
+	}
+	v := first & (0x7F >> n)
+	for i := 1; i < n; i++ {
+		b, readErr := br.read(8)
+		if readErr != nil {
+			return 0, readErr
+		}
+		if b&0xC0 != 0x80 {
+			return 0, corruptf("invalid UTF-8 frame number")
+		}
+		v = v<<6 | b&0x3F
+	}
+	return v, nil
+}
+
+// readSubframe decodes one channel of a frame
+func readSubframe(br *bitReader, blockSize int, bitDepth uint) ([]int64, error) {
+	header, err := br.read(8)
+	if err != nil {
+		return nil, err
+	}
+	if header&0x80 != 0 {
+		return nil, corruptf("subframe padding bit set")
+	}
+	kind := int(header >> 1 & 0x3F) // #nosec G115 -- 6-bit field
+
+	// Wasted bits are low zero bits of every sample, left out of the encoding
+	var wasted uint
+	if header&1 != 0 {
+		count, unaryErr := br.readUnary()
+		if unaryErr != nil {
+			return nil, unaryErr
+		}
+		wasted = uint(count) + 1
+		if wasted >= bitDepth {
+			return nil, corruptf("%d wasted bits of %d", wasted, bitDepth)
+		}
+		bitDepth -= wasted
+	}
+
+	samples := make([]int64, blockSize)
+	switch {
+	case kind == subframeConstant:
+		value, readErr := br.readSigned(bitDepth)
+		if readErr != nil {
+			return nil, readErr
+		}
+		for i := range samples {
+			samples[i] = value
+		}
+	case kind == subframeVerbatim:
+		for i := range samples {
+			if samples[i], err = br.readSigned(bitDepth); err != nil {
+				return nil, err
+			}
+		}
+	case kind >= subframeFixed && kind <= subframeFixed+maxFixedOrder:
+		if err = readFixed(br, samples, kind-subframeFixed, bitDepth); err != nil {
+			return nil, err
+		}
+	case kind >= subframeLPC:
+		if err = readLPC(br, samples, kind-subframeLPC+1, bitDepth); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, corruptf("reserved subframe type %d", kind)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+	return samples, nil
+}
+
+// readWarmup reads the first order samples, which are stored verbatim
+func readWarmup(br *bitReader, samples []int64, order int, bitDepth uint) error {
+	if order > len(samples) {
+		return corruptf("predictor order %d exceeds block size %d", order, len(samples))
+	}
+	for i := 0; i < order; i++ {
+		var err error
+		if samples[i], err = br.readSigned(bitDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFixed decodes a fixed predictor subframe into samples
+func readFixed(br *bitReader, samples []int64, order int, bitDepth uint) error {
+	if err := readWarmup(br, samples, order, bitDepth); err != nil {
+		return err
+	}
+	if err := readResiduals(br, samples, order); err != nil {
+		return err
+	}
+	s := samples
+	for i := order; i < len(s); i++ {
+		switch order {
+		case 1:
+			s[i] += s[i-1]
+		case 2:
+			s[i] += 2*s[i-1] - s[i-2]
+		case 3:
+			s[i] += 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			s[i] += 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+	}
+	return nil
+}
+
+// readLPC decodes a linear predictor subframe into samples
+func readLPC(br *bitReader, samples []int64, order int, bitDepth uint) error {
+	if err := readWarmup(br, samples, order, bitDepth); err != nil {
+		return err
+	}
+	precision, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	if precision == 0xF {
+		return corruptf("invalid LPC coefficient precision")
+	}
+	shift, err := br.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return corruptf("negative LPC shift")
+	}
+	coefficients := make([]int64, order)
+	for i := range coefficients {
+		if coefficients[i], err = br.readSigned(uint(precision) + 1); err != nil {
+			return err
+		}
+	}
+	if err = readResiduals(br, samples, order); err != nil {
+		return err
+	}
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, coefficient := range coefficients {
+			prediction += coefficient * samples[i-j-1]
+		}
+		samples[i] += prediction >> shift
+	}
+	return nil
+}
+
+// readResiduals reads the partitioned Rice residuals of a predictor of the
+// given order into samples[order:]
+func readResiduals(br *bitReader, samples []int64, order int) error {
+	method, err := br.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return corruptf("reserved residual coding method")
+	}
+	paramBits := uint(4 + method)
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	if len(samples)%partitions != 0 || len(samples)/partitions < order {
+		return corruptf("partition order %d doesn't fit block size %d", partitionOrder, len(samples))
+	}
+	partitionSize := len(samples) / partitions
+
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+		param, readErr := br.read(paramBits)
+		if readErr != nil {
+			return readErr
+		}
+		if param == escape {
+			// Residuals stored unencoded, with the bit width that follows
+			width, widthErr := br.read(5)
+			if widthErr != nil {
+				return widthErr
+			}
+			for ; i < end; i++ {
+				if samples[i], err = br.readSigned(uint(width)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		for ; i < end; i++ {
+			q, unaryErr := br.readUnary()
+			if unaryErr != nil {
+				return unaryErr
+			}
+			low, lowErr := br.read(uint(param))
+			if lowErr != nil {
+				return lowErr
+			}
+			folded := q<<param | low
+			samples[i] = int64(folded>>1) ^ -int64(folded&1) // #nosec G115 -- zigzag decoding
+		}
+	}
+	return nil
+}
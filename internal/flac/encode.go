@@ -0,0 +1,421 @@
+package flac
+
+import (
+	"crypto/md5" // #nosec G501 -- STREAMINFO checksum defined by the format
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// BlockSize is the number of samples per channel in each frame the encoder
+// writes, except the last
+const BlockSize = 4096
+
+// maxPartitionOrder bounds the Rice partition orders the encoder tries
+const maxPartitionOrder = 8
+
+// maxRiceParameter is the highest Rice parameter of the 5-bit coding method;
+// the 4-bit method stops at 14, as 15 is its escape code
+const maxRiceParameter = 30
+
+// Encode writes interleaved integer samples of the given bit depth (4 to 32)
+// as a FLAC stream. Encoding is lossless: decoding the stream returns samples
+// exactly.
+func Encode(w io.Writer, samples []int, sampleRate, channels, bitDepth int) error {
+	switch {
+	case bitDepth < 4 || bitDepth > 32:
+		return fmt.Errorf("unsupported FLAC bit depth %d: must be 4 to 32", bitDepth)
+	case channels < 1 || channels > 8:
+		return fmt.Errorf("unsupported FLAC channel count %d: must be 1 to 8", channels)
+	case sampleRate < 1 || sampleRate >= 1<<20:
+		return fmt.Errorf("unsupported FLAC sample rate %d", sampleRate)
+	case len(samples)%channels != 0:
+		return fmt.Errorf("%d samples is not a whole number of %d-channel frames", len(samples), channels)
+	}
+	minValue, maxValue := -1<<(bitDepth-1), 1<<(bitDepth-1)-1
+	for _, sample := range samples {
+		if sample < minValue || sample > maxValue {
+			return fmt.Errorf("sample %d out of range for %d-bit audio", sample, bitDepth)
+		}
+	}
+
+	enc := &encoder{
+		channels: channels,
+		bitDepth: bitDepth,
+		rateCode: sampleRateCodes[sampleRate],
+		sizeCode: sampleSizeCodes[bitDepth],
+	}
+	frameCount := len(samples) / channels
+	var frames []byte
+	minFrame, maxFrame := 0, 0
+	for start, number := 0, uint64(0); start < frameCount; start, number = start+BlockSize, number+1 {
+		end := min(start+BlockSize, frameCount)
+		frame := enc.encodeFrame(samples[start*channels:end*channels], number)
+		if minFrame == 0 || len(frame) < minFrame {
+			minFrame = len(frame)
+		}
+		maxFrame = max(maxFrame, len(frame))
+		frames = append(frames, frame...)
+	}
+
+	info := StreamInfo{
+		SampleRate:   sampleRate,
+		Channels:     channels,
+		BitDepth:     bitDepth,
+		TotalSamples: int64(frameCount),
+		MD5:          samplesMD5(samples, bitDepth),
+	}
+	if _, err := w.Write(marker[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(encodeStreamInfo(info, minFrame, maxFrame)); err != nil {
+		return err
+	}
+	_, err := w.Write(frames)
+	return err
+}
+
+// encodeStreamInfo returns the STREAMINFO block, marked as the last metadata block
+func encodeStreamInfo(info StreamInfo, minFrame, maxFrame int) []byte {
+	var bw bitWriter
+	bw.write(1, 1) // last metadata block
+	bw.write(blockStreamInfo, 7)
+	bw.write(streamInfoSize, 24)
+	// Block sizes below 16 are reserved, so short streams still claim the
+	// nominal size; their one frame is the last, which may be smaller
+	bw.write(BlockSize, 16)
+	bw.write(BlockSize, 16)
+	bw.write(uint64(minFrame), 24)          // #nosec G115 -- frame sizes are positive
+	bw.write(uint64(maxFrame), 24)          // #nosec G115 -- frame sizes are positive
+	bw.write(uint64(info.SampleRate), 20)   // #nosec G115 -- checked by Encode
+	bw.write(uint64(info.Channels-1), 3)    // #nosec G115 -- checked by Encode
+	bw.write(uint64(info.BitDepth-1), 5)    // #nosec G115 -- checked by Encode
+	bw.write(uint64(info.TotalSamples), 36) // #nosec G115 -- a sample count
+	buf := bw.bytes()
+	return append(buf, info.MD5[:]...)
+}
+
+// samplesMD5 returns the MD5 of the samples as little-endian signed integers
+// of the smallest whole number of bytes that holds bitDepth, as STREAMINFO
+// defines it
+func samplesMD5(samples []int, bitDepth int) [16]byte {
+	width := (bitDepth + 7) / 8
+	buf := make([]byte, 0, len(samples)*width)
+	var le [8]byte
+	for _, sample := range samples {
+		binary.LittleEndian.PutUint64(le[:], uint64(sample)) // #nosec G115 -- two's complement bit pattern
+		buf = append(buf, le[:width]...)
+	}
+	return md5.Sum(buf) // #nosec G401 -- STREAMINFO checksum defined by the format
+}
+
+// encoder holds the stream parameters every frame header repeats
+type encoder struct {
+	channels int
+	bitDepth int
+	rateCode uint64
+	sizeCode uint64
+}
+
+// encodeFrame encodes one block of interleaved samples as a frame
+func (e *encoder) encodeFrame(samples []int, number uint64) []byte {
+	blockSize := len(samples) / e.channels
+	chans := make([][]int64, e.channels)
+	for c := range chans {
+		chans[c] = make([]int64, blockSize)
+		for i := range chans[c] {
+			chans[c][i] = int64(samples[i*e.channels+c])
+		}
+	}
+
+	// Independent channels, unless a stereo pair is smaller stored as one
+	// channel plus the difference (side), or as their average (mid) plus side
+	assignment := uint64(e.channels - 1) // #nosec G115 -- checked by Encode
+	subframes := make([]subframe, e.channels)
+	for c := range chans {
+		subframes[c] = chooseSubframe(chans[c], uint(e.bitDepth)) // #nosec G115 -- checked by Encode
+	}
+	if e.channels == 2 {
+		left, right := chans[0], chans[1]
+		mid, side := make([]int64, blockSize), make([]int64, blockSize)
+		for i := range left {
+			mid[i] = (left[i] + right[i]) >> 1
+			side[i] = left[i] - right[i]
+		}
+		bitDepth := uint(e.bitDepth) // #nosec G115 -- checked by Encode
+		midSub := chooseSubframe(mid, bitDepth)
+		sideSub := chooseSubframe(side, bitDepth+1)
+		best := subframes[0].bits + subframes[1].bits
+		if cost := subframes[0].bits + sideSub.bits; cost < best {
+			best, assignment = cost, channelsLeftSide
+		}
+		if cost := sideSub.bits + subframes[1].bits; cost < best {
+			best, assignment = cost, channelsRightSide
+		}
+		if cost := midSub.bits + sideSub.bits; cost < best {
+			assignment = channelsMidSide
+		}
+		switch assignment {
+		case channelsLeftSide:
+			subframes[1] = sideSub
+		case channelsRightSide:
+			subframes[0] = sideSub
+		case channelsMidSide:
+			subframes[0], subframes[1] = midSub, sideSub
+		}
+	}
+
+	var bw bitWriter
+	bw.write(frameSync, 14)
+	bw.write(0, 1) // reserved
+	bw.write(0, 1) // fixed block size
+	var blockSizeBits uint
+	switch {
+	case blockSize == BlockSize:
+		bw.write(12, 4) // 256 * 2^(12-8)
+	case blockSize <= 256:
+		bw.write(6, 4) // 8-bit block size - 1 follows
+		blockSizeBits = 8
+	default:
+		bw.write(7, 4) // 16-bit block size - 1 follows
+		blockSizeBits = 16
+	}
+	bw.write(e.rateCode, 4)
+	bw.write(assignment, 4)
+	bw.write(e.sizeCode, 3)
+	bw.write(0, 1) // reserved
+	writeUTF8(&bw, number)
+	bw.write(uint64(blockSize-1), blockSizeBits) // #nosec G115 -- blockSize >= 1
+	bw.write(uint64(crc8(bw.bytes())), 8)
+
+	for c, sub := range subframes {
+		bitDepth := uint(e.bitDepth) // #nosec G115 -- checked by Encode
+		if isSideChannel(assignment, c) {
+			bitDepth++
+		}
+		sub.write(&bw, bitDepth)
+	}
+	bw.align()
+	bw.write(uint64(crc16(bw.bytes())), 16)
+	return bw.bytes()
+}
+
+// isSideChannel reports whether channel c holds the side (difference) signal
+// under the given channel assignment, which takes one more bit per sample
+func isSideChannel(assignment uint64, c int) bool {
+	switch assignment {
+	case channelsLeftSide, channelsMidSide:
+		return c == 1
+	case channelsRightSide:
+		return c == 0
+	}
+	return false
+}
+
+// writeUTF8 writes a frame number in FLAC's extended UTF-8 coding
+func writeUTF8(bw *bitWriter, v uint64) {
+	if v < 0x80 {
+		bw.write(v, 8)
+		return
+	}
+	// Number of continuation bytes, each carrying 6 bits
+	n := uint(1)
+	for v >= 1<<(6*n+6-n) {
+		n++
+	}
+	lead := uint64(0xFF) << (7 - n) & 0xFF
+	bw.write(lead|v>>(6*n), 8)
+	for i := n; i > 0; i-- {
+		bw.write(0x80|v>>(6*(i-1))&0x3F, 8)
+	}
+}
+
+// subframe is a channel of one frame, encoded by the cheapest method found
+type subframe struct {
+	kind      int     // subframeConstant, subframeVerbatim or subframeFixed
+	order     int     // fixed predictor order
+	samples   []int64 // the channel's samples
+	residuals []int64 // prediction residuals, after the warm-up samples
+	rice      riceCoding
+	bits      int // encoded size, excluding the subframe header
+}
+
+// chooseSubframe picks the smallest encoding of a channel's samples
+func chooseSubframe(samples []int64, bitDepth uint) subframe {
+	constant := true
+	for _, sample := range samples[1:] {
+		if sample != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return subframe{kind: subframeConstant, samples: samples, bits: int(bitDepth)}
+	}
+
+	best := subframe{kind: subframeVerbatim, samples: samples, bits: len(samples) * int(bitDepth)}
+	for order := 0; order <= maxFixedOrder && order < len(samples); order++ {
+		residuals := fixedResiduals(samples, order)
+		rice, ok := chooseRice(residuals, len(samples), order)
+		if !ok {
+			continue
+		}
+		bits := order*int(bitDepth) + rice.bits
+		if bits < best.bits {
+			best = subframe{kind: subframeFixed, order: order, samples: samples, residuals: residuals, rice: rice, bits: bits}
+		}
+	}
+	return best
+}
+
+// fixedResiduals returns the residuals of the fixed predictor of the given
+// order, which predicts each sample by extrapolating a polynomial through the
+// previous ones
+func fixedResiduals(samples []int64, order int) []int64 {
+	residuals := make([]int64, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		s := samples
+		var prediction int64
+		switch order {
+		case 1:
+			prediction = s[i-1]
+		case 2:
+			prediction = 2*s[i-1] - s[i-2]
+		case 3:
+			prediction = 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			prediction = 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+		residuals[i-order] = s[i] - prediction
+	}
+	return residuals
+}
+
+// write encodes the subframe with its header
+func (s subframe) write(bw *bitWriter, bitDepth uint) {
+	bw.write(0, 1) // padding
+	switch s.kind {
+	case subframeConstant:
+		bw.write(subframeConstant, 6)
+		bw.write(0, 1) // no wasted bits
+		bw.writeSigned(s.samples[0], bitDepth)
+	case subframeVerbatim:
+		bw.write(subframeVerbatim, 6)
+		bw.write(0, 1)
+		for _, sample := range s.samples {
+			bw.writeSigned(sample, bitDepth)
+		}
+	default:
+		bw.write(uint64(subframeFixed+s.order), 6) // #nosec G115 -- order <= 4
+		bw.write(0, 1)
+		for _, sample := range s.samples[:s.order] {
+			bw.writeSigned(sample, bitDepth)
+		}
+		s.rice.write(bw, s.residuals, s.order)
+	}
+}
+
+// riceCoding is a partitioning of the residuals with a Rice parameter each
+type riceCoding struct {
+	order      uint   // partition order: 2^order partitions
+	parameters []uint // Rice parameter of each partition
+	bits       int    // estimated encoded size
+}
+
+// chooseRice picks the partition order and parameters that encode the
+// residuals of a block of blockSize samples smallest. It reports false if a
+// residual doesn't fit the 32 bits the format allows.
+func chooseRice(residuals []int64, blockSize, predictorOrder int) (riceCoding, bool) {
+	// Sums of zigzag-folded residuals for the finest partitioning; coarser
+	// ones are sums of neighbours
+	maxOrder := uint(0)
+	for maxOrder < maxPartitionOrder && blockSize%(1<<(maxOrder+1)) == 0 && blockSize>>(maxOrder+1) > predictorOrder {
+		maxOrder++
+	}
+	partitions := 1 << maxOrder
+	partitionSize := blockSize >> maxOrder
+	sums := make([]uint64, partitions)
+	for i, residual := range residuals {
+		if residual < -1<<31 || residual >= 1<<31 {
+			return riceCoding{}, false
+		}
+		sums[(i+predictorOrder)/partitionSize] += zigzag(residual)
+	}
+
+	var best riceCoding
+	for order := maxOrder; ; order-- {
+		coding := riceCoding{order: order, parameters: make([]uint, len(sums)), bits: 2 + 4}
+		for p, sum := range sums {
+			count := blockSize >> order
+			if p == 0 {
+				count -= predictorOrder
+			}
+			param, bits := riceParameter(sum, count)
+			coding.parameters[p] = param
+			coding.bits += bits
+		}
+		for _, param := range coding.parameters {
+			coding.bits += 4
+			if param > 14 {
+				coding.bits++ // parameters take 5 bits with the second coding method
+			}
+		}
+		if best.parameters == nil || coding.bits < best.bits {
+			best = coding
+		}
+		if order == 0 {
+			return best, true
+		}
+		// Merge pairs of partitions for the next coarser order
+		for p := range sums[:len(sums)/2] {
+			sums[p] = sums[2*p] + sums[2*p+1]
+		}
+		sums = sums[:len(sums)/2]
+	}
+}
+
+// riceParameter picks the Rice parameter for a partition of count residuals
+// whose folded values sum to sum, and returns it with the estimated encoded
+// size of the partition
+func riceParameter(sum uint64, count int) (uint, int) {
+	bestParam, bestBits := uint(0), -1
+	for param := uint(0); param <= maxRiceParameter; param++ {
+		bits := count*(int(param)+1) + int(sum>>param) // #nosec G115 -- bounded by the block's bits
+		if bestBits < 0 || bits < bestBits {
+			bestParam, bestBits = param, bits
+		}
+	}
+	return bestParam, bestBits
+}
+
+// write encodes the residuals of a predictor of the given order with the
+// chosen partitions and parameters
+func (rc riceCoding) write(bw *bitWriter, residuals []int64, predictorOrder int) {
+	paramBits := uint(4)
+	for _, param := range rc.parameters {
+		if param > 14 {
+			paramBits = 5
+		}
+	}
+	bw.write(uint64(paramBits-4), 2) // coding method
+	bw.write(uint64(rc.order), 4)
+
+	// The first partition is short by the predictor's warm-up samples
+	partitionSize := (len(residuals) + predictorOrder) >> rc.order
+	start := 0
+	for p, param := range rc.parameters {
+		end := (p+1)*partitionSize - predictorOrder
+		bw.write(uint64(param), paramBits)
+		for _, residual := range residuals[start:end] {
+			folded := zigzag(residual)
+			bw.writeUnary(folded >> param)
+			bw.write(folded, param)
+		}
+		start = end
+	}
+}
+
+// zigzag folds a signed residual onto the non-negative integers
+func zigzag(v int64) uint64 {
+	return uint64(v<<1 ^ v>>63) // #nosec G115 -- zigzag encoding
+}
@@ -0,0 +1,121 @@
+package flac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSignal returns frames interleaved frames of a test signal at bitDepth
+func testSignal(kind string, frames, channels, bitDepth int, rng *rand.Rand) []int {
+	minValue, maxValue := -1<<(bitDepth-1), 1<<(bitDepth-1)-1
+	samples := make([]int, frames*channels)
+	for f := 0; f < frames; f++ {
+		for ch := 0; ch < channels; ch++ {
+			var value int
+			switch kind {
+			case "silence":
+			case "constant":
+				value = maxValue / 3
+			case "extremes":
+				// Full-scale square wave, the hardest case for the residual widths
+				value = maxValue
+				if (f+ch)%2 == 1 {
+					value = minValue
+				}
+			case "sine":
+				value = int(float64(maxValue) * 0.9 * math.Sin(2*math.Pi*440*float64(f)/44100+float64(ch)))
+			case "noise":
+				value = minValue + rng.Intn(maxValue-minValue+1)
+			case "correlated":
+				// Identical or inverted channels, which stereo decorrelation picks up
+				value = int(float64(maxValue) * 0.5 * math.Sin(2*math.Pi*220*float64(f)/44100))
+				if ch%2 == 1 {
+					value = -value
+				}
+			}
+			samples[f*channels+ch] = value
+		}
+	}
+	return samples
+}
+
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, bitDepth := range []int{8, 16, 24, 32} {
+		for _, channels := range []int{1, 2, 6} {
+			for _, kind := range []string{"silence", "constant", "extremes", "sine", "noise", "correlated"} {
+				for _, frames := range []int{1, BlockSize, 2*BlockSize + 17} {
+					name := fmt.Sprintf("%d-bit/%dch/%s/%d", bitDepth, channels, kind, frames)
+					t.Run(name, func(t *testing.T) {
+						samples := testSignal(kind, frames, channels, bitDepth, rng)
+						var encoded bytes.Buffer
+						require.NoError(t, Encode(&encoded, samples, 44100, channels, bitDepth))
+
+						decoder, err := NewDecoder(&encoded)
+						require.NoError(t, err)
+						info := decoder.Info()
+						assert.Equal(t, 44100, info.SampleRate)
+						assert.Equal(t, channels, info.Channels)
+						assert.Equal(t, bitDepth, info.BitDepth)
+						assert.Equal(t, int64(frames), info.TotalSamples)
+
+						decoded, err := decoder.ReadAll()
+						require.NoError(t, err)
+						require.Equal(t, samples, decoded)
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestRoundTripRead(t *testing.T) {
+	// Reading in small buffers returns the same samples as ReadAll
+	samples := testSignal("sine", 3*BlockSize, 2, 16, nil)
+	var encoded bytes.Buffer
+	require.NoError(t, Encode(&encoded, samples, 48000, 2, 16))
+	decoder, err := NewDecoder(&encoded)
+	require.NoError(t, err)
+
+	var decoded []int
+	buf := make([]int, 1000)
+	for {
+		n, err := decoder.Read(buf)
+		if err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+		decoded = append(decoded, buf[:n]...)
+	}
+	assert.Equal(t, samples, decoded)
+}
+
+func TestEncodeRejectsInvalidInput(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Error(t, Encode(&encoded, []int{1 << 15}, 44100, 1, 16), "sample out of range")
+	assert.Error(t, Encode(&encoded, []int{0, 0, 0}, 44100, 2, 16), "partial frame")
+	assert.Error(t, Encode(&encoded, []int{0}, 44100, 9, 16), "too many channels")
+	assert.Error(t, Encode(&encoded, []int{0}, 44100, 1, 33), "bit depth")
+}
+
+func TestDecodeDetectsCorruption(t *testing.T) {
+	samples := testSignal("sine", 2*BlockSize, 2, 16, nil)
+	var encoded bytes.Buffer
+	require.NoError(t, Encode(&encoded, samples, 44100, 2, 16))
+
+	// Flip a bit in the middle of the audio, past the metadata
+	corrupted := bytes.Clone(encoded.Bytes())
+	corrupted[len(corrupted)/2] ^= 0x10
+	decoder, err := NewDecoder(bytes.NewReader(corrupted))
+	require.NoError(t, err)
+	_, err = decoder.ReadAll()
+	assert.True(t, errors.Is(err, ErrCorrupt), "got %v", err)
+}
@@ -0,0 +1,111 @@
+// Package health provides HTTP liveness and readiness endpoints for Kubernetes probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/pphelan007/davidAI/internal/utils"
+)
+
+// checkTimeout bounds how long the dependency checks of one request may take
+const checkTimeout = 5 * time.Second
+
+// Check reports whether a dependency is reachable
+type Check func(ctx context.Context) error
+
+// Response is the JSON body returned by the health endpoints
+type Response struct {
+	Status string            `json:"status"`           // "ok" or "unavailable"
+	Checks map[string]string `json:"checks,omitempty"` // check name -> "ok" or the error
+}
+
+// handler serves the health endpoints
+type handler struct {
+	ready  func() bool
+	checks map[string]Check
+}
+
+// NewServer creates a routine serving health endpoints on the given port:
+//   - /livez returns 200 as long as the process is serving requests
+//   - /healthz returns 200 only if every check passes
+//   - /readyz additionally returns 503 until ready reports true
+func NewServer(port int, ready func() bool, checks map[string]Check) *utils.HTTPServerRoutine {
+	h := &handler{
+		ready:  ready,
+		checks: checks,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livez", h.live)
+	mux.HandleFunc("GET /healthz", h.health)
+	mux.HandleFunc("GET /readyz", h.readiness)
+
+	return utils.NewHTTPServerRoutine("health", &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	})
+}
+
+func (h *handler) live(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Status: "ok"})
+}
+
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	response, healthy := h.runChecks(r.Context())
+	writeResponse(w, response, healthy)
+}
+
+func (h *handler) readiness(w http.ResponseWriter, r *http.Request) {
+	response, healthy := h.runChecks(r.Context())
+	if !h.ready() {
+		response.Checks["worker"] = "not polling task queue"
+		healthy = false
+	} else {
+		response.Checks["worker"] = "ok"
+	}
+	writeResponse(w, response, healthy)
+}
+
+// runChecks runs every dependency check and reports whether all of them passed
+func (h *handler) runChecks(ctx context.Context) (Response, bool) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	response := Response{Checks: make(map[string]string, len(h.checks)+1)}
+	healthy := true
+	for name, check := range h.checks {
+		if err := check(ctx); err != nil {
+			log.Warn().Err(err).Str("check", name).Msg("Health check failed")
+			response.Checks[name] = err.Error()
+			healthy = false
+			continue
+		}
+		response.Checks[name] = "ok"
+	}
+	return response, healthy
+}
+
+func writeResponse(w http.ResponseWriter, response Response, healthy bool) {
+	if !healthy {
+		response.Status = "unavailable"
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	response.Status = "ok"
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
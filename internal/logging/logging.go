@@ -0,0 +1,61 @@
+// Package logging configures the application's structured logger.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	temporallog "go.temporal.io/sdk/log"
+)
+
+// Setup configures the global zerolog logger to emit leveled JSON to stderr
+// at the given level (debug, info, warn, error)
+func Setup(level string) error {
+	lvl, err := zerolog.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	zerolog.SetGlobalLevel(lvl)
+	log.Logger = zerolog.New(os.Stderr).With().Timestamp().Caller().Logger()
+	return nil
+}
+
+// TemporalLogger adapts a zerolog logger to Temporal's log.Logger interface so
+// workflow and activity loggers emit through the same structured output
+type TemporalLogger struct {
+	logger zerolog.Logger
+}
+
+// NewTemporalLogger creates a Temporal logger backed by the given zerolog logger
+func NewTemporalLogger(logger zerolog.Logger) *TemporalLogger {
+	return &TemporalLogger{logger: logger}
+}
+
+// Debug logs a debug message with key/value pairs
+func (l *TemporalLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug().Fields(keyvals).Msg(msg)
+}
+
+// Info logs an info message with key/value pairs
+func (l *TemporalLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info().Fields(keyvals).Msg(msg)
+}
+
+// Warn logs a warning message with key/value pairs
+func (l *TemporalLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn().Fields(keyvals).Msg(msg)
+}
+
+// Error logs an error message with key/value pairs
+func (l *TemporalLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error().Fields(keyvals).Msg(msg)
+}
+
+// With returns a logger that includes the given key/value pairs on every entry
+func (l *TemporalLogger) With(keyvals ...interface{}) temporallog.Logger {
+	return &TemporalLogger{logger: l.logger.With().Fields(keyvals).Logger()}
+}
@@ -4,77 +4,143 @@ package internal
 import (
 	"context"
 	"fmt"
-	"log"
+	"time"
 
+	"github.com/rs/zerolog/log"
+	"go.temporal.io/sdk/client"
+
+	"github.com/pphelan007/davidAI/internal/api"
 	"github.com/pphelan007/davidAI/internal/config"
 	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/health"
+	"github.com/pphelan007/davidAI/internal/logging"
+	"github.com/pphelan007/davidAI/internal/metrics"
 	"github.com/pphelan007/davidAI/internal/temporal"
 	"github.com/pphelan007/davidAI/internal/temporal/activities"
+	"github.com/pphelan007/davidAI/internal/temporal/workflows"
+	"github.com/pphelan007/davidAI/internal/tracing"
 	"github.com/pphelan007/davidAI/internal/utils"
 )
 
 // Run starts the worker and blocks until shutdown
 func Run(cfg *config.Config) error {
 	// 1. Setup Logging
-	// Using standard log package for now, can be upgraded to zerolog later
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if err := logging.Setup(cfg.Log.Level); err != nil {
+		return fmt.Errorf("failed to setup logging: %w", err)
+	}
 
-	// 2. Create Database Client
+	// 2. Setup Tracing (no-op when no OTLP endpoint is configured)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.OTLPEndpoint, cfg.App.Name)
+	if err != nil {
+		return fmt.Errorf("failed to setup tracing: %w", err)
+	}
+	defer func() {
+		if shutdownErr := shutdownTracing(context.Background()); shutdownErr != nil {
+			log.Error().Err(shutdownErr).Msg("Error shutting down tracing")
+		}
+	}()
+
+	// 3. Create Database Client. Run owns it: it is closed once, after every
+	// routine using it (the worker's activities, the health checks) has stopped.
 	dbClient, err := database.NewClient(&cfg.Database)
 	if err != nil {
 		return fmt.Errorf("failed to create database client: %w", err)
 	}
-	defer dbClient.Close()
+	defer func() {
+		if closeErr := dbClient.Close(); closeErr != nil {
+			log.Error().Err(closeErr).Msg("Error closing database client")
+		}
+	}()
 
-	// 3. Create Temporal Client
-	temporalClient, err := temporal.NewTemporalClient(context.Background(), cfg.Temporal.Address, cfg.Temporal.Namespace)
+	// 4. Create Temporal Client
+	temporalClient, err := temporal.NewTemporalClient(context.Background(), &cfg.Temporal)
 	if err != nil {
 		return fmt.Errorf("failed to create temporal client: %w", err)
 	}
 	defer temporalClient.Close()
 
-	// 4. Create the Worker Object
-	worker, err := temporal.NewWorker(temporalClient.GetClient(), cfg.Temporal.TaskQueue, dbClient)
+	// 5. Create the Worker Object
+	worker, err := temporal.NewWorker(temporalClient.GetClient(), cfg.Temporal.WorkerTaskQueues, dbClient, cfg.Worker.ShutdownGracePeriod,
+		cfg.Temporal.BuildID, cfg.Temporal.UseVersioning)
 	if err != nil {
 		return fmt.Errorf("failed to create worker: %w", err)
 	}
 
-	// 5. Create Activities Client
-	activitiesClient := activities.NewActivitiesClient(context.Background(), temporalClient.GetClient(), dbClient)
+	// 6. Create Activities Client
+	activitiesClient := activities.NewActivitiesClient(temporalClient.GetClient(), dbClient, activities.NewActivitiesConfig(cfg))
 
-	// 6. Start Worker Routine (closure captures activitiesClient)
+	// 7. Start Worker Routine (closure captures activitiesClient). On SIGTERM
+	// the worker drains in-flight activities for the grace period before stopping.
 	workerRoutine := utils.NewWorkerRoutine(
 		"worker",
-		func() {
-			worker.Start(activitiesClient)
+		func() error {
+			return worker.Start(activitiesClient)
 		},
 		worker.Stop,
 	)
 
-	mainWg, closeables, startErr := utils.StartRoutines([]utils.Routine{
+	routines := []utils.Routine{
 		workerRoutine,
-	})
+	}
+
+	// HTTP servers are restarted with backoff if they fail, before giving up
+	restartPolicy := utils.RestartPolicy{
+		MaxRestarts:    cfg.Worker.MaxRestarts,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+
+	// 8. Start Metrics Server Routine (if enabled)
+	if cfg.Metrics.Port > 0 {
+		routines = append(routines, utils.NewSupervisedRoutine(metrics.NewServer(cfg.Metrics.Port), restartPolicy))
+	}
+
+	// 9. Start API Server Routine (if enabled)
+	if cfg.API.Port > 0 {
+		activityOptions := workflows.ActivityOptions{
+			StartToCloseTimeout: cfg.Activity.StartToCloseTimeout,
+			InitialInterval:     cfg.Activity.InitialInterval,
+			MaximumInterval:     cfg.Activity.MaximumInterval,
+			MaximumAttempts:     cfg.Activity.MaximumAttempts,
+		}
+		apiServer := api.NewServer(&cfg.API, cfg.Data.HashAlgorithm, temporalClient.GetClient(), cfg.Temporal.TaskQueueFor, activityOptions)
+		routines = append(routines, utils.NewSupervisedRoutine(apiServer, restartPolicy))
+	}
+
+	// 10. Start Health Check Server Routine (if enabled)
+	if cfg.Health.Port > 0 {
+		healthServer := health.NewServer(cfg.Health.Port, worker.Ready, map[string]health.Check{
+			"database": dbClient.Ping,
+			"temporal": func(ctx context.Context) error {
+				_, checkErr := temporalClient.GetClient().CheckHealth(ctx, &client.CheckHealthRequest{})
+				return checkErr
+			},
+		})
+		routines = append(routines, utils.NewSupervisedRoutine(healthServer, restartPolicy))
+	}
+
+	mainWg, closeables, startErr := utils.StartRoutines(routines)
 
 	if startErr != nil {
 		return fmt.Errorf("failed to start routines: %w", startErr)
 	}
 
-	// 7. Log That Worker Started
-	log.Println("Worker started")
-	log.Println("Worker running, waiting for shutdown signal...")
+	// 11. Log That Worker Started
+	log.Info().Msg("Worker started")
+	log.Info().Msg("Worker running, waiting for shutdown signal...")
 
-	// 8. Block Until Shutdown
+	// 12. Block Until Shutdown
 	mainWg.Wait()
 
-	// 9. Cleanup (Reverse Order)
+	// 13. Cleanup (Reverse Order)
 	for i := len(closeables) - 1; i >= 0; i-- {
 		if err := closeables[i].Close(); err != nil {
-			log.Printf("Error closing %T: %v", closeables[i], err)
+			log.Error().Err(err).Str("closeable", fmt.Sprintf("%T", closeables[i])).Msg("Error closing")
 		}
 	}
 
-	// 10. Log That Worker Stopped
-	log.Println("Worker stopped")
+	// 14. Log That Worker Stopped
+	log.Info().Msg("Worker stopped")
 
 	return nil
 }
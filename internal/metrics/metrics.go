@@ -0,0 +1,55 @@
+// Package metrics defines the Prometheus metrics exported by the worker and
+// the HTTP server that exposes them.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Trim outcomes used as the "outcome" label on TrimOperations
+const (
+	TrimOutcomeTrimmed   = "trimmed"
+	TrimOutcomeNoOp      = "noop"
+	TrimOutcomeAllSilent = "all_silent"
+)
+
+var (
+	// AssetsIngested counts raw audio assets ingested
+	AssetsIngested = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "davidai_assets_ingested_total",
+		Help: "Number of raw audio assets ingested",
+	})
+
+	// BytesHashed counts bytes read while computing content hashes
+	BytesHashed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "davidai_bytes_hashed_total",
+		Help: "Number of bytes read while computing content hashes",
+	})
+
+	// TrimOperations counts TrimSilence runs by outcome (trimmed or noop)
+	TrimOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "davidai_trim_operations_total",
+		Help: "Number of TrimSilence operations by outcome",
+	}, []string{"outcome"})
+
+	// SNRDuration observes how long ComputeSNR takes
+	SNRDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "davidai_snr_computation_seconds",
+		Help:    "Latency of SNR computation in seconds",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14), // 10ms to ~80s
+	})
+
+	// FeatureCacheHits counts features reused from an earlier computation on
+	// the same content instead of being recomputed, by feature type
+	FeatureCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "davidai_feature_cache_hits_total",
+		Help: "Number of features reused from an earlier computation by feature type",
+	}, []string{"feature_type"})
+
+	// DBInsertErrors counts failed database inserts by table
+	DBInsertErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "davidai_db_insert_errors_total",
+		Help: "Number of failed database inserts by table",
+	}, []string{"table"})
+)
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/pphelan007/davidAI/internal/utils"
+)
+
+// NewServer creates a routine serving Prometheus metrics at /metrics on the
+// given port, so it starts and stops with the rest of the worker lifecycle
+func NewServer(port int) *utils.HTTPServerRoutine {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return utils.NewHTTPServerRoutine("metrics", &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	})
+}
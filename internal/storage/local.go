@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Local is the local filesystem backend. Relative paths are resolved against
+// BaseDir rather than the process working directory.
+type Local struct {
+	BaseDir string
+}
+
+// Open opens a local file for reading
+func (l *Local) Open(_ context.Context, p string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(l.path(p))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Create creates or truncates a local file
+func (l *Local) Create(_ context.Context, p string) (File, error) {
+	f, err := os.Create(l.path(p))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// CreateNew creates a local file, failing if it already exists. The check and
+// the creation are a single atomic operation.
+func (l *Local) CreateNew(_ context.Context, p string) (File, error) {
+	f, err := os.OpenFile(l.path(p), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666) // #nosec G302 -- same permissions as os.Create
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Exists reports whether a local file exists
+func (l *Local) Exists(_ context.Context, p string) (bool, error) {
+	_, err := os.Stat(l.path(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Remove deletes a local file
+func (l *Local) Remove(_ context.Context, p string) error {
+	return os.Remove(l.path(p))
+}
+
+// MkdirAll creates a local directory and any missing parents
+func (l *Local) MkdirAll(_ context.Context, dir string) error {
+	return os.MkdirAll(l.path(dir), 0o750)
+}
+
+// path resolves p against the base directory
+func (l *Local) path(p string) string {
+	return Resolve(l.BaseDir, p)
+}
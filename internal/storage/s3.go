@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// S3 is the Amazon S3 backend. Objects are not seekable, so reads and writes
+// are buffered through temporary files.
+type S3 struct {
+	client *s3.Client
+}
+
+// NewS3 creates an S3 backend using the default AWS credential chain
+// (environment, shared config, or instance role)
+func NewS3(ctx context.Context) (*S3, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &S3{client: s3.NewFromConfig(cfg)}, nil
+}
+
+// Open downloads an object to a temporary file so it can be seeked
+func (s *S3) Open(ctx context.Context, p string) (io.ReadSeekCloser, error) {
+	bucket, key, ok := parseS3URL(p)
+	if !ok {
+		return nil, fmt.Errorf("invalid S3 URL: %s", p)
+	}
+
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get S3 object %s: %w", p, err)
+	}
+	defer obj.Body.Close()
+
+	tmp, err := newTempFile()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmp, obj.Body); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to download S3 object %s: %w", p, err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to rewind downloaded S3 object: %w", err)
+	}
+	return tmp, nil
+}
+
+// Create returns a temporary file that is uploaded to S3 when closed
+func (s *S3) Create(ctx context.Context, p string) (File, error) {
+	bucket, key, ok := parseS3URL(p)
+	if !ok {
+		return nil, fmt.Errorf("invalid S3 URL: %s", p)
+	}
+
+	tmp, err := newTempFile()
+	if err != nil {
+		return nil, err
+	}
+	return &s3Upload{
+		tempFile: tmp,
+		ctx:      ctx,
+		client:   s.client,
+		bucket:   bucket,
+		key:      key,
+	}, nil
+}
+
+// CreateNew is like Create but fails if the object already exists. S3 can't
+// reserve a key up front, so an object created by someone else after the
+// check makes the upload on Close fail instead of overwriting it.
+func (s *S3) CreateNew(ctx context.Context, p string) (File, error) {
+	exists, err := s.Exists(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, &fs.PathError{Op: "create", Path: p, Err: fs.ErrExist}
+	}
+
+	file, err := s.Create(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	upload, ok := file.(*s3Upload)
+	if !ok {
+		return nil, fmt.Errorf("unexpected S3 upload type %T", file)
+	}
+	upload.ifNoneMatch = true
+	return upload, nil
+}
+
+// Exists reports whether an object exists
+func (s *S3) Exists(ctx context.Context, p string) (bool, error) {
+	bucket, key, ok := parseS3URL(p)
+	if !ok {
+		return false, fmt.Errorf("invalid S3 URL: %s", p)
+	}
+
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check S3 object %s: %w", p, err)
+	}
+	return true, nil
+}
+
+// Remove deletes an object
+func (s *S3) Remove(ctx context.Context, p string) error {
+	bucket, key, ok := parseS3URL(p)
+	if !ok {
+		return fmt.Errorf("invalid S3 URL: %s", p)
+	}
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// MkdirAll is a no-op since S3 prefixes don't need to be created
+func (s *S3) MkdirAll(context.Context, string) error {
+	return nil
+}
+
+// tempFile is a temporary file that is deleted when closed
+type tempFile struct {
+	*os.File
+}
+
+func newTempFile() (*tempFile, error) {
+	f, err := os.CreateTemp("", "davidai-s3-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return &tempFile{File: f}, nil
+}
+
+// Close closes and deletes the temporary file
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	if removeErr := os.Remove(t.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// s3Upload buffers writes in a temporary file and uploads it on Close
+type s3Upload struct {
+	*tempFile
+	ctx    context.Context
+	client *s3.Client
+	bucket string
+	key    string
+	closed bool
+
+	ifNoneMatch bool // fail instead of replacing an existing object
+}
+
+// Abort deletes the temporary file without uploading it, so a failed write
+// never reaches S3
+func (u *s3Upload) Abort() error {
+	if u.closed {
+		return nil
+	}
+	u.closed = true
+	return u.tempFile.Close()
+}
+
+// Close uploads the buffered content and deletes the temporary file
+func (u *s3Upload) Close() error {
+	if u.closed {
+		return nil
+	}
+	u.closed = true
+	defer u.tempFile.Close()
+
+	if _, err := u.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind upload buffer: %w", err)
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(u.key),
+		Body:   u.File,
+	}
+	if u.ifNoneMatch {
+		input.IfNoneMatch = aws.String("*")
+	}
+	_, err := u.client.PutObject(u.ctx, input)
+	var apiErr smithy.APIError
+	if u.ifNoneMatch && errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+		return &fs.PathError{Op: "create", Path: fmt.Sprintf("s3://%s/%s", u.bucket, u.key), Err: fs.ErrExist}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", u.bucket, u.key, err)
+	}
+	return nil
+}
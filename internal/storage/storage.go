@@ -0,0 +1,188 @@
+// Package storage abstracts reading and writing audio files so activities work
+// transparently with local paths and s3:// URLs.
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// s3Scheme is the URL prefix that selects the S3 backend
+const s3Scheme = "s3://"
+
+// File is a writable file that can be rewound and read back. The WAV encoder
+// seeks to patch headers after writing, and callers hash the written output.
+type File interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+// Aborter is implemented by files that can be discarded before Close persists
+// them. Abort releases the file without storing anything, leaving whatever
+// was at its path untouched, and makes Close a no-op. Local files are written
+// in place and don't implement it.
+type Aborter interface {
+	Abort() error
+}
+
+// Storage opens and creates files on a storage backend
+type Storage interface {
+	// Open opens a file for reading. Backends that cannot seek buffer the
+	// content to a temporary file so the result is always seekable.
+	Open(ctx context.Context, path string) (io.ReadSeekCloser, error)
+	// Create creates or truncates a file. The content is persisted on Close.
+	Create(ctx context.Context, path string) (File, error)
+	// CreateNew is like Create but fails with an error matching fs.ErrExist
+	// if the file already exists, instead of replacing it.
+	CreateNew(ctx context.Context, path string) (File, error)
+	// Exists reports whether a file exists
+	Exists(ctx context.Context, path string) (bool, error)
+	// Remove deletes a file
+	Remove(ctx context.Context, path string) error
+	// MkdirAll ensures a directory exists (a no-op for object stores)
+	MkdirAll(ctx context.Context, dir string) error
+}
+
+// Router dispatches to the local filesystem or S3 based on the path's scheme.
+// The S3 client is created lazily the first time an s3:// path is used. A
+// Router is safe for concurrent use.
+type Router struct {
+	local  *Local
+	s3Once sync.Once
+	s3     *S3
+	s3Err  error
+}
+
+// NewRouter creates a storage router that resolves relative local paths
+// against baseDir
+func NewRouter(baseDir string) *Router {
+	return &Router{local: &Local{BaseDir: baseDir}}
+}
+
+// Open opens a file on the backend selected by the path
+func (r *Router) Open(ctx context.Context, p string) (io.ReadSeekCloser, error) {
+	backend, err := r.backend(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Open(ctx, p)
+}
+
+// Create creates a file on the backend selected by the path
+func (r *Router) Create(ctx context.Context, p string) (File, error) {
+	backend, err := r.backend(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Create(ctx, p)
+}
+
+// CreateNew creates a file that must not exist yet on the backend selected by the path
+func (r *Router) CreateNew(ctx context.Context, p string) (File, error) {
+	backend, err := r.backend(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return backend.CreateNew(ctx, p)
+}
+
+// Exists reports whether a file exists on the backend selected by the path
+func (r *Router) Exists(ctx context.Context, p string) (bool, error) {
+	backend, err := r.backend(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	return backend.Exists(ctx, p)
+}
+
+// Remove deletes a file on the backend selected by the path
+func (r *Router) Remove(ctx context.Context, p string) error {
+	backend, err := r.backend(ctx, p)
+	if err != nil {
+		return err
+	}
+	return backend.Remove(ctx, p)
+}
+
+// MkdirAll ensures a directory exists on the backend selected by the path
+func (r *Router) MkdirAll(ctx context.Context, dir string) error {
+	backend, err := r.backend(ctx, dir)
+	if err != nil {
+		return err
+	}
+	return backend.MkdirAll(ctx, dir)
+}
+
+func (r *Router) backend(ctx context.Context, p string) (Storage, error) {
+	if !IsS3(p) {
+		return r.local, nil
+	}
+	r.s3Once.Do(func() {
+		// The result is shared by every later call, so it must not fail just
+		// because the first caller's context was cancelled
+		r.s3, r.s3Err = NewS3(context.WithoutCancel(ctx))
+	})
+	if r.s3Err != nil {
+		return nil, r.s3Err
+	}
+	return r.s3, nil
+}
+
+// IsS3 reports whether the path is an s3:// URL
+func IsS3(p string) bool {
+	return strings.HasPrefix(p, s3Scheme)
+}
+
+// Resolve joins a relative local path onto baseDir. Absolute paths, s3:// URLs
+// and paths with an empty baseDir are returned unchanged.
+func Resolve(baseDir, p string) string {
+	if baseDir == "" || IsS3(p) || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
+
+// Dir returns all but the last element of a local path or s3:// URL
+func Dir(p string) string {
+	if bucket, key, ok := parseS3URL(p); ok {
+		dir := path.Dir(key)
+		if dir == "." {
+			return s3Scheme + bucket
+		}
+		return s3Scheme + bucket + "/" + dir
+	}
+	return filepath.Dir(p)
+}
+
+// Join joins a file name onto a local directory or s3:// URL prefix
+func Join(dir, name string) string {
+	if IsS3(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
+
+// Size returns the total size of a seekable stream and rewinds it to the start
+func Size(r io.Seeker) (int64, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// parseS3URL splits s3://bucket/key into its bucket and key
+func parseS3URL(p string) (bucket, key string, ok bool) {
+	if !IsS3(p) {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(p, s3Scheme), "/")
+	return bucket, key, bucket != ""
+}
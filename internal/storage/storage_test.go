@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRouterConcurrentS3Init has many callers create the lazy S3 backend at
+// once. All must share one backend. CI runs it under -race.
+func TestRouterConcurrentS3Init(t *testing.T) {
+	// Keep the AWS SDK away from the environment's credentials and config
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	router := NewRouter(t.TempDir())
+
+	const callers = 16
+	backends := make([]Storage, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backends[i], errs[i] = router.backend(context.Background(), "s3://bucket/key.wav")
+		}()
+	}
+	wg.Wait()
+
+	for i := range backends {
+		require.NoError(t, errs[i])
+		assert.Same(t, backends[0], backends[i])
+	}
+}
+
+// TestRouterConcurrentLocal creates, checks and removes local files from many
+// goroutines through one router
+func TestRouterConcurrentLocal(t *testing.T) {
+	router := NewRouter(t.TempDir())
+	ctx := context.Background()
+	require.NoError(t, router.MkdirAll(ctx, "out"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path := fmt.Sprintf("out/%d.wav", i)
+			file, err := router.CreateNew(ctx, path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = file.Write([]byte("RIFF"))
+			assert.NoError(t, err)
+			assert.NoError(t, file.Close())
+
+			exists, err := router.Exists(ctx, path)
+			assert.NoError(t, err)
+			assert.True(t, exists)
+			assert.NoError(t, router.Remove(ctx, path))
+		}()
+	}
+	wg.Wait()
+}
+
+// TestS3UploadAbort discards a buffered upload. Nothing may be sent: the
+// backend has no client, so an upload would panic.
+func TestS3UploadAbort(t *testing.T) {
+	file, err := (&S3{}).Create(context.Background(), "s3://bucket/out.wav")
+	require.NoError(t, err)
+	_, err = file.Write([]byte("RIFF"))
+	require.NoError(t, err)
+	upload, ok := file.(*s3Upload)
+	require.True(t, ok)
+	require.Implements(t, (*Aborter)(nil), file)
+
+	require.NoError(t, upload.Abort())
+	assert.NoFileExists(t, upload.Name(), "the upload buffer is deleted")
+	assert.NoError(t, file.Close(), "Close after Abort does nothing")
+}
@@ -1,21 +1,88 @@
 package activities
 
 import (
-	"context"
+	"time"
 
 	"go.temporal.io/sdk/client"
 
+	"github.com/pphelan007/davidAI/internal/config"
 	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/storage"
 )
 
+// ActivitiesClient holds the dependencies shared by all activities. Temporal
+// runs activities concurrently on one client, so it must stay safe for
+// concurrent use: its fields are set once in NewActivitiesClient and never
+// modified, the database client is a *sql.DB connection pool, storage
+// guards its lazily created S3 backend with a sync.Once, and decodeSlots is
+// a channel. Any mutable state
+// added here must be guarded as well; per-activity state belongs in locals.
 type ActivitiesClient struct {
 	client   client.Client
 	dbClient *database.Client
+	storage  storage.Storage // resolves local paths and s3:// URLs
+
+	retryJitter     time.Duration // maximum random delay before a retried attempt, 0 disables it
+	hangDetection   bool          // if false, sample-processing loops are never cancelled as hung
+	hangTimeout     time.Duration // fixed hang timeout on top of each attempt's deadline, 0 for none
+	overwritePolicy string        // what to do when an output file exists, one of the Overwrite* constants
+	maxFileSize     int64         // largest audio file in bytes that is decoded into memory, 0 disables the limit
+	dbFailurePolicy string        // what to do when a database call fails, one of the DBFailure* constants
+	hashAlgorithm   string        // content hash algorithm, one of the Hash* constants
+	decodeSlots     chan struct{} // semaphore of activities decoding at once, nil for no limit
+}
+
+// ActivitiesConfig holds the worker settings that shape how activities run
+type ActivitiesConfig struct {
+	DataDir              string        // base directory relative local paths in activity inputs are resolved against
+	RetryJitter          time.Duration // maximum random delay before a retried attempt, 0 disables it
+	HangDetection        bool          // if false, sample-processing loops are never cancelled as hung
+	HangTimeout          time.Duration // fixed hang timeout on top of each attempt's deadline, 0 for none
+	OverwritePolicy      string        // one of the Overwrite* constants, empty or unknown behaves like OverwriteUnique
+	MaxFileSize          int64         // largest audio file in bytes that is decoded into memory, 0 disables the limit
+	DBFailurePolicy      string        // one of the DBFailure* constants, empty or unknown behaves like DBFailureContinue
+	HashAlgorithm        string        // one of the Hash* constants, empty or unknown behaves like HashSHA256
+	MaxConcurrentDecodes int           // activities decoding audio at once, the rest wait for a slot; 0 disables the limit
 }
 
-func NewActivitiesClient(ctx context.Context, temporalClient client.Client, dbClient *database.Client) *ActivitiesClient {
+// NewActivitiesConfig collects the activity settings from the worker's
+// configuration
+func NewActivitiesConfig(cfg *config.Config) ActivitiesConfig {
+	return ActivitiesConfig{
+		DataDir:              cfg.Data.Dir,
+		RetryJitter:          cfg.Activity.RetryJitter,
+		HangDetection:        cfg.Activity.HangDetection,
+		HangTimeout:          cfg.Activity.HangTimeout,
+		OverwritePolicy:      cfg.Data.OverwritePolicy,
+		MaxFileSize:          cfg.Activity.MaxFileSize,
+		DBFailurePolicy:      cfg.Database.FailurePolicy,
+		HashAlgorithm:        cfg.Data.HashAlgorithm,
+		MaxConcurrentDecodes: cfg.Activity.MaxConcurrentDecodes,
+	}
+}
+
+// NewActivitiesClient creates the client whose methods are registered as
+// activities. Retried attempts wait a random delay up to cfg.RetryJitter
+// before starting. With cfg.HangDetection, sample-processing loops that run
+// too long are cancelled with a retryable HangDetected error (see detectHang).
+// Activities that decode a whole file into memory reject files over
+// cfg.MaxFileSize bytes with a non-retryable FileTooLarge error.
+func NewActivitiesClient(temporalClient client.Client, dbClient *database.Client, cfg ActivitiesConfig) *ActivitiesClient {
+	var decodeSlots chan struct{}
+	if cfg.MaxConcurrentDecodes > 0 {
+		decodeSlots = make(chan struct{}, cfg.MaxConcurrentDecodes)
+	}
 	return &ActivitiesClient{
-		client:   temporalClient,
-		dbClient: dbClient,
+		client:          temporalClient,
+		dbClient:        dbClient,
+		storage:         storage.NewRouter(cfg.DataDir),
+		retryJitter:     cfg.RetryJitter,
+		hangDetection:   cfg.HangDetection,
+		hangTimeout:     cfg.HangTimeout,
+		overwritePolicy: cfg.OverwritePolicy,
+		maxFileSize:     cfg.MaxFileSize,
+		dbFailurePolicy: cfg.DBFailurePolicy,
+		hashAlgorithm:   cfg.HashAlgorithm,
+		decodeSlots:     decodeSlots,
 	}
 }
@@ -0,0 +1,102 @@
+package activities
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// TestActivitiesClientConcurrentUse runs activities at once on one client, as
+// Temporal does; CI runs it under -race
+func TestActivitiesClientConcurrentUse(t *testing.T) {
+	const activities = 8
+	dir := t.TempDir()
+	_, ac := newTestEnv(t, dir, ActivitiesConfig{HangDetection: true, MaxConcurrentDecodes: 2})
+	samples := concat(make([]int, 4410), tone(4410, 1, 10000), make([]int, 44100))
+	source := writeTestWAV(t, filepath.Join(dir, "source.wav"), samples, 44100, 1, 16)
+
+	// Every attempt writes the same output name, so OverwriteUnique has to
+	// hand each a different file
+	outputs := make([]string, activities)
+	errs := make([]error, activities)
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(ac)
+			result, err := env.ExecuteActivity("TrimSilence", TrimSilenceInput{SourcePath: source, SilenceThreshold: 0.01, MinSilenceDuration: 0.5})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			var output TrimSilenceOutput
+			errs[i] = result.Get(&output)
+			outputs[i] = output.OutputPath
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, output := range outputs {
+		require.NoError(t, errs[i])
+		assert.False(t, seen[output], "output %s written twice", output)
+		seen[output] = true
+
+		trimmed, _, _ := readTestWAV(t, dir, output)
+		assert.Equal(t, samples[4410:8820], trimmed)
+	}
+}
+
+func TestAcquireDecodeLimitsConcurrency(t *testing.T) {
+	const limit = 3
+	ac := NewActivitiesClient(nil, nil, ActivitiesConfig{MaxConcurrentDecodes: limit})
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4*limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := ac.acquireDecode(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer release()
+			now := running.Add(1)
+			for {
+				highest := peak.Load()
+				if now <= highest || peak.CompareAndSwap(highest, now) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, peak.Load(), int32(limit))
+	assert.Positive(t, peak.Load())
+}
+
+func TestAcquireDecodeCancelled(t *testing.T) {
+	ac := NewActivitiesClient(nil, nil, ActivitiesConfig{MaxConcurrentDecodes: 1})
+	release, err := ac.acquireDecode(context.Background())
+	require.NoError(t, err)
+	defer release()
+
+	// With the only slot taken, waiting ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = ac.acquireDecode(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
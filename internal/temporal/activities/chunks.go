@@ -0,0 +1,164 @@
+package activities
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-audio/wav"
+)
+
+// wavChunk is a RIFF chunk of a WAV file, without its header or padding
+type wavChunk struct {
+	id   [4]byte
+	data []byte
+}
+
+// RIFF chunk IDs of the chunks that describe the audio itself, which the
+// encoder writes anew, and of the chunks holding sample positions
+var (
+	fmtChunkID  = [4]byte{'f', 'm', 't', ' '}
+	dataChunkID = [4]byte{'d', 'a', 't', 'a'}
+	factChunkID = [4]byte{'f', 'a', 'c', 't'}
+	cueChunkID  = [4]byte{'c', 'u', 'e', ' '}
+	smplChunkID = [4]byte{'s', 'm', 'p', 'l'}
+)
+
+// Sizes of the fixed parts of cue and smpl chunks
+const (
+	cuePointSize   = 24 // one cue point
+	smplHeaderSize = 36 // smpl fields before the loops
+	smplLoopSize   = 24 // one sample loop
+)
+
+// readExtraChunks walks the RIFF chunks of a WAV file from its start and
+// returns every chunk other than fmt, data and fact, in file order: LIST/INFO
+// and adtl, cue, bext, iXML and anything else the file carries. A truncated
+// last chunk is dropped, as readers ignore it too.
+func readExtraChunks(r io.Reader) ([]wavChunk, error) {
+	// Skip the RIFF header: "RIFF", size, "WAVE"
+	if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+		return nil, err
+	}
+
+	var chunks []wavChunk
+	var header struct {
+		ID   [4]byte
+		Size uint32
+	}
+	for {
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return chunks, nil
+			}
+			return nil, err
+		}
+		// Chunks are padded to an even size
+		size := int64(header.Size)
+		padded := size + size&1
+		switch header.ID {
+		case fmtChunkID, dataChunkID, factChunkID:
+			if _, err := io.CopyN(io.Discard, r, padded); err != nil {
+				return chunks, nil
+			}
+			continue
+		}
+
+		// Read through a LimitReader so a corrupt size can't allocate more
+		// than the file holds
+		data, err := io.ReadAll(io.LimitReader(r, size))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) < size {
+			return chunks, nil
+		}
+		chunks = append(chunks, wavChunk{id: header.ID, data: data})
+		if padded > size {
+			if _, err = io.CopyN(io.Discard, r, 1); err != nil {
+				return chunks, nil
+			}
+		}
+	}
+}
+
+// trimChunks adjusts the sample positions in chunks copied from a file to the
+// same file with its first leadingFrames frames removed and frames frames
+// kept: cue points and sample loops move back, and those no longer inside the
+// audio are dropped, and the bext time reference moves forward so it still
+// gives the timecode of the first sample. Other chunks are returned as is;
+// the input isn't modified.
+func trimChunks(chunks []wavChunk, leadingFrames, frames int) []wavChunk {
+	// inRange shifts a frame position and reports whether it is still in the audio
+	inRange := func(position uint32) (uint32, bool) {
+		shifted := int64(position) - int64(leadingFrames)
+		return uint32(shifted), shifted >= 0 && shifted <= int64(frames) // #nosec G115 -- checked to be in range
+	}
+
+	trimmed := make([]wavChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		data := chunk.data
+		switch {
+		case chunk.id == cueChunkID && len(data) >= 4:
+			count := min(int(binary.LittleEndian.Uint32(data)), (len(data)-4)/cuePointSize)
+			kept := []byte{0, 0, 0, 0}
+			for i := 0; i < count; i++ {
+				point := append([]byte(nil), data[4+i*cuePointSize:4+(i+1)*cuePointSize]...)
+				// dwPosition is at 4 and dwSampleOffset at 20
+				position, ok := inRange(binary.LittleEndian.Uint32(point[4:]))
+				offset, offsetOK := inRange(binary.LittleEndian.Uint32(point[20:]))
+				if !ok || !offsetOK {
+					continue
+				}
+				binary.LittleEndian.PutUint32(point[4:], position)
+				binary.LittleEndian.PutUint32(point[20:], offset)
+				kept = append(kept, point...)
+			}
+			binary.LittleEndian.PutUint32(kept, uint32((len(kept)-4)/cuePointSize)) // #nosec G115 -- at most count
+			data = kept
+		case chunk.id == smplChunkID && len(data) >= smplHeaderSize:
+			count := min(int(binary.LittleEndian.Uint32(data[28:])), (len(data)-smplHeaderSize)/smplLoopSize)
+			loopsEnd := smplHeaderSize + count*smplLoopSize
+			kept := append([]byte(nil), data[:smplHeaderSize]...)
+			for i := 0; i < count; i++ {
+				loop := append([]byte(nil), data[smplHeaderSize+i*smplLoopSize:smplHeaderSize+(i+1)*smplLoopSize]...)
+				// dwStart is at 8 and dwEnd at 12
+				start, ok := inRange(binary.LittleEndian.Uint32(loop[8:]))
+				end, endOK := inRange(binary.LittleEndian.Uint32(loop[12:]))
+				if !ok || !endOK {
+					continue
+				}
+				binary.LittleEndian.PutUint32(loop[8:], start)
+				binary.LittleEndian.PutUint32(loop[12:], end)
+				kept = append(kept, loop...)
+			}
+			binary.LittleEndian.PutUint32(kept[28:], uint32((len(kept)-smplHeaderSize)/smplLoopSize)) // #nosec G115 -- at most count
+			// Sampler-specific data follows the loops
+			data = append(kept, data[loopsEnd:]...)
+		case chunk.id == bextChunkID && len(data) >= bextFixedSize:
+			data = append([]byte(nil), data...)
+			// TimeReference is the last bext field before the version
+			timeReference := binary.LittleEndian.Uint64(data[bextFixedSize-8:])
+			binary.LittleEndian.PutUint64(data[bextFixedSize-8:], timeReference+uint64(leadingFrames)) // #nosec G115 -- frames are non-negative
+		}
+		trimmed = append(trimmed, wavChunk{id: chunk.id, data: data})
+	}
+	return trimmed
+}
+
+// writeChunk adds a chunk, padded to an even size, to the file being encoded.
+// Going through the encoder keeps the RIFF size it writes on Close correct.
+func writeChunk(encoder *wav.Encoder, chunk wavChunk) error {
+	if err := encoder.AddLE(chunk.id); err != nil {
+		return err
+	}
+	if err := encoder.AddLE(uint32(len(chunk.data))); err != nil { // #nosec G115 -- read from a 32-bit size
+		return err
+	}
+	if err := encoder.AddLE(chunk.data); err != nil {
+		return err
+	}
+	if len(chunk.data)%2 == 1 {
+		return encoder.AddLE(uint8(0))
+	}
+	return nil
+}
@@ -1,13 +1,16 @@
 package activities
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"math"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/go-audio/audio"
@@ -16,73 +19,98 @@ import (
 	"go.temporal.io/sdk/activity"
 
 	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/flac"
+	"github.com/pphelan007/davidAI/internal/metrics"
+	"github.com/pphelan007/davidAI/internal/storage"
 )
 
 // in this file we define the following activities:
 // - IngestRawAudio
+// - FindExistingAsset
 // - TrimSilence
-// - FindNonSilentRange
-// - ComputeSNR
+// - DiscardTrimmedOutput
+// - DetectSegments
+// - TrimToRange
+// - TrimByRanges
+// - ExtractChannel
+// - ApplyGain
+// - ApplyFilter
+// - NormalizeAudio
+// - Resample
+// - ConcatenateAudio
+// - SplitOnSilence
 
 // IngestRawAudio is an activity that ingests a raw audio file, registers it as an asset,
-// computes its content hash, and extracts basic metadata.
+// computes its content hash, and extracts basic metadata. For Broadcast Wave
+// files this includes the bext timing: origination date and time, time
+// reference, and the start and end timecodes it gives.
 func (ac *ActivitiesClient) IngestRawAudio(ctx context.Context, input IngestRawAudioInput) (*IngestRawAudioOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
 	// Read the audio file
-	file, err := os.Open(input.FilePath)
+	file, err := ac.storage.Open(ctx, input.FilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open audio file: %w", err)
 	}
 	defer file.Close()
-
-	// Compute content hash
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return nil, fmt.Errorf("failed to compute hash: %w", err)
+	if _, err = ac.checkFileSize(file, input.FilePath); err != nil {
+		return nil, err
 	}
-	contentHash := hex.EncodeToString(hash.Sum(nil))
 
-	// Reset file pointer for reading metadata
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek file: %w", err)
+	// Compute content hash, leaving the file rewound for reading metadata
+	contentHash, err := ac.hashContent(file)
+	if err != nil {
+		return nil, err
 	}
 
-	// Decode WAV file to extract metadata
-	decoder := wav.NewDecoder(file)
-	if !decoder.IsValidFile() {
-		return nil, fmt.Errorf("file is not a valid WAV file")
+	// Decode the samples to calculate the duration. Empty files fail here, so
+	// no asset is registered for a file with nothing to process.
+	container, err := detectContainer(file)
+	if err != nil {
+		return nil, audioFormatError(input.FilePath, err)
 	}
-
-	format := decoder.Format()
-	sampleRate := int(format.SampleRate)
-	channels := int(format.NumChannels)
-
-	// Read all samples to calculate duration using FullPCMBuffer
-	buf, err := decoder.FullPCMBuffer()
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	decoded, err := decodeAudio(ctx, file, input.FilePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode audio for metadata: %w", err)
+		return nil, err
 	}
+	sampleRate := decoded.format.SampleRate
+	channels := decoded.format.NumChannels
 
 	// Calculate duration
 	var duration float64
-	if sampleRate > 0 && len(buf.Data) > 0 {
+	if sampleRate > 0 {
 		// Duration = (number of samples) / (sample rate * channels)
-		duration = float64(len(buf.Data)) / float64(sampleRate*channels)
+		duration = float64(len(decoded.samples)) / float64(sampleRate*channels)
 	}
 
 	// Generate asset ID
 	assetID := uuid.New().String()
 
 	// Create asset info
-	asset := AssetInfo{
-		AssetID:     assetID,
-		FilePath:    input.FilePath,
-		ContentHash: contentHash,
-		Metadata: AudioMetadata{
-			SampleRate: sampleRate,
-			Duration:   duration,
-			Channels:   channels,
+	output := &IngestRawAudioOutput{
+		Asset: AssetInfo{
+			AssetID:     assetID,
+			FilePath:    input.FilePath,
+			ContentHash: contentHash,
+			Metadata: AudioMetadata{
+				SampleRate: sampleRate,
+				Duration:   duration,
+				Channels:   channels,
+				DCOffset:   dcOffsets(decoded.samples, channels),
+			},
 		},
 	}
+	if container == containerWAV {
+		if err = ac.readBroadcastTiming(ctx, file, &output.Asset.Metadata, len(decoded.samples)/channels); err != nil {
+			return nil, err
+		}
+	}
 
 	// Store asset in database
 	if ac.dbClient != nil {
@@ -95,22 +123,117 @@ func (ac *ActivitiesClient) IngestRawAudio(ctx context.Context, input IngestRawA
 			FilePath:      input.FilePath,
 			ContentHash:   contentHash,
 			CreatedAt:     time.Now(),
+			DatasetID:     input.DatasetID,
+		}
+		if err := ac.dbClient.InsertAssetContext(ctx, dbAsset); err != nil {
+			metrics.DBInsertErrors.WithLabelValues("assets").Inc()
+			if err = ac.dbError(ctx, &output.DBStatus, "insert asset", err); err != nil {
+				return nil, err
+			}
 		}
-		if err := ac.dbClient.InsertAsset(dbAsset); err != nil {
-			// Log error but don't fail the activity
-			activity.GetLogger(ctx).Error("Failed to insert asset into database", "error", err)
+	}
+	metrics.AssetsIngested.Inc()
+
+	return output, nil
+}
+
+// readBroadcastTiming fills in the bext timing of metadata from the WAV file
+// r, leaving it empty if the file has no bext chunk. A malformed bext chunk
+// is logged and skipped rather than failing ingest over optional metadata.
+func (ac *ActivitiesClient) readBroadcastTiming(ctx context.Context, r io.ReadSeeker, metadata *AudioMetadata, frames int) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind audio file: %w", err)
+	}
+	bext, err := readBroadcastExtension(r, metadata.SampleRate)
+	if err != nil {
+		activity.GetLogger(ctx).Warn("Ignoring unreadable bext chunk", "error", err)
+		return nil
+	}
+	if bext == nil {
+		return nil
+	}
+	metadata.OriginationDate = bext.OriginationDate
+	metadata.OriginationTime = bext.OriginationTime
+	metadata.TimeReference = &bext.TimeReference
+	metadata.StartTime = bext.Timecode
+	metadata.EndTime = formatTimecode(bext.TimeReference+uint64(frames), metadata.SampleRate) // #nosec G115 -- frames is non-negative
+	return nil
+}
+
+// dcOffsets returns the mean of each channel's 16-bit samples, scaled to
+// -1..1. Audio has no DC component, so an offset well away from 0 (say beyond
+// 0.01) usually points to a faulty interface or preamp.
+func dcOffsets(samples []int, channels int) []float64 {
+	sums := make([]int64, channels)
+	counts := make([]int, channels) // a trailing partial frame leaves later channels a sample short
+	for i, sample := range samples {
+		sums[i%channels] += int64(sample)
+		counts[i%channels]++
+	}
+	offsets := make([]float64, channels)
+	for ch, sum := range sums {
+		if counts[ch] > 0 {
+			offsets[ch] = float64(sum) / float64(counts[ch]) / 32768.0
 		}
 	}
+	return offsets
+}
 
-	return &IngestRawAudioOutput{
-		Asset: asset,
-	}, nil
+// FindExistingAsset hashes an audio file without decoding it and looks up an
+// already ingested asset with the same content, so duplicate submissions can
+// reuse it instead of creating new assets. If the lookup fails under
+// DBFailureContinue, the content is reported as new, so it may be ingested
+// again.
+func (ac *ActivitiesClient) FindExistingAsset(ctx context.Context, input FindExistingAssetInput) (*FindExistingAssetOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	file, err := ac.storage.Open(ctx, input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	contentHash, err := ac.hashContent(file)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &FindExistingAssetOutput{
+		ContentHash: contentHash,
+	}
+	if ac.dbClient == nil {
+		return output, nil
+	}
+
+	existing, err := ac.dbClient.GetRootAssetByContentHash(ctx, input.DatasetID, contentHash)
+	if err != nil {
+		if err = ac.dbError(ctx, &output.DBStatus, "look up asset by content hash", err); err != nil {
+			return nil, err
+		}
+		return output, nil
+	}
+	if existing != nil {
+		output.ExistingAsset = &AssetInfo{
+			AssetID:     existing.ID,
+			FilePath:    existing.FilePath,
+			ContentHash: existing.ContentHash,
+		}
+	}
+	return output, nil
 }
 
 // TrimSilence trims silence from the beginning and end of an audio file,
 // computes the content hash of the trimmed audio, and stores it as a new asset
-// if it differs from the original.
+// if it differs from the original. The trimmed file keeps the bit depth of 24- and
+// 32-bit integer sources; everything else is written as 16-bit. A WAV output
+// from a WAV source keeps the source's metadata chunks (LIST/INFO, cue, bext,
+// iXML and others), with cue points, sample loops and the bext timecode moved
+// to match the trimmed audio.
 func (ac *ActivitiesClient) TrimSilence(ctx context.Context, input TrimSilenceInput) (*TrimSilenceOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
 	// Default values if not provided
 	silenceThreshold := input.SilenceThreshold
 	if silenceThreshold == 0 {
@@ -120,215 +243,1425 @@ func (ac *ActivitiesClient) TrimSilence(ctx context.Context, input TrimSilenceIn
 	if minSilenceDuration == 0 {
 		minSilenceDuration = 0.1 // Default 100ms minimum silence
 	}
+	outputFormat := input.OutputFormat
+	switch outputFormat {
+	case "":
+		outputFormat = OutputFormatWAV
+	case OutputFormatWAV, OutputFormatFLAC:
+	default:
+		return nil, invalidInputError("invalid output format %q: must be %q or %q", outputFormat, OutputFormatWAV, OutputFormatFLAC)
+	}
 
 	// Open and decode the source audio file
-	file, err := os.Open(input.SourcePath)
+	file, err := ac.storage.Open(ctx, input.SourcePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open source audio file: %w", err)
 	}
 	defer file.Close()
+	if _, err = ac.checkFileSize(file, input.SourcePath); err != nil {
+		return nil, err
+	}
+
+	// Compute the original content hash once, before decoding. It is reused
+	// for the no-trim result and for comparing against the trimmed output.
+	originalHash, err := ac.hashContent(file)
+	if err != nil {
+		return nil, err
+	}
 
-	decoder := wav.NewDecoder(file)
-	if !decoder.IsValidFile() {
-		return nil, fmt.Errorf("file is not a valid WAV file")
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return nil, audioFormatError(input.SourcePath, err)
 	}
 
 	format := decoder.Format()
 	sampleRate := int(format.SampleRate)
 	channels := int(format.NumChannels)
 
-	// Read all audio samples using FullPCMBuffer
-	buf, err := decoder.FullPCMBuffer()
+	// Read all audio samples at the source bit depth, so trimming doesn't
+	// truncate 24- and 32-bit audio
+	samples, bitDepth, err := decodeNativeSamples(decoder)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode audio: %w", err)
 	}
-	samples := buf.Data
+	if len(samples) == 0 {
+		return nil, emptyAudioError(input.SourcePath)
+	}
+
+	threshold, err := resolveSilenceThreshold(samples, channels, sampleRate, bitDepth, silenceThreshold, input.ThresholdMode, input.NoiseFloorMarginDB)
+	if err != nil {
+		return nil, err
+	}
+	silenceThreshold = threshold.threshold
 
-	// Find start and end of non-silent audio
-	startIdx, endIdx := findNonSilentRange(samples, channels, silenceThreshold, sampleRate, minSilenceDuration)
+	// Find start and end of non-silent audio. The scan runs under the hang
+	// detector, which cancels it if it stalls.
+	hangCtx, cancelHang := ac.detectHang(ctx, "TrimSilence")
+	defer cancelHang()
+	thresholdValue := silenceThresholdValue(silenceThreshold, bitDepth)
+	startIdx, endIdx, found, err := findNonSilentRange(hangCtx, samples, channels, thresholdValue, sampleRate, minSilenceDuration)
+	if err != nil {
+		if hangErr := hangError(ctx, err, "TrimSilence"); hangErr != nil {
+			return nil, hangErr
+		}
+		return nil, err
+	}
+
+	output := &TrimSilenceOutput{
+		ContentHash:      originalHash,
+		DryRun:           input.DryRun,
+		SilenceThreshold: silenceThreshold,
+	}
+	if input.ThresholdMode == ThresholdModeNoiseFloor {
+		output.NoiseFloorDB = peakDB(threshold.noiseFloor)
+	}
+
+	// Nothing is above the threshold: report it rather than a misleading
+	// "not trimmed", and leave it to the caller whether to keep the asset
+	if !found {
+		metrics.TrimOperations.WithLabelValues(metrics.TrimOutcomeAllSilent).Inc()
+		output.AllSilent = true
+		return output, nil
+	}
+
+	// A truncated final frame is never part of the range, so the range is
+	// compared against the whole frames
+	wholeFrames := len(samples) / channels * channels
+	output.LeadingSamplesRemoved = startIdx / channels
+	output.TrailingSamplesRemoved = (wholeFrames - endIdx) / channels
+	output.TrimmedDuration = float64((endIdx-startIdx)/channels) / float64(sampleRate)
 
 	// Check if trimming is needed
-	if startIdx == 0 && endIdx == len(samples) {
+	if startIdx == 0 && endIdx == wholeFrames {
 		// No trimming needed - audio has no leading/trailing silence
-		// Compute hash of original file
-		hash := sha256.New()
-		if _, err := file.Seek(0, 0); err != nil {
-			return nil, fmt.Errorf("failed to seek file: %w", err)
-		}
-		if _, err := io.Copy(hash, file); err != nil {
-			return nil, fmt.Errorf("failed to compute hash: %w", err)
+		metrics.TrimOperations.WithLabelValues(metrics.TrimOutcomeNoOp).Inc()
+		output.NoOp = true
+		if input.CopyOnNoOp && !input.DryRun {
+			if output.OutputPath, err = ac.copyUntrimmed(ctx, file, input); err != nil {
+				return nil, err
+			}
 		}
-		contentHash := hex.EncodeToString(hash.Sum(nil))
+		return output, nil
+	}
 
-		return &TrimSilenceOutput{
-			ContentHash: contentHash,
-			WasTrimmed:  false,
-			NoOp:        true,
-		}, nil
+	// In dry-run mode only report what would be trimmed, without writing anything
+	if input.DryRun {
+		output.WasTrimmed = true
+		return output, nil
 	}
 
 	// Trim the samples
 	trimmedSamples := samples[startIdx:endIdx]
 
+	// Ramp the edges so the cut points don't click
+	applyFade(trimmedSamples, channels, sampleRate, input.FadeInMs, input.FadeOutMs)
+
 	// Create output file path
-	outputDir := filepath.Dir(input.SourcePath)
-	outputPath := filepath.Join(outputDir, fmt.Sprintf("trimmed_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, trimmedFileName(input.AssetID, "."+outputFormat))
 
-	// Write trimmed audio to new file
-	outputFile, err := os.Create(outputPath)
+	write := ac.writeWAV
+	switch {
+	case outputFormat == OutputFormatFLAC:
+		write = ac.writeFLAC
+	case decoder.container == containerWAV:
+		// Carry the source's metadata chunks over, with their sample
+		// positions moved to match the trimmed audio
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind source audio file: %w", err)
+		}
+		chunks, chunksErr := readExtraChunks(file)
+		if chunksErr != nil {
+			return nil, fmt.Errorf("failed to read metadata chunks: %w", chunksErr)
+		}
+		chunks = trimChunks(chunks, startIdx/channels, len(trimmedSamples)/channels)
+		write = func(ctx context.Context, outputPath string, format *audio.Format, samples []int, bitDepth int) (string, string, error) {
+			return ac.writeWAVWithChunks(ctx, outputPath, format, samples, bitDepth, chunks)
+		}
+	}
+	outputPath, contentHash, err := write(ctx, outputPath, format, trimmedSamples, bitDepth)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create output file: %w", err)
+		return nil, err
+	}
+	// Read the stored file back before it is registered, so a corrupt write
+	// fails here instead of surfacing when the asset is next decoded
+	if err = ac.verifyOutput(ctx, outputPath, format, bitDepth, trimmedSamples); err != nil {
+		if removeErr := ac.storage.Remove(ctx, outputPath); removeErr != nil {
+			activity.GetLogger(ctx).Warn("Failed to remove unverified trimmed file", "path", outputPath, "error", removeErr)
+		}
+		return nil, err
 	}
-	defer outputFile.Close()
 
-	// Create encoder - use 16-bit depth as default
-	bitDepth := 16
-	encoder := wav.NewEncoder(outputFile, sampleRate, bitDepth, channels, 1) // 1 = PCM encoding
-	trimmedBuf := &audio.IntBuffer{
-		Format: format,
-		Data:   trimmedSamples,
+	output.ContentHash = contentHash
+	output.WasTrimmed = true
+	output.OutputPath = outputPath
+
+	// If hashes are different, create new asset
+	if contentHash != originalHash {
+		if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+			return nil, err
+		}
+		metrics.TrimOperations.WithLabelValues(metrics.TrimOutcomeTrimmed).Inc()
+	} else {
+		// Hashes are identical (shouldn't happen if we trimmed, but handle it)
+		// Remove the output file since it's identical to the original, unless
+		// the caller wants a copy either way
+		if !input.CopyOnNoOp {
+			if removeErr := ac.storage.Remove(ctx, outputPath); removeErr != nil {
+				activity.GetLogger(ctx).Warn("Failed to remove duplicate trimmed file", "path", outputPath, "error", removeErr)
+			}
+			output.OutputPath = ""
+		}
+		output.NoOp = true
+		metrics.TrimOperations.WithLabelValues(metrics.TrimOutcomeNoOp).Inc()
 	}
 
-	if err := encoder.Write(trimmedBuf); err != nil {
-		return nil, fmt.Errorf("failed to encode trimmed audio: %w", err)
+	return output, nil
+}
+
+// verifyFrames is how many frames verifyOutput compares after the header
+const verifyFrames = 4096
+
+// verifyOutput reopens a file written from samples and checks that it decodes
+// to them: the header must give the same sample rate, channels, bit depth and
+// length, and the first verifyFrames frames must match. The rest isn't read,
+// to keep the check cheap next to the write.
+func (ac *ActivitiesClient) verifyOutput(ctx context.Context, path string, format *audio.Format, bitDepth int, samples []int) error {
+	file, err := ac.storage.Open(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to reopen %s for verification: %w", path, err)
 	}
+	defer file.Close()
 
-	if err := encoder.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close encoder: %w", err)
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return fmt.Errorf("written file %s is not decodable: %w", path, err)
+	}
+	got := decoder.Format()
+	if got.SampleRate != format.SampleRate || got.NumChannels != format.NumChannels || decoder.BitDepth() != bitDepth {
+		return fmt.Errorf("written file %s has format %d Hz, %d channels, %d-bit, expected %d Hz, %d channels, %d-bit", path,
+			got.SampleRate, got.NumChannels, decoder.BitDepth(), format.SampleRate, format.NumChannels, bitDepth)
+	}
+	frames, err := decoder.Frames()
+	if err != nil {
+		return fmt.Errorf("written file %s is not decodable: %w", path, err)
+	}
+	if frames != len(samples)/format.NumChannels {
+		return fmt.Errorf("written file %s holds %d frames, expected %d", path, frames, len(samples)/format.NumChannels)
 	}
 
-	// Compute hash of trimmed file
-	hash := sha256.New()
-	if _, err := outputFile.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek output file: %w", err)
+	expected := samples[:min(len(samples), verifyFrames*format.NumChannels)]
+	decoded := make([]int, len(expected))
+	for read := 0; read < len(decoded); {
+		n, err := decoder.PCMBuffer(&audio.IntBuffer{Format: format, Data: decoded[read:]})
+		if err != nil {
+			return fmt.Errorf("written file %s is not decodable: %w", path, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("written file %s ended after %d samples, expected %d", path, read, len(expected))
+		}
+		read += n
 	}
-	if _, err := io.Copy(hash, outputFile); err != nil {
-		return nil, fmt.Errorf("failed to compute hash: %w", err)
+	if !slices.Equal(decoded, expected) {
+		return fmt.Errorf("written file %s decodes to different samples than were written", path)
 	}
-	contentHash := hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
 
-	// Compare with original hash
-	originalHash := ""
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek original file: %w", err)
+// trimmedFileName returns the name of TrimSilence's output file for an asset,
+// with the given extension
+func trimmedFileName(assetID, ext string) string {
+	return fmt.Sprintf("trimmed_%s_%s%s", assetID, time.Now().Format("20060102_150405"), ext)
+}
+
+// DiscardTrimmedOutput removes a file written by TrimSilence and soft-deletes
+// the asset created for it, e.g. when the workflow that trimmed it is
+// cancelled. A file or asset that is already gone is not an error, so the
+// activity can be retried.
+func (ac *ActivitiesClient) DiscardTrimmedOutput(ctx context.Context, input DiscardTrimmedOutputInput) error {
+	if err := ac.storage.Remove(ctx, input.FilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove trimmed file %s: %w", input.FilePath, err)
 	}
-	origHash := sha256.New()
-	if _, err := io.Copy(origHash, file); err != nil {
-		return nil, fmt.Errorf("failed to compute original hash: %w", err)
+	if input.AssetID == "" || ac.dbClient == nil {
+		return nil
 	}
-	originalHash = hex.EncodeToString(origHash.Sum(nil))
+	if err := ac.dbClient.SoftDeleteAsset(input.AssetID); err != nil && !errors.Is(err, database.ErrAssetNotFound) {
+		return fmt.Errorf("failed to delete trimmed asset %s: %w", input.AssetID, err)
+	}
+	return nil
+}
 
-	output := &TrimSilenceOutput{
-		ContentHash: contentHash,
-		WasTrimmed:  true,
-		NoOp:        false,
-		OutputPath:  outputPath,
+// copyUntrimmed copies the source file unchanged into TrimSilence's output
+// directory, so callers get an output file even when nothing was trimmed. The
+// copy keeps the source's extension, as it isn't re-encoded to WAV.
+func (ac *ActivitiesClient) copyUntrimmed(ctx context.Context, src io.ReadSeeker, input TrimSilenceInput) (outputPath string, err error) {
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return "", err
 	}
+	outputPath = storage.Join(outputDir, trimmedFileName(input.AssetID, filepath.Ext(input.SourcePath)))
 
-	// If hashes are different, create new asset
-	if contentHash != originalHash {
-		newAssetID := uuid.New().String()
-		output.NewAssetID = newAssetID
-
-		// Store trimmed asset in database
-		if ac.dbClient != nil {
-			activityInfo := activity.GetInfo(ctx)
-			parentAssetID := input.AssetID
-			dbAsset := &database.Asset{
-				ID:            newAssetID,
-				WorkflowID:    activityInfo.WorkflowExecution.ID,
-				WorkflowRunID: activityInfo.WorkflowExecution.RunID,
-				ParentAssetID: &parentAssetID,
-				FilePath:      outputPath,
-				ContentHash:   contentHash,
-				CreatedAt:     time.Now(),
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind source file: %w", err)
+	}
+	outputFile, outputPath, err := ac.createOutput(ctx, outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer ac.closeOutput(ctx, outputFile, outputPath, &err)
+
+	if _, err = io.Copy(outputFile, src); err != nil {
+		return "", fmt.Errorf("failed to copy source file: %w", err)
+	}
+	return outputPath, nil
+}
+
+// DetectSegments finds every non-silent segment of an audio file, splitting on
+// silent gaps of at least MinSilenceDuration anywhere in the audio. If
+// SplitFiles is set, each segment is also written out as a new child asset.
+func (ac *ActivitiesClient) DetectSegments(ctx context.Context, input DetectSegmentsInput) (*DetectSegmentsOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	// Default values if not provided
+	silenceThreshold := input.SilenceThreshold
+	if silenceThreshold == 0 {
+		silenceThreshold = 0.01 // Default 1% threshold
+	}
+	minSilenceDuration := input.MinSilenceDuration
+	if minSilenceDuration == 0 {
+		minSilenceDuration = 0.1 // Default 100ms minimum silence
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := format.SampleRate
+	channels := format.NumChannels
+
+	ranges := findNonSilentSegments(samples, channels, silenceThreshold, sampleRate, minSilenceDuration)
+	if input.MinSegmentDuration > 0 {
+		ranges = dropShortSegments(ranges, channels*int(float64(sampleRate)*input.MinSegmentDuration))
+	}
+
+	var outputDir string
+	if input.SplitFiles && len(ranges) > 0 {
+		outputDir, err = ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp := time.Now().Format("20060102_150405")
+	output := &DetectSegmentsOutput{}
+	segments := make([]AudioSegment, 0, len(ranges))
+	for i, r := range ranges {
+		segment := AudioSegment{
+			StartSample: r.start / channels,
+			EndSample:   r.end / channels,
+			StartTime:   float64(r.start/channels) / float64(sampleRate),
+			EndTime:     float64(r.end/channels) / float64(sampleRate),
+		}
+
+		if input.SplitFiles {
+			outputPath := storage.Join(outputDir, fmt.Sprintf("segment_%s_%03d_%s.wav", input.AssetID, i, timestamp))
+			outputPath, contentHash, writeErr := ac.writeWAV(ctx, outputPath, format, samples[r.start:r.end], normalizedBitDepth)
+			if writeErr != nil {
+				// Not wrapped, so an OutputExists failure stays non-retryable;
+				// the error already names the segment's file
+				return nil, writeErr
 			}
-			if err := ac.dbClient.InsertAsset(dbAsset); err != nil {
-				// Log error but don't fail the activity
-				activity.GetLogger(ctx).Error("Failed to insert trimmed asset into database", "error", err)
+			segment.FilePath = outputPath
+			segment.ContentHash = contentHash
+			if segment.AssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+				return nil, err
 			}
 		}
-	} else {
-		// Hashes are identical (shouldn't happen if we trimmed, but handle it)
+
+		segments = append(segments, segment)
+	}
+
+	output.Segments = segments
+	return output, nil
+}
+
+// TrimToRange clips an audio file to an explicit time range, writing the
+// frames from StartSeconds up to EndSeconds as a new child asset. Times are
+// rounded to the nearest frame.
+func (ac *ActivitiesClient) TrimToRange(ctx context.Context, input TrimToRangeInput) (*TrimToRangeOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if input.StartSeconds < 0 {
+		return nil, invalidInputError("start time must not be negative, got %.3fs", input.StartSeconds)
+	}
+	if input.StartSeconds >= input.EndSeconds {
+		return nil, invalidInputError("start time %.3fs must be before end time %.3fs", input.StartSeconds, input.EndSeconds)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := format.SampleRate
+	channels := format.NumChannels
+
+	frames := len(samples) / channels
+	startFrame := int(math.Round(input.StartSeconds * float64(sampleRate)))
+	endFrame := int(math.Round(input.EndSeconds * float64(sampleRate)))
+	if endFrame > frames {
+		return nil, invalidInputError("end time %.3fs is past the end of the audio (%.3fs)",
+			input.EndSeconds, float64(frames)/float64(sampleRate))
+	}
+	if startFrame >= endFrame {
+		return nil, invalidInputError("range %.3fs-%.3fs is shorter than one sample", input.StartSeconds, input.EndSeconds)
+	}
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("range_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, format, samples[startFrame*channels:endFrame*channels], normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &TrimToRangeOutput{
+		ContentHash: contentHash,
+		OutputPath:  outputPath,
+		StartSample: startFrame,
+		EndSample:   endFrame,
+		Duration:    float64(endFrame-startFrame) / float64(sampleRate),
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// TrimByRanges cuts an audio file down to an explicit list of ranges to keep,
+// e.g. from silence regions a UI detected, and joins them in order into a new
+// child asset. Times are rounded to the nearest frame. With CrossfadeMs, each
+// join crossfades linearly from the end of one range into the start of the
+// next, which overlaps them and so shortens the output by the crossfade.
+func (ac *ActivitiesClient) TrimByRanges(ctx context.Context, input TrimByRangesInput) (*TrimByRangesOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if len(input.Ranges) == 0 {
+		return nil, invalidInputError("at least one range to keep is required")
+	}
+	if input.CrossfadeMs < 0 {
+		return nil, invalidInputError("crossfade must not be negative, got %dms", input.CrossfadeMs)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := format.SampleRate
+	channels := format.NumChannels
+
+	frames := len(samples) / channels
+	kept := make([]KeepRange, len(input.Ranges))
+	ranges := make([]sampleRange, len(input.Ranges))
+	previousEnd := 0
+	for i, r := range input.Ranges {
+		startFrame, endFrame := r.StartSample, r.EndSample
+		if r.EndSample == 0 {
+			startFrame = int(math.Round(r.StartSeconds * float64(sampleRate)))
+			endFrame = int(math.Round(r.EndSeconds * float64(sampleRate)))
+		} else if r.StartSeconds != 0 || r.EndSeconds != 0 {
+			return nil, invalidInputError("range %d is given in both seconds and samples", i)
+		}
+		switch {
+		case startFrame < 0 || startFrame >= endFrame:
+			return nil, invalidInputError("range %d (frames %d-%d) must start at or after 0 and before its end", i, startFrame, endFrame)
+		case endFrame > frames:
+			return nil, invalidInputError("range %d ends at frame %d, past the end of the audio (%d frames)", i, endFrame, frames)
+		case startFrame < previousEnd:
+			return nil, invalidInputError("range %d starts at frame %d, before the end of the previous range (%d)", i, startFrame, previousEnd)
+		}
+		previousEnd = endFrame
+
+		kept[i] = KeepRange{
+			StartSeconds: float64(startFrame) / float64(sampleRate),
+			EndSeconds:   float64(endFrame) / float64(sampleRate),
+			StartSample:  startFrame,
+			EndSample:    endFrame,
+		}
+		ranges[i] = sampleRange{start: startFrame * channels, end: endFrame * channels}
+	}
+
+	joined := joinRanges(samples, channels, ranges, sampleRate*input.CrossfadeMs/1000)
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("ranges_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, format, joined, normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &TrimByRangesOutput{
+		ContentHash: contentHash,
+		OutputPath:  outputPath,
+		Ranges:      kept,
+		Duration:    float64(len(joined)/channels) / float64(sampleRate),
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ExtractChannel writes one channel of a multi-channel recording, e.g. one lav
+// mic of a field recording, as a mono WAV registered as a child asset. The
+// channel index is checked against the header before anything is decoded.
+// 24- and 32-bit PCM keeps its bit depth, like TrimSilence's output.
+func (ac *ActivitiesClient) ExtractChannel(ctx context.Context, input ExtractChannelInput) (*ExtractChannelOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+
+	file, err := ac.storage.Open(ctx, input.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source audio file: %w", err)
+	}
+	defer file.Close()
+	if _, err = ac.checkFileSize(file, input.SourcePath); err != nil {
+		return nil, err
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return nil, audioFormatError(input.SourcePath, err)
+	}
+	format := decoder.Format()
+	channels := format.NumChannels
+	if input.Channel < 0 || input.Channel >= channels {
+		return nil, invalidInputError("channel %d doesn't exist in %s, which has %d channels (0 to %d)",
+			input.Channel, input.SourcePath, channels, channels-1)
+	}
+
+	samples, bitDepth, err := decodeNativeSamples(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	frames := len(samples) / channels
+	if frames == 0 {
+		return nil, emptyAudioError(input.SourcePath)
+	}
+	mono := make([]int, frames)
+	for frame := range mono {
+		mono[frame] = samples[frame*channels+input.Channel]
+	}
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("channel%d_%s_%s.wav", input.Channel, input.AssetID, time.Now().Format("20060102_150405")))
+
+	monoFormat := &audio.Format{NumChannels: 1, SampleRate: format.SampleRate}
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, monoFormat, mono, bitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ExtractChannelOutput{
+		ContentHash: contentHash,
+		OutputPath:  outputPath,
+		Channel:     input.Channel,
+		Channels:    channels,
+		BitDepth:    bitDepth,
+		Duration:    float64(frames) / float64(format.SampleRate),
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ApplyGain scales every sample of an audio file by a fixed gain in dB and
+// writes the result as a new child asset. 24- and 32-bit PCM keeps its bit
+// depth, like TrimSilence's output. Samples pushed past the range of the
+// output bit depth are clamped rather than wrapped, and the number clamped is
+// reported and stored as the "apply_gain" feature of the new asset.
+func (ac *ActivitiesClient) ApplyGain(ctx context.Context, input ApplyGainInput) (*ApplyGainOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if math.IsNaN(input.GainDB) || math.IsInf(input.GainDB, 0) {
+		return nil, invalidInputError("gain must be a finite number of dB, got %v", input.GainDB)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, bitDepth, err := ac.loadNativeSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	clipped := applyGain(samples, math.Pow(10, input.GainDB/20), bitDepth)
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("gain_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, format, samples, bitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ApplyGainOutput{
+		ContentHash:    contentHash,
+		OutputPath:     outputPath,
+		GainDB:         input.GainDB,
+		Clipped:        clipped > 0,
+		ClippedSamples: clipped,
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, output.NewAssetID, "apply_gain",
+		map[string]interface{}{
+			"clipped":         output.Clipped,
+			"clipped_samples": output.ClippedSamples,
+		},
+		map[string]interface{}{
+			"gain_db":         input.GainDB,
+			"source_asset_id": input.AssetID,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// ApplyFilter runs an audio file through a Butterworth high-pass or low-pass
+// filter, e.g. to remove rumble or hiss before feature extraction, and writes
+// the result as a new child asset. Each channel is filtered on its own. The
+// filter type, cutoff and order are stored as the "filter" feature of the new
+// asset.
+func (ac *ActivitiesClient) ApplyFilter(ctx context.Context, input ApplyFilterInput) (*ApplyFilterOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if input.FilterType != FilterHighPass && input.FilterType != FilterLowPass {
+		return nil, invalidInputError("invalid filter type %q: must be %q or %q", input.FilterType, FilterHighPass, FilterLowPass)
+	}
+	order := input.Order
+	if order == 0 {
+		order = defaultFilterOrder
+	}
+	if order < 1 || order > maxFilterOrder {
+		return nil, invalidInputError("filter order must be from 1 to %d, got %d", maxFilterOrder, order)
+	}
+	if !(input.CutoffHz > 0) || math.IsInf(input.CutoffHz, 0) {
+		return nil, invalidInputError("cutoff must be a positive frequency, got %v Hz", input.CutoffHz)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if nyquist := float64(format.SampleRate) / 2; input.CutoffHz >= nyquist {
+		return nil, invalidInputError("cutoff %v Hz must be below the Nyquist frequency of %v Hz", input.CutoffHz, nyquist)
+	}
+
+	sections := butterworthSections(input.FilterType, order, input.CutoffHz, float64(format.SampleRate))
+	clipped := applyFilter(samples, format.NumChannels, sections)
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("%s_%s_%s.wav", input.FilterType, input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, format, samples, normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ApplyFilterOutput{
+		ContentHash:    contentHash,
+		OutputPath:     outputPath,
+		FilterType:     input.FilterType,
+		CutoffHz:       input.CutoffHz,
+		Order:          order,
+		ClippedSamples: clipped,
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, output.NewAssetID, "filter",
+		map[string]interface{}{
+			"filter_type":     output.FilterType,
+			"cutoff_hz":       output.CutoffHz,
+			"order":           output.Order,
+			"clipped_samples": output.ClippedSamples,
+		},
+		map[string]interface{}{
+			"source_asset_id": input.AssetID,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// NormalizeAudio scales an audio file so its sample peak sits at TargetPeakDB
+// and writes the result as a new child asset. The gain applied and the source
+// peak are stored as the "normalize" feature of the new asset. Digital silence
+// has no peak to normalize and is rejected.
+func (ac *ActivitiesClient) NormalizeAudio(ctx context.Context, input NormalizeAudioInput) (*NormalizeAudioOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	targetPeakDB := DefaultTargetPeakDB
+	if input.TargetPeakDB != nil {
+		targetPeakDB = *input.TargetPeakDB
+	}
+	if math.IsNaN(targetPeakDB) || targetPeakDB > 0 || math.IsInf(targetPeakDB, 0) {
+		return nil, invalidInputError("target peak must be a finite level of at most 0 dBFS, got %v", targetPeakDB)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	peak := 0
+	for _, sample := range samples {
+		peak = max(peak, absInt(sample))
+	}
+	if peak == 0 {
+		return nil, invalidInputError("%s is digital silence and can't be normalized", input.SourcePath)
+	}
+
+	// Same scale as ComputeTruePeak, so a full-scale negative sample is 0 dBFS
+	sourcePeakDB := 20 * math.Log10(float64(peak)/32768.0)
+	gainDB := targetPeakDB - sourcePeakDB
+	applyGain(samples, math.Pow(10, gainDB/20), normalizedBitDepth)
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("normalized_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, format, samples, normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &NormalizeAudioOutput{
+		ContentHash:  contentHash,
+		OutputPath:   outputPath,
+		SourcePeakDB: sourcePeakDB,
+		TargetPeakDB: targetPeakDB,
+		GainDB:       gainDB,
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, output.NewAssetID, "normalize",
+		map[string]interface{}{
+			"source_peak_db": output.SourcePeakDB,
+			"gain_db":        output.GainDB,
+		},
+		map[string]interface{}{
+			"target_peak_db":  targetPeakDB,
+			"source_asset_id": input.AssetID,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// Resample converts an audio file to TargetSampleRate and writes the result
+// as a new child asset. A file already at the target rate is left alone:
+// the output reports NoOp and no file or asset is created. The rates are
+// stored as the "resample" feature of the new asset.
+func (ac *ActivitiesClient) Resample(ctx context.Context, input ResampleInput) (*ResampleOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if input.TargetSampleRate < 1 || input.TargetSampleRate > maxResampleRate {
+		return nil, invalidInputError("target sample rate must be from 1 to %d Hz, got %d", maxResampleRate, input.TargetSampleRate)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	channels := format.NumChannels
+
+	output := &ResampleOutput{
+		SourceSampleRate: format.SampleRate,
+		TargetSampleRate: input.TargetSampleRate,
+	}
+	if format.SampleRate == input.TargetSampleRate {
+		output.Duration = float64(len(samples)/channels) / float64(format.SampleRate)
 		output.NoOp = true
-		// Clean up the output file since it's identical
-		os.Remove(outputPath)
-		output.OutputPath = ""
+		return output, nil
+	}
+
+	resampled, clipped := resampleSamples(samples, channels, format.SampleRate, input.TargetSampleRate)
+	if len(resampled) == 0 {
+		return nil, invalidInputError("%s is too short to resample to %d Hz", input.SourcePath, input.TargetSampleRate)
+	}
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("resampled_%s_%s.wav", input.AssetID, time.Now().Format("20060102_150405")))
+
+	outputFormat := &audio.Format{NumChannels: channels, SampleRate: input.TargetSampleRate}
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, outputFormat, resampled, normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetID, outputPath, contentHash); err != nil {
+		return nil, err
+	}
+	output.ContentHash = contentHash
+	output.OutputPath = outputPath
+	output.Duration = float64(len(resampled)/channels) / float64(input.TargetSampleRate)
+	output.ClippedSamples = clipped
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, output.NewAssetID, "resample",
+		map[string]interface{}{
+			"source_sample_rate": output.SourceSampleRate,
+			"target_sample_rate": output.TargetSampleRate,
+			"clipped_samples":    output.ClippedSamples,
+		},
+		map[string]interface{}{
+			"source_asset_id": input.AssetID,
+		},
+	); err != nil {
+		return nil, err
 	}
 
 	return output, nil
 }
 
-// findNonSilentRange finds the start and end indices of non-silent audio
-func findNonSilentRange(samples []int, channels int, threshold float64, sampleRate int, minSilenceDuration float64) (int, int) {
-	if len(samples) == 0 {
-		return 0, 0
+// ConcatenateAudio joins several audio files end to end into one WAV. All
+// sources must share sample rate, channel count, and encoding. The combined
+// file becomes a new asset whose parent is the first source; every source is
+// recorded in order in asset_parents (see database.Asset).
+func (ac *ActivitiesClient) ConcatenateAudio(ctx context.Context, input ConcatenateAudioInput) (*ConcatenateAudioOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	if len(input.SourcePaths) < 2 {
+		return nil, invalidInputError("at least two source files are required, got %d", len(input.SourcePaths))
+	}
+	if len(input.AssetIDs) != len(input.SourcePaths) {
+		return nil, invalidInputError("expected one asset ID per source file, got %d asset IDs for %d files",
+			len(input.AssetIDs), len(input.SourcePaths))
 	}
 
-	// Convert threshold to sample value (assuming 16-bit audio, range -32768 to 32767)
-	maxSampleValue := 32767.0
-	thresholdValue := int(threshold * maxSampleValue)
+	// One slot covers every source, as they are all held in memory together
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var first *decodedAudio
+	var combined []int
+	for i, path := range input.SourcePaths {
+		decoded, err := ac.loadAudio(ctx, path)
+		if IsInvalidAudio(err) || IsEmptyAudio(err) {
+			return nil, err // already names the file, and wrapping would make it retryable
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load source %d (%s): %w", i, path, err)
+		}
+		if first == nil {
+			first = decoded
+		} else if err = checkSameFormat(first, decoded); err != nil {
+			return nil, invalidInputError("source %d (%s) doesn't match %s: %v", i, path, input.SourcePaths[0], err)
+		}
+		combined = append(combined, decoded.samples...)
+	}
 
-	// Minimum samples of silence to consider
-	minSilenceSamples := int(float64(sampleRate) * minSilenceDuration)
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.SourcePaths[0])
+	if err != nil {
+		return nil, err
+	}
+	outputPath := storage.Join(outputDir, fmt.Sprintf("concat_%s_%s.wav", input.AssetIDs[0], time.Now().Format("20060102_150405")))
 
-	// Find start (skip leading silence)
-	startIdx := 0
-	for i := 0; i < len(samples)-channels; i += channels {
-		// Check if any channel in this frame is above threshold
-		isSilent := true
-		for ch := 0; ch < channels; ch++ {
-			if i+ch < len(samples) {
-				absValue := samples[i+ch]
-				if absValue < 0 {
-					absValue = -absValue
-				}
-				if absValue > thresholdValue {
-					isSilent = false
-					break
-				}
+	outputPath, contentHash, err := ac.writeWAV(ctx, outputPath, first.format, combined, normalizedBitDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &ConcatenateAudioOutput{
+		ContentHash: contentHash,
+		OutputPath:  outputPath,
+		Duration:    float64(len(combined)/first.format.NumChannels) / float64(first.format.SampleRate),
+	}
+	if output.NewAssetID, err = ac.recordDerivedAsset(ctx, &output.DBStatus, input.AssetIDs[0], outputPath, contentHash); err != nil {
+		return nil, err
+	}
+	if ac.dbClient != nil {
+		if err = ac.dbClient.InsertAssetParents(ctx, output.NewAssetID, input.AssetIDs); err != nil {
+			metrics.DBInsertErrors.WithLabelValues("asset_parents").Inc()
+			if err = ac.dbError(ctx, &output.DBStatus, "insert asset parents", err); err != nil {
+				return nil, err
 			}
 		}
-		if !isSilent {
-			startIdx = i
+	}
+
+	return output, nil
+}
+
+// checkSameFormat returns an error describing the first difference between
+// two decoded files that prevents joining them
+func checkSameFormat(a, b *decodedAudio) error {
+	switch {
+	case a.format.SampleRate != b.format.SampleRate:
+		return fmt.Errorf("sample rate %d Hz differs from %d Hz", b.format.SampleRate, a.format.SampleRate)
+	case a.format.NumChannels != b.format.NumChannels:
+		return fmt.Errorf("%d channels differ from %d", b.format.NumChannels, a.format.NumChannels)
+	case a.bitDepth != b.bitDepth:
+		return fmt.Errorf("bit depth %d differs from %d", b.bitDepth, a.bitDepth)
+	case a.encoding != b.encoding:
+		return fmt.Errorf("encoding %s differs from %s", b.encoding, a.encoding)
+	}
+	return nil
+}
+
+// SplitOnSilence splits a recording into clips at silence boundaries, writing
+// one WAV per clip and registering each as a child asset of the original.
+// Clips shorter than MinSegmentDuration are skipped.
+func (ac *ActivitiesClient) SplitOnSilence(ctx context.Context, input SplitOnSilenceInput) (*SplitOnSilenceOutput, error) {
+	detected, err := ac.DetectSegments(ctx, DetectSegmentsInput{
+		AssetID:            input.AssetID,
+		SourcePath:         input.SourcePath,
+		SilenceThreshold:   input.SilenceThreshold,
+		MinSilenceDuration: input.MinSilenceDuration,
+		MinSegmentDuration: input.MinSegmentDuration,
+		SplitFiles:         true,
+		OutputDir:          input.OutputDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SplitOnSilenceOutput{
+		Clips:    detected.Segments,
+		DBStatus: detected.DBStatus,
+	}, nil
+}
+
+// writeWAV encodes samples as a PCM WAV file of the given bit depth (8, 16, 24
+// or 32, and 16 for samples from decodeSamples) and returns the path it was
+// written to, which the overwrite policy may have changed, and its content
+// hash. The file is removed if writing fails or the activity is cancelled.
+func (ac *ActivitiesClient) writeWAV(ctx context.Context, outputPath string, format *audio.Format,
+	samples []int, bitDepth int) (writtenPath, contentHash string, err error) {
+	return ac.writeWAVWithChunks(ctx, outputPath, format, samples, bitDepth, nil)
+}
+
+// writeWAVWithChunks is writeWAV for a file that also carries the given
+// chunks, e.g. metadata copied from the source by readExtraChunks. They are
+// written after the data chunk, where the encoder puts its own LIST chunk.
+func (ac *ActivitiesClient) writeWAVWithChunks(ctx context.Context, outputPath string, format *audio.Format,
+	samples []int, bitDepth int, chunks []wavChunk) (writtenPath, contentHash string, err error) {
+	outputFile, outputPath, err := ac.createOutput(ctx, outputPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer ac.closeOutput(ctx, outputFile, outputPath, &err)
+
+	if err = ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	// The encoder writes each sample's low bitDepth bits as is, so samples
+	// must already be at that depth
+	encoder := wav.NewEncoder(outputFile, int(format.SampleRate), bitDepth, format.NumChannels, 1) // 1 = PCM encoding
+	if err = encoder.Write(&audio.IntBuffer{Format: format, Data: samples, SourceBitDepth: bitDepth}); err != nil {
+		return "", "", fmt.Errorf("failed to encode audio: %w", err)
+	}
+	if len(chunks) > 0 {
+		// Chunks start at even offsets, so an odd-sized data chunk is padded
+		if len(samples)*bitDepth/8%2 == 1 {
+			err = encoder.AddLE(uint8(0))
+		}
+		for _, chunk := range chunks {
+			if err == nil {
+				err = writeChunk(encoder, chunk)
+			}
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to write metadata chunks: %w", err)
+		}
+	}
+	if err = encoder.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to close encoder: %w", err)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	// Compute hash of the written file
+	if _, err = outputFile.Seek(0, 0); err != nil {
+		return "", "", fmt.Errorf("failed to seek output file: %w", err)
+	}
+	contentHash, err = ac.hashContent(outputFile)
+	if err != nil {
+		return "", "", err
+	}
+	return outputPath, contentHash, nil
+}
+
+// writeFLAC encodes samples as a FLAC file of the given bit depth, like
+// writeWAV. The stream is decoded again before it is written, so a file that
+// doesn't hold exactly the given samples is never stored. The content hash is
+// of the FLAC bytes.
+func (ac *ActivitiesClient) writeFLAC(ctx context.Context, outputPath string, format *audio.Format,
+	samples []int, bitDepth int) (writtenPath, contentHash string, err error) {
+	var encoded bytes.Buffer
+	if err = flac.Encode(&encoded, samples, format.SampleRate, format.NumChannels, bitDepth); err != nil {
+		return "", "", fmt.Errorf("failed to encode audio: %w", err)
+	}
+	if err = checkLossless(encoded.Bytes(), samples); err != nil {
+		return "", "", err
+	}
+
+	outputFile, outputPath, err := ac.createOutput(ctx, outputPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer ac.closeOutput(ctx, outputFile, outputPath, &err)
+
+	if err = ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if _, err = outputFile.Write(encoded.Bytes()); err != nil {
+		return "", "", fmt.Errorf("failed to write output file: %w", err)
+	}
+	contentHash, err = ac.hashContent(bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		return "", "", err
+	}
+	return outputPath, contentHash, nil
+}
+
+// checkLossless decodes a FLAC stream and checks it holds exactly samples
+func checkLossless(encoded []byte, samples []int) error {
+	decoder, err := flac.NewDecoder(bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to verify FLAC encoding: %w", err)
+	}
+	decoded, err := decoder.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to verify FLAC encoding: %w", err)
+	}
+	if !slices.Equal(decoded, samples) {
+		return errors.New("FLAC encoding is not lossless: decoded samples differ from the input")
+	}
+	return nil
+}
+
+// hashContent hashes everything from the current position to the end of r in
+// a single pass with the configured algorithm (see newContentHash), then
+// rewinds r to the start. Memory-mapping a 1 GiB file hashed it with SHA-256
+// at most ~14% faster than io.Copy, a small fraction of the time spent
+// decoding the same file, so files are read the portable way.
+func (ac *ActivitiesClient) hashContent(r io.ReadSeeker) (string, error) {
+	hash, prefix := ac.newContentHash()
+	hashedBytes, err := io.Copy(hash, r)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute hash: %w", err)
+	}
+	metrics.BytesHashed.Add(float64(hashedBytes))
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to seek file: %w", err)
+	}
+	return prefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// recordDerivedAsset stores a new asset derived from parentAssetID and returns
+// its ID. A database error is handled by the failure policy (see dbError); if
+// that fails the activity, the file is removed too, so retried attempts don't
+// leave copies of it behind.
+func (ac *ActivitiesClient) recordDerivedAsset(ctx context.Context, status *DBStatus, parentAssetID, filePath, contentHash string) (string, error) {
+	newAssetID := uuid.New().String()
+	if ac.dbClient == nil {
+		return newAssetID, nil
+	}
+
+	activityInfo := activity.GetInfo(ctx)
+	dbAsset := &database.Asset{
+		ID:            newAssetID,
+		WorkflowID:    activityInfo.WorkflowExecution.ID,
+		WorkflowRunID: activityInfo.WorkflowExecution.RunID,
+		ParentAssetID: &parentAssetID,
+		FilePath:      filePath,
+		ContentHash:   contentHash,
+		CreatedAt:     time.Now(),
+	}
+	if err := ac.dbClient.InsertAssetContext(ctx, dbAsset); err != nil {
+		metrics.DBInsertErrors.WithLabelValues("assets").Inc()
+		if err = ac.dbError(ctx, status, "insert derived asset", err); err != nil {
+			if removeErr := ac.storage.Remove(ctx, filePath); removeErr != nil {
+				activity.GetLogger(ctx).Warn("Failed to remove output file", "path", filePath, "error", removeErr)
+			}
+			return "", err
+		}
+	}
+	return newAssetID, nil
+}
+
+// resolveOutputDir returns the directory derived files should be written to,
+// defaulting to the source file's directory, and creates it if needed
+func (ac *ActivitiesClient) resolveOutputDir(ctx context.Context, outputDir, sourcePath string) (string, error) {
+	if outputDir == "" {
+		outputDir = storage.Dir(sourcePath)
+	}
+	if err := ac.storage.MkdirAll(ctx, outputDir); err != nil {
+		return "", fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+	}
+	return outputDir, nil
+}
+
+// findNonSilentRange finds the start and end indices of non-silent audio.
+// Indices always fall on frame boundaries. A truncated final frame (fewer
+// samples than channels) isn't a whole frame, so it never counts as sound and
+// is left out of the range. thresholdValue is at the samples' bit depth (see
+// silenceThresholdValue). found is false if every frame is below the
+// threshold, in which case the range is empty (0, 0), so a caller that ignores
+// found can't mistake an all-silent file for one with nothing to trim. The
+// scan stops with ctx's error once ctx is done.
+func findNonSilentRange(ctx context.Context, samples []int, channels, thresholdValue, sampleRate int,
+	minSilenceDuration float64) (start, end int, found bool, err error) {
+	frames := len(samples) / channels
+	frame := func(f int) []int {
+		return samples[f*channels : (f+1)*channels]
+	}
+
+	// Find start (skip leading silence)
+	startFrame := -1
+	for f := 0; f < frames; f++ {
+		if f%hangCheckFrames == 0 && ctx.Err() != nil {
+			return 0, 0, false, ctx.Err()
+		}
+		if !isSilentFrame(frame(f), thresholdValue) {
+			startFrame = f
 			break
 		}
 	}
+	if startFrame < 0 {
+		// Entirely silent: there's no non-silent range to keep
+		return 0, 0, false, nil
+	}
+
+	// Find end: walk back from the last whole frame to the last frame above
+	// threshold, and only trim the tail if that trailing silence is long enough.
+	// The loop always stops, at startFrame at the latest.
+	lastLoudFrame := frames - 1
+	for checked := 1; isSilentFrame(frame(lastLoudFrame), thresholdValue); checked++ {
+		if checked%hangCheckFrames == 0 && ctx.Err() != nil {
+			return 0, 0, false, ctx.Err()
+		}
+		lastLoudFrame--
+	}
+	endFrame := frames
+	minSilenceFrames := int(float64(sampleRate) * minSilenceDuration)
+	if frames-(lastLoudFrame+1) >= minSilenceFrames {
+		endFrame = lastLoudFrame + 1
+	}
+
+	return startFrame * channels, endFrame * channels, true, nil
+}
+
+// applyGain multiplies samples in place by gain, clamping results to the
+// range of bitDepth, and returns the number of samples that were clamped
+func applyGain(samples []int, gain float64, bitDepth int) (clipped int) {
+	maxValue := float64(int(1)<<(bitDepth-1) - 1)
+	minValue := -maxValue - 1
+	for i, sample := range samples {
+		value := math.Round(float64(sample) * gain)
+		switch {
+		case value > maxValue:
+			value = maxValue
+			clipped++
+		case value < minValue:
+			value = minValue
+			clipped++
+		}
+		samples[i] = int(value)
+	}
+	return clipped
+}
+
+// applyFade applies a linear fade-in and fade-out, in place, over the first and
+// last N milliseconds of the interleaved samples. Fades longer than the audio
+// are clamped to its length.
+func applyFade(samples []int, channels, sampleRate, fadeInMs, fadeOutMs int) {
+	frames := len(samples) / channels
+	fadeInFrames := min(sampleRate*fadeInMs/1000, frames)
+	fadeOutFrames := min(sampleRate*fadeOutMs/1000, frames)
 
-	// Find end (skip trailing silence)
-	endIdx := len(samples)
-	silenceCount := 0
-	for i := len(samples) - channels; i >= startIdx; i -= channels {
-		// Check if any channel in this frame is above threshold
-		isSilent := true
+	for frame := 0; frame < fadeInFrames; frame++ {
+		gain := float64(frame) / float64(fadeInFrames)
 		for ch := 0; ch < channels; ch++ {
-			if i+ch >= 0 && i+ch < len(samples) {
-				absValue := samples[i+ch]
-				if absValue < 0 {
-					absValue = -absValue
-				}
-				if absValue > thresholdValue {
-					isSilent = false
-					break
-				}
+			samples[frame*channels+ch] = int(float64(samples[frame*channels+ch]) * gain)
+		}
+	}
+	for i := 0; i < fadeOutFrames; i++ {
+		frame := frames - 1 - i
+		gain := float64(i) / float64(fadeOutFrames)
+		for ch := 0; ch < channels; ch++ {
+			samples[frame*channels+ch] = int(float64(samples[frame*channels+ch]) * gain)
+		}
+	}
+}
+
+// joinRanges concatenates ranges of interleaved samples, crossfading each join
+// linearly over crossfadeFrames frames: the end of the previous range fades
+// out while the start of the next fades in over it. A crossfade is shortened
+// to fit both ranges, without reaching into the previous join's crossfade.
+func joinRanges(samples []int, channels int, ranges []sampleRange, crossfadeFrames int) []int {
+	total := 0
+	for _, r := range ranges {
+		total += r.end - r.start
+	}
+	joined := make([]int, 0, total)
+
+	previousFrames := 0 // frames of the previous range after its own crossfade
+	for i, r := range ranges {
+		part := samples[r.start:r.end]
+		partFrames := len(part) / channels
+		fade := 0
+		if i > 0 {
+			fade = min(crossfadeFrames, previousFrames, partFrames)
+		}
+		tail := joined[len(joined)-fade*channels:]
+		for frame := 0; frame < fade; frame++ {
+			gain := float64(frame+1) / float64(fade+1)
+			for ch := 0; ch < channels; ch++ {
+				j := frame*channels + ch
+				tail[j] = int(math.Round(float64(tail[j])*(1-gain) + float64(part[j])*gain))
 			}
 		}
-		if !isSilent {
-			endIdx = i + channels
-			break
+		joined = append(joined, part[fade*channels:]...)
+		previousFrames = partFrames - fade
+	}
+	return joined
+}
+
+// sampleRange is a half-open [start, end) range of interleaved sample indices
+type sampleRange struct {
+	start int
+	end   int
+}
+
+// findNonSilentSegments returns the non-silent ranges of the audio. Silent gaps
+// shorter than minSilenceDuration are kept inside the surrounding segment;
+// leading and trailing silence are excluded.
+func findNonSilentSegments(samples []int, channels int, threshold float64, sampleRate int, minSilenceDuration float64) []sampleRange {
+	thresholdValue := silenceThresholdValue(threshold, normalizedBitDepth)
+	minSilenceFrames := max(int(float64(sampleRate)*minSilenceDuration), 1)
+
+	var segments []sampleRange
+	segmentStart := -1 // -1 when not inside a segment
+	lastLoudEnd := 0   // index just past the most recent non-silent frame
+	for i := 0; i+channels <= len(samples); i += channels {
+		if isSilentFrame(samples[i:i+channels], thresholdValue) {
+			// Close the segment once the gap is long enough
+			if segmentStart >= 0 && (i+channels-lastLoudEnd)/channels >= minSilenceFrames {
+				segments = append(segments, sampleRange{start: segmentStart, end: lastLoudEnd})
+				segmentStart = -1
+			}
+			continue
 		}
-		silenceCount++
-		if silenceCount*channels >= minSilenceSamples {
-			// We've found enough consecutive silence
-			endIdx = i + channels
-			break
+		if segmentStart < 0 {
+			segmentStart = i
 		}
+		lastLoudEnd = i + channels
+	}
+	if segmentStart >= 0 {
+		segments = append(segments, sampleRange{start: segmentStart, end: lastLoudEnd})
 	}
 
-	// Ensure we have valid indices
-	if endIdx <= startIdx {
-		return 0, len(samples)
+	return segments
+}
+
+// dropShortSegments removes segments with fewer than minSamples interleaved samples
+func dropShortSegments(segments []sampleRange, minSamples int) []sampleRange {
+	kept := segments[:0]
+	for _, segment := range segments {
+		if segment.end-segment.start >= minSamples {
+			kept = append(kept, segment)
+		}
 	}
+	return kept
+}
 
-	return startIdx, endIdx
+// Noise floor measurement for ThresholdModeNoiseFloor
+const (
+	defaultNoiseFloorMarginDB = 6.0
+	noiseFloorWindowMs        = 10 // length of the windows whose peaks are ranked
+	noiseFloorPercentile      = 10 // the noise floor is this percentile of the window peaks
+	noiseFloorPeakHeadroomDB  = 6.0
+)
+
+// silenceThreshold is a threshold chosen by resolveSilenceThreshold
+type silenceThreshold struct {
+	threshold  float64 // fraction of full scale
+	noiseFloor float64 // measured noise floor as a fraction of full scale, only in noise_floor mode
+}
+
+// resolveSilenceThreshold returns the full-scale threshold for the given mode.
+// In relative_peak mode the threshold is scaled by the peak amplitude of the
+// samples, which are at the given bit depth, so quiet recordings that never
+// approach full scale still have detectable silence. In noise_floor mode the
+// threshold ignores threshold and is set marginDB above the measured noise
+// floor (see noiseFloor), but kept noiseFloorPeakHeadroomDB below the peak so
+// a recording that is mostly sound still has something above it.
+func resolveSilenceThreshold(samples []int, channels, sampleRate, bitDepth int, threshold float64, mode string, marginDB float64) (silenceThreshold, error) {
+	switch mode {
+	case "", ThresholdModeAbsolute:
+		return silenceThreshold{threshold: threshold}, nil
+	case ThresholdModeRelativePeak:
+		return silenceThreshold{threshold: threshold * peakAmplitude(samples) / fullScale(bitDepth)}, nil
+	case ThresholdModeNoiseFloor:
+		if marginDB == 0 {
+			marginDB = defaultNoiseFloorMarginDB
+		}
+		if !(marginDB > 0) || math.IsInf(marginDB, 0) {
+			return silenceThreshold{}, invalidInputError("noise floor margin must be a positive number of dB, got %v", marginDB)
+		}
+		floor := noiseFloor(samples, channels, sampleRate) / fullScale(bitDepth)
+		peak := peakAmplitude(samples) / fullScale(bitDepth)
+		return silenceThreshold{
+			threshold:  math.Min(floor*math.Pow(10, marginDB/20), peak*math.Pow(10, -noiseFloorPeakHeadroomDB/20)),
+			noiseFloor: floor,
+		}, nil
+	default:
+		return silenceThreshold{}, invalidInputError("invalid threshold mode %q: must be %q, %q or %q",
+			mode, ThresholdModeAbsolute, ThresholdModeRelativePeak, ThresholdModeNoiseFloor)
+	}
+}
+
+// noiseFloor estimates the level of the background noise of interleaved
+// samples: the peak amplitude of every noiseFloorWindowMs window is measured
+// across all channels, and the noiseFloorPercentile-th percentile of those
+// peaks is returned, at the samples' bit depth. Using window peaks keeps the
+// estimate on the same scale as isSilentFrame's per-sample comparison, and a
+// low percentile picks out the quiet passages without being thrown off by a
+// few windows of digital silence. It assumes at least that share of the audio
+// is background; in a file that is sound throughout, it measures the quietest
+// sound instead.
+func noiseFloor(samples []int, channels, sampleRate int) float64 {
+	windowSamples := max(sampleRate*noiseFloorWindowMs/1000, 1) * channels
+	var peaks []int
+	for start := 0; start < len(samples); start += windowSamples {
+		peaks = append(peaks, int(peakAmplitude(samples[start:min(start+windowSamples, len(samples))])))
+	}
+	if len(peaks) == 0 {
+		return 0
+	}
+	slices.Sort(peaks)
+	return float64(peaks[len(peaks)*noiseFloorPercentile/100])
+}
+
+// peakAmplitude returns the largest absolute sample value
+func peakAmplitude(samples []int) float64 {
+	peak := 0
+	for _, sample := range samples {
+		peak = max(peak, absInt(sample))
+	}
+	return float64(peak)
+}
+
+// silenceThresholdValue converts a 0.0-1.0 threshold to a sample value for
+// audio of the given bit depth (16-bit: range -32768 to 32767)
+func silenceThresholdValue(threshold float64, bitDepth int) int {
+	return int(threshold * fullScale(bitDepth))
+}
+
+// fullScale returns the largest sample value of the given bit depth
+func fullScale(bitDepth int) float64 {
+	return float64(int(1)<<(bitDepth-1) - 1)
+}
+
+// isSilentFrame reports whether every channel in the frame is at or below the threshold
+func isSilentFrame(frame []int, thresholdValue int) bool {
+	for _, sample := range frame {
+		if absInt(sample) > thresholdValue {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,243 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test signals for findNonSilentRange: samples above silentThreshold are
+// loud, and at testSampleRate a 0.5s minimum silence is five frames
+const (
+	testSampleRate  = 10
+	silentThreshold = 100
+	loud            = 1000
+)
+
+// repeat returns n copies of v
+func repeat(n, v int) []int {
+	samples := make([]int, n)
+	for i := range samples {
+		samples[i] = v
+	}
+	return samples
+}
+
+// concat joins sample slices
+func concat(parts ...[]int) []int {
+	var samples []int
+	for _, part := range parts {
+		samples = append(samples, part...)
+	}
+	return samples
+}
+
+func TestFindNonSilentRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		samples    []int
+		channels   int
+		minSilence float64 // seconds
+		start, end int     // sample indices
+		found      bool
+	}{
+		{"empty", nil, 1, 0.5, 0, 0, false},
+		{"all silent", repeat(10, 0), 1, 0.5, 0, 0, false},
+		{"all at the threshold", repeat(10, -silentThreshold), 1, 0.5, 0, 0, false},
+		{"all silent stereo", repeat(20, 50), 2, 0.5, 0, 0, false},
+		{"no silence", repeat(5, loud), 1, 0.5, 0, 5, true},
+		{"negative samples are loud", repeat(5, -loud), 1, 0.5, 0, 5, true},
+		{"leading silence", concat(repeat(3, 0), repeat(4, loud)), 1, 0.5, 3, 7, true},
+		{"short leading silence", concat(repeat(1, 0), repeat(4, loud)), 1, 0.5, 1, 5, true},
+		{"trailing silence", concat(repeat(4, loud), repeat(7, 0)), 1, 0.5, 0, 4, true},
+		{"both ends", concat(repeat(2, 0), repeat(3, loud), repeat(6, 0)), 1, 0.5, 2, 5, true},
+		{"silence in the middle", concat(repeat(1, loud), repeat(6, 0), repeat(1, loud)), 1, 0.5, 0, 8, true},
+
+		// The minimum silence only applies to the end
+		{"trailing silence at the minimum", concat(repeat(4, loud), repeat(5, 0)), 1, 0.5, 0, 4, true},
+		{"trailing silence below the minimum", concat(repeat(4, loud), repeat(4, 0)), 1, 0.5, 0, 8, true},
+		{"no minimum", concat(repeat(4, loud), repeat(1, 0)), 1, 0, 0, 4, true},
+		{"minimum longer than the file", concat(repeat(4, loud), repeat(6, 0)), 1, 100, 0, 10, true},
+		{"single loud frame", concat(repeat(4, 0), repeat(1, loud), repeat(5, 0)), 1, 0.5, 4, 5, true},
+
+		// Ranges are whole frames, and a frame is loud if any channel is
+		{"stereo loud in one channel", []int{0, 0, 0, loud, loud, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 2, 0.5, 2, 6, true},
+		{"three channels", concat(repeat(6, 0), []int{0, 0, loud}, repeat(15, 0)), 3, 0.5, 6, 9, true},
+		{"five channels", concat(repeat(5, loud), repeat(25, 0)), 5, 0.5, 0, 5, true},
+
+		// A truncated final frame is never sound and never in the range
+		{"partial final frame", concat(repeat(6, loud), []int{loud}), 2, 0, 0, 6, true},
+		{"partial final frame after silence", concat(repeat(2, loud), repeat(16, 0), []int{loud, loud}), 3, 0.5, 0, 3, true},
+		{"only a partial frame", []int{loud, loud}, 3, 0.5, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, found, err := findNonSilentRange(context.Background(), tt.samples, tt.channels, silentThreshold, testSampleRate, tt.minSilence)
+			require.NoError(t, err)
+			assert.Equal(t, tt.found, found, "found")
+			assert.Equal(t, tt.start, start, "start")
+			assert.Equal(t, tt.end, end, "end")
+		})
+	}
+}
+
+func TestFindNonSilentRangeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err := findNonSilentRange(ctx, repeat(10, 0), 1, silentThreshold, testSampleRate, 0.5)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFindNonSilentRangeFrameBoundaries(t *testing.T) {
+	for channels := 1; channels <= 8; channels++ {
+		for truncated := 0; truncated < channels; truncated++ {
+			// A loud frame between silent ones, then a loud truncated frame
+			// that must not move the end
+			samples := concat(repeat(3*channels, 0), repeat(channels, loud), repeat(2*channels, 0), repeat(truncated, loud))
+			start, end, found, err := findNonSilentRange(context.Background(), samples, channels, silentThreshold, testSampleRate, 0)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, 3*channels, start, "start, %d channels, %d truncated", channels, truncated)
+			assert.Equal(t, 4*channels, end, "end, %d channels, %d truncated", channels, truncated)
+
+			// Only the last whole frame is loud
+			samples = concat(repeat(4*channels, 0), repeat(channels, loud), repeat(truncated, 0))
+			start, end, found, err = findNonSilentRange(context.Background(), samples, channels, silentThreshold, testSampleRate, 0)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, 4*channels, start, "start of last frame, %d channels, %d truncated", channels, truncated)
+			assert.Equal(t, 5*channels, end, "end of last frame, %d channels, %d truncated", channels, truncated)
+		}
+	}
+}
+
+func TestFindNonSilentRangeTrailingSilence(t *testing.T) {
+	// A soft ending, each sample a little quieter but all above the threshold
+	decay := []int{800, 400, 200, 150, 120, silentThreshold + 1}
+	interleave := func(left, right []int) []int {
+		samples := make([]int, 0, 2*len(left))
+		for i := range left {
+			samples = append(samples, left[i], right[i])
+		}
+		return samples
+	}
+	tests := []struct {
+		name       string
+		samples    []int
+		channels   int
+		minSilence float64
+		end        int
+	}{
+		// The whole tail goes, not just the part beyond the minimum
+		{"long tail", concat(repeat(2, loud), repeat(20, 0)), 1, 0.5, 2},
+		{"long stereo tail", concat(repeat(4, loud), repeat(40, 0)), 2, 0.5, 4},
+		// The minimum counts frames, not interleaved samples
+		{"stereo tail one frame short", concat(repeat(4, loud), repeat(8, 0)), 2, 0.5, 12},
+		{"stereo tail at the minimum", concat(repeat(4, loud), repeat(10, 0)), 2, 0.5, 4},
+		{"six-channel tail one frame short", concat(repeat(6, loud), repeat(24, 0)), 6, 0.5, 30},
+		{"six-channel tail at the minimum", concat(repeat(6, loud), repeat(30, 0)), 6, 0.5, 6},
+		// A loud frame inside the tail ends the silence there
+		{"loud frame in the tail", concat(repeat(2, loud), repeat(6, 0), repeat(1, loud), repeat(5, 0)), 1, 0.5, 9},
+		// A soft ending decaying to just above the threshold is kept in full,
+		// and only the silence after it goes
+		{"decaying tail", concat(repeat(2, loud), decay, repeat(20, 0)), 1, 0.5, 8},
+		{"decaying tail into low noise", concat(repeat(2, loud), decay, []int{silentThreshold, -60, 30, -10}, repeat(16, 0)), 1, 0.5, 8},
+		{"decaying stereo tail", concat(repeat(4, loud), interleave(decay, decay), repeat(40, 0)), 2, 0.5, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, found, err := findNonSilentRange(context.Background(), tt.samples, tt.channels, silentThreshold, testSampleRate, tt.minSilence)
+			require.NoError(t, err)
+			require.True(t, found)
+			assert.Equal(t, 0, start, "start")
+			assert.Equal(t, tt.end, end, "end")
+		})
+	}
+}
+
+// appendTruncatedFrame adds one 16-bit sample to the end of a WAV file from
+// writeTestWAV, whose data chunk comes last, leaving a partial final frame
+func appendTruncatedFrame(t *testing.T, path string, sample int16) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	dataChunk := bytes.Index(data, []byte("data"))
+	require.GreaterOrEqual(t, dataChunk, 0)
+
+	data = binary.LittleEndian.AppendUint16(data, uint16(sample))
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	binary.LittleEndian.PutUint32(data[dataChunk+4:], uint32(len(data)-dataChunk-8))
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func TestTrimSilenceTruncatedFinalFrame(t *testing.T) {
+	// Stereo files whose data chunk ends one sample into a frame
+	tests := []struct {
+		name     string
+		samples  []int
+		last     int16
+		noOp     bool
+		trailing int
+	}{
+		{"loud throughout", tone(1000, 2, 10000), 10000, true, 0},
+		{"trailing silence", concat(tone(1000, 2, 10000), repeat(2*5000, 0)), 0, false, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			env, _ := newTestEnv(t, dir, ActivitiesConfig{})
+			source := writeTestWAV(t, filepath.Join(dir, "source.wav"), tt.samples, 44100, 2, 16)
+			appendTruncatedFrame(t, source, tt.last)
+
+			result, err := env.ExecuteActivity("TrimSilence", TrimSilenceInput{SourcePath: source, SilenceThreshold: 0.01, DryRun: true})
+			require.NoError(t, err)
+			var output TrimSilenceOutput
+			require.NoError(t, result.Get(&output))
+
+			assert.Equal(t, tt.noOp, output.NoOp, "no-op")
+			assert.Equal(t, !tt.noOp, output.WasTrimmed, "trimmed")
+			assert.Equal(t, 0, output.LeadingSamplesRemoved, "leading")
+			assert.Equal(t, tt.trailing, output.TrailingSamplesRemoved, "trailing")
+		})
+	}
+}
+
+func TestApplyGainClampsToSourceBitDepth(t *testing.T) {
+	// +6 dB roughly doubles each sample, so only samples above half of full
+	// scale at the source depth clip
+	for _, bitDepth := range []int{16, 24} {
+		t.Run(fmt.Sprintf("%d-bit", bitDepth), func(t *testing.T) {
+			maxValue := 1<<(bitDepth-1) - 1
+			// 2% either side of half scale, clear of 6 dB being a little under 2x
+			half, margin := maxValue/2, maxValue/50
+			samples := []int{0, 100, -100, half - margin, -(half - margin), half + margin, -(half + margin), maxValue, -maxValue - 1}
+
+			dir := t.TempDir()
+			env, _ := newTestEnv(t, dir, ActivitiesConfig{})
+			source := writeTestWAV(t, filepath.Join(dir, "source.wav"), samples, 44100, 1, bitDepth)
+			result, err := env.ExecuteActivity("ApplyGain", ApplyGainInput{SourcePath: source, GainDB: 6})
+			require.NoError(t, err)
+			var output ApplyGainOutput
+			require.NoError(t, result.Get(&output))
+
+			gain := math.Pow(10, 6.0/20)
+			written, _, writtenDepth := readTestWAV(t, dir, output.OutputPath)
+			assert.Equal(t, bitDepth, writtenDepth)
+			want := make([]int, len(samples))
+			for i, sample := range samples {
+				want[i] = max(-maxValue-1, min(maxValue, int(math.Round(float64(sample)*gain))))
+			}
+			assert.Equal(t, want, written)
+			assert.True(t, output.Clipped)
+			assert.Equal(t, 4, output.ClippedSamples)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// Policies for database calls that fail while an activity runs
+const (
+	DBFailureContinue = "continue" // keep the activity's results and set DBStatus.DBFailed (default)
+	DBFailureFail     = "fail"     // fail the attempt with a retryable error
+)
+
+// dbError applies the database failure policy to err, returned by the
+// database call described by op (e.g. "insert asset"). The failure is always
+// logged. Under DBFailureFail it returns a retryable error for the activity
+// to return, so Temporal retries the attempt until the database is back or
+// the attempts run out; otherwise it flags status and returns nil, and the
+// activity carries on without the database.
+func (ac *ActivitiesClient) dbError(ctx context.Context, status *DBStatus, op string, err error) error {
+	activity.GetLogger(ctx).Error("Database call failed", "op", op, "policy", ac.dbFailurePolicy, "error", err)
+	if ac.dbFailurePolicy == DBFailureFail {
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+	status.DBFailed = true
+	return nil
+}
@@ -0,0 +1,27 @@
+package activities
+
+import "context"
+
+// acquireDecode waits for one of the configured decode slots and returns the
+// function that frees it again.
+//
+// Temporal bounds how many activities a worker runs, but not how many of them
+// decode at once, and a decoded file takes several times its size in memory:
+// a handful of activities loading multi-GB files together can exhaust the
+// worker's memory. Activities that decode a whole file, or spend most of their
+// time decoding, hold a slot from just before they decode until they return,
+// as their samples stay in memory until then. They must not acquire a second
+// slot while holding one, or they could deadlock each other. Waiting ends
+// early with the context's error if the activity is cancelled or times out. A
+// limit of zero disables the slots and returns at once.
+func (ac *ActivitiesClient) acquireDecode(ctx context.Context) (release func(), err error) {
+	if ac.decodeSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case ac.decodeSlots <- struct{}{}:
+		return func() { <-ac.decodeSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,405 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-audio/aiff"
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+
+	"github.com/pphelan007/davidAI/internal/flac"
+)
+
+// Audio containers activities can decode. Output files are always WAV.
+const (
+	containerWAV  = "WAV"
+	containerAIFF = "AIFF"
+	containerOgg  = "Ogg"
+	containerFLAC = "FLAC"
+)
+
+// ErrInvalidAudio is wrapped by decoding errors for files that are malformed:
+// their container is recognized but its headers can't be parsed
+var ErrInvalidAudio = errors.New("malformed audio")
+
+// ErrInvalidWAV is returned for RIFF/WAVE files whose headers can't be parsed.
+// It wraps ErrInvalidAudio.
+var ErrInvalidWAV = fmt.Errorf("%w: not a valid WAV file", ErrInvalidAudio)
+
+// ErrUnsupportedFormat is wrapped by decoding errors for well-formed files in
+// a container, codec, encoding or bit depth activities can't decode
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// errUnknownContainer is returned for files in none of the supported containers
+var errUnknownContainer = fmt.Errorf("%w: file is not a WAV, AIFF, FLAC or Ogg file", ErrUnsupportedFormat)
+
+// pcmDecoder decodes a WAV, AIFF, FLAC or Ogg Vorbis/Opus stream behind one
+// interface, so activities handle them all the same way. The container is
+// detected from the file header rather than the extension, since uploaded and
+// piped files keep whatever name they were given.
+//
+// Samples come out of the WAV, AIFF and FLAC decoders as signed integers at the
+// source bit depth (except 8-bit, see normalizeSamples), with AIFF's big-endian
+// bytes already swapped, so after normalizeSamples the math downstream can't
+// tell them apart. Vorbis and Opus are lossy and decoded straight to 16-bit.
+// Their decoded samples only approximate the original audio, so the content
+// hash of anything written from them (e.g. a trimmed WAV) never matches the
+// hash of the compressed source file.
+type pcmDecoder struct {
+	container string
+	codec     string         // codecVorbis or codecOpus for Ogg files
+	wav       *wav.Decoder   // set for WAV files, and for Opus files decoded to WAV
+	aiff      *aiff.Decoder  // set for AIFF files
+	flac      *flac.Decoder  // set for FLAC files
+	vorbis    *vorbisDecoder // set for Ogg Vorbis files
+
+	// remaining counts the WAV and AIFF samples not yet returned. Both
+	// decoders read the padding that aligns the sample chunk to an even size
+	// as an extra sample, so reads are capped at the size the headers give:
+	// the WAV data chunk's size or the AIFF COMM chunk's frame count. -1 until
+	// known.
+	remaining int
+}
+
+// newPCMDecoder detects the container of the stream at its current position
+// and checks the file is valid and its encoding supported. Opus streams are
+// decoded in full here, by opusdec under ctx; other formats are decoded as
+// their samples are read. Malformed files fail with an error wrapping
+// ErrInvalidAudio and unsupported ones with ErrUnsupportedFormat; the decoder
+// is nil whenever the error isn't.
+func newPCMDecoder(ctx context.Context, r io.ReadSeeker) (*pcmDecoder, error) {
+	container, err := detectContainer(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &pcmDecoder{container: container, remaining: -1}
+	switch container {
+	case containerOgg:
+		if d.codec, err = detectOggCodec(r); err != nil {
+			return nil, err
+		}
+		if d.codec == codecVorbis {
+			if d.vorbis, err = newVorbisDecoder(r); err != nil {
+				return nil, err
+			}
+			return d, nil
+		}
+		decoded, opusErr := decodeOpus(ctx, r)
+		if opusErr != nil {
+			return nil, opusErr
+		}
+		d.wav = wav.NewDecoder(bytes.NewReader(decoded))
+		if !d.wav.IsValidFile() {
+			return nil, fmt.Errorf("%s produced an invalid WAV file", opusdecCommand)
+		}
+		return d, nil
+	case containerFLAC:
+		if d.flac, err = flac.NewDecoder(r); err != nil {
+			return nil, fmt.Errorf("%w: not a valid FLAC file: %w", ErrInvalidAudio, err)
+		}
+		switch depth := d.flac.Info().BitDepth; depth {
+		case 8, 16, 24, 32:
+			return d, nil
+		default:
+			return nil, fmt.Errorf("%w: FLAC bit depth %d", ErrUnsupportedFormat, depth)
+		}
+	case containerAIFF:
+		d.aiff = aiff.NewDecoder(r)
+		// Compressed AIFC encodings fail here too
+		if !d.aiff.IsValidFile() {
+			return nil, fmt.Errorf("%w: not a valid AIFF file or uses an unsupported compression", ErrInvalidAudio)
+		}
+		if err = checkAIFFEncoding(d.aiff); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	// go-audio rounds the data chunk's size up to include its pad byte, so
+	// the size is read from the header first
+	dataSize, err := wavDataSize(r)
+	if err != nil {
+		return nil, err
+	}
+	d.wav = wav.NewDecoder(r)
+	if !d.wav.IsValidFile() {
+		return nil, ErrInvalidWAV
+	}
+	if err = checkWAVEncoding(d.wav); err != nil {
+		return nil, err
+	}
+	if dataSize > 0 {
+		d.remaining = int(dataSize / int64((d.wav.BitDepth-1)/8+1))
+	}
+	return d, nil
+}
+
+// wavDataSize returns the size of the data chunk in the WAV stream at the
+// current position, or 0 if there is none, and seeks back to where it
+// started. A malformed file is left for the WAV decoder to reject.
+func wavDataSize(r io.ReadSeeker) (int64, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file header: %w", err)
+	}
+	// Skip the RIFF header: "RIFF", size, "WAVE"
+	offset := start + 12
+	var size int64
+	var header struct {
+		ID   [4]byte
+		Size uint32
+	}
+	for {
+		if _, err = r.Seek(offset, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("failed to read file header: %w", err)
+		}
+		if binary.Read(r, binary.LittleEndian, &header) != nil {
+			break
+		}
+		if header.ID == dataChunkID {
+			size = int64(header.Size)
+			break
+		}
+		// Chunks are padded to an even size
+		offset += 8 + int64(header.Size) + int64(header.Size&1)
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return size, nil
+}
+
+// detectContainer reads the header of the stream at its current position,
+// returns its container, and seeks back to where it started
+func detectContainer(r io.ReadSeeker) (string, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file header: %w", err)
+	}
+	header := make([]byte, ContainerHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", errUnknownContainer // too short to be any of them
+		}
+		return "", fmt.Errorf("failed to read file header: %w", err)
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return containerOf(header)
+}
+
+// ContainerHeaderSize is how many leading bytes of a file identify its container
+const ContainerHeaderSize = 12
+
+// containerOf identifies the container of a file from its first bytes
+func containerOf(header []byte) (string, error) {
+	if len(header) < ContainerHeaderSize {
+		return "", errUnknownContainer // too short to be any of them
+	}
+	form, kind := string(header[0:4]), string(header[8:12])
+	switch {
+	case form == "RIFF" && kind == "WAVE":
+		return containerWAV, nil
+	case form == "FORM" && (kind == "AIFF" || kind == "AIFC"):
+		return containerAIFF, nil
+	case form == "OggS":
+		return containerOgg, nil
+	case form == "fLaC":
+		return containerFLAC, nil
+	}
+	return "", errUnknownContainer
+}
+
+// containerExtensions are the file extensions of the containers
+var containerExtensions = map[string]string{
+	containerWAV:  ".wav",
+	containerAIFF: ".aiff",
+	containerOgg:  ".ogg",
+	containerFLAC: ".flac",
+}
+
+// ContainerExtension returns the file extension, with its dot, of audio whose
+// first ContainerHeaderSize bytes are header, e.g. to name a file piped audio
+// is saved to. Audio in none of the supported containers is an
+// ErrUnsupportedFormat error.
+func ContainerExtension(header []byte) (string, error) {
+	container, err := containerOf(header)
+	if err != nil {
+		return "", err
+	}
+	return containerExtensions[container], nil
+}
+
+// checkAIFFEncoding rejects bit depths whose samples can't be scaled to the
+// 16-bit range, like checkWAVEncoding. AIFF and uncompressed AIFC are always
+// integer PCM.
+func checkAIFFEncoding(decoder *aiff.Decoder) error {
+	switch decoder.BitDepth {
+	case 8, 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("%w: AIFF bit depth %d", ErrUnsupportedFormat, decoder.BitDepth)
+}
+
+// Format returns the sample rate and channel count
+func (d *pcmDecoder) Format() *audio.Format {
+	switch {
+	case d.vorbis != nil:
+		return d.vorbis.format()
+	case d.flac != nil:
+		info := d.flac.Info()
+		return &audio.Format{NumChannels: info.Channels, SampleRate: info.SampleRate}
+	case d.aiff != nil:
+		return d.aiff.Format()
+	}
+	return d.wav.Format()
+}
+
+// BitDepth returns the bit depth of the source samples, or 0 for lossy codecs,
+// which have none
+func (d *pcmDecoder) BitDepth() int {
+	switch {
+	case d.codec != "":
+		return 0
+	case d.flac != nil:
+		return d.flac.Info().BitDepth
+	case d.aiff != nil:
+		return int(d.aiff.BitDepth)
+	}
+	return int(d.wav.BitDepth)
+}
+
+// Encoding returns the name of the sample encoding: "pcm" or "float" for WAV
+// and AIFF (always "pcm"), "flac" for FLAC, or the codec for Ogg files
+func (d *pcmDecoder) Encoding() string {
+	switch {
+	case d.codec != "":
+		return d.codec
+	case d.flac != nil:
+		return "flac"
+	case d.aiff != nil:
+		return encodingName(wavFormatPCM)
+	}
+	return encodingName(int(d.wav.WavAudioFormat))
+}
+
+// isFloat reports whether the samples are IEEE floats, which the WAV decoder
+// returns as their raw bit patterns
+func (d *pcmDecoder) isFloat() bool {
+	return d.codec == "" && d.wav != nil && d.wav.WavAudioFormat == wavFormatIEEEFloat
+}
+
+// Frames seeks to the sample data without reading it and returns the number
+// of frames it holds
+func (d *pcmDecoder) Frames() (int, error) {
+	if d.vorbis != nil {
+		return int(d.vorbis.reader.Length()), nil
+	}
+	if d.flac != nil {
+		info := d.flac.Info()
+		if info.TotalSamples > 0 {
+			return int(info.TotalSamples), nil
+		}
+		// The encoder may leave the length out, so count the samples
+		samples, err := d.flac.ReadAll()
+		if err != nil {
+			return 0, err
+		}
+		return len(samples) / info.Channels, nil
+	}
+	if d.aiff != nil {
+		if err := d.aiff.FwdToPCM(); err != nil {
+			return 0, err
+		}
+		if d.aiff.PCMChunk == nil {
+			return 0, errors.New("no SSND chunk")
+		}
+		return int(d.aiff.NumSampleFrames), nil
+	}
+
+	if err := d.wav.FwdToPCM(); err != nil {
+		return 0, err
+	}
+	if d.wav.PCMSize <= 0 {
+		return 0, nil
+	}
+	bytesPerFrame := int(d.wav.NumChans) * int(d.wav.BitDepth) / 8
+	return d.wav.PCMSize / bytesPerFrame, nil
+}
+
+// FullPCMBuffer reads every sample, unscaled
+func (d *pcmDecoder) FullPCMBuffer() (*audio.IntBuffer, error) {
+	if d.vorbis != nil {
+		samples, err := d.vorbis.readAll()
+		if err != nil {
+			return nil, err
+		}
+		return &audio.IntBuffer{Format: d.vorbis.format(), Data: samples, SourceBitDepth: 16}, nil
+	}
+	if d.flac != nil {
+		samples, err := d.flac.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		return &audio.IntBuffer{Format: d.Format(), Data: samples, SourceBitDepth: d.BitDepth()}, nil
+	}
+	var buf *audio.IntBuffer
+	var err error
+	if d.aiff != nil {
+		buf, err = d.aiff.FullPCMBuffer()
+	} else {
+		buf, err = d.wav.FullPCMBuffer()
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf.Data = buf.Data[:d.capSamples(len(buf.Data))]
+	return buf, nil
+}
+
+// PCMBuffer reads the next samples into buf, unscaled, and returns how many
+// were read. It returns 0 once the samples are exhausted.
+func (d *pcmDecoder) PCMBuffer(buf *audio.IntBuffer) (int, error) {
+	if d.vorbis != nil {
+		return d.vorbis.read(buf.Data)
+	}
+	if d.flac != nil {
+		n, err := d.flac.Read(buf.Data)
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return n, err
+	}
+	var n int
+	var err error
+	if d.aiff != nil {
+		n, err = d.aiff.PCMBuffer(buf)
+	} else {
+		n, err = d.wav.PCMBuffer(buf)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return d.capSamples(n), nil
+}
+
+// capSamples limits a read of n WAV or AIFF samples to the samples remaining.
+// A WAV file without a data chunk size isn't capped.
+func (d *pcmDecoder) capSamples(n int) int {
+	if d.remaining < 0 {
+		if d.aiff == nil {
+			return n
+		}
+		d.remaining = int(d.aiff.NumSampleFrames) * int(d.aiff.NumChans)
+	}
+	n = min(n, d.remaining)
+	d.remaining -= n
+	return n
+}
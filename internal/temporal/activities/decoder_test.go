@@ -0,0 +1,85 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeOddSizedDataChunk reads WAV files whose data chunk has an odd
+// size and is followed by its pad byte and another chunk. The pad byte must
+// not come out as an extra sample, whether the file is read at once or in
+// buffers.
+func TestDecodeOddSizedDataChunk(t *testing.T) {
+	dir := t.TempDir()
+	ac := NewActivitiesClient(nil, nil, ActivitiesConfig{DataDir: dir})
+	chunks := []wavChunk{{id: [4]byte{'i', 'X', 'M', 'L'}, data: []byte("<BWFXML/>")}}
+
+	for _, bitDepth := range []int{8, 24} {
+		t.Run(fmt.Sprintf("%d-bit", bitDepth), func(t *testing.T) {
+			samples := tone(1001, 1, 1<<(bitDepth-2))
+			if bitDepth == 8 {
+				// 8-bit WAV is unsigned
+				for i := range samples {
+					samples[i] += 128
+				}
+			}
+			path, _, err := ac.writeWAVWithChunks(context.Background(), filepath.Join(dir, "odd.wav"),
+				&audio.Format{NumChannels: 1, SampleRate: 44100}, samples, bitDepth, chunks)
+			require.NoError(t, err)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			decoder, err := newPCMDecoder(context.Background(), bytes.NewReader(data))
+			require.NoError(t, err)
+			full, err := decoder.FullPCMBuffer()
+			require.NoError(t, err)
+			assert.Equal(t, samples, full.Data)
+
+			decoder, err = newPCMDecoder(context.Background(), bytes.NewReader(data))
+			require.NoError(t, err)
+			var streamed []int
+			buf := &audio.IntBuffer{Data: make([]int, 256)}
+			for {
+				n, err := decoder.PCMBuffer(buf)
+				require.NoError(t, err)
+				if n == 0 {
+					break
+				}
+				streamed = append(streamed, buf.Data[:n]...)
+			}
+			assert.Equal(t, samples, streamed)
+		})
+	}
+}
+
+func TestContainerExtension(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string // empty if the container isn't recognized
+	}{
+		{"RIFF\x24\x00\x00\x00WAVE", ".wav"},
+		{"FORM\x00\x00\x00\x00AIFF", ".aiff"},
+		{"FORM\x00\x00\x00\x00AIFC", ".aiff"},
+		{"OggS\x00\x02\x00\x00\x00\x00\x00\x00", ".ogg"},
+		{"fLaC\x00\x00\x00\x22\x10\x00\x10\x00", ".flac"},
+		{"ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00", ""},
+		{"fLaC", ""}, // too short to tell
+	}
+	for _, tt := range tests {
+		ext, err := ContainerExtension([]byte(tt.header))
+		if tt.want == "" {
+			assert.ErrorIs(t, err, ErrUnsupportedFormat, "%q", tt.header)
+			continue
+		}
+		require.NoError(t, err, "%q", tt.header)
+		assert.Equal(t, tt.want, ext, "%q", tt.header)
+	}
+}
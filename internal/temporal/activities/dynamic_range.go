@@ -0,0 +1,165 @@
+package activities
+
+import (
+	"context"
+	"math"
+	"slices"
+)
+
+// Dynamic range defaults and limits
+const (
+	defaultDRWindowSeconds = 3.0   // block length DR meters use
+	drLoudestFraction      = 0.2   // share of the loudest windows the DR score's RMS is taken over
+	maxDynamicRangeWindows = 10000 // keeps the window list well within Temporal's payload limit
+)
+
+func init() {
+	RegisterFeature(Feature{
+		Name:     "dynamic_range",
+		Activity: "ComputeDynamicRange",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeDynamicRangeInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeDynamicRange },
+	})
+}
+
+// ComputeDynamicRange measures the dynamic range of an audio file, to check
+// that a master isn't over-compressed. The peak and RMS level and their
+// difference, the crest factor, are measured over sliding windows across all
+// channels, like ComputeSNRProfile's windows. The DR score follows the DR
+// meter: per channel, the second-highest window peak over the RMS of the
+// loudest 20% of windows, averaged over the channels. Its defaults of
+// non-overlapping 3 second windows match the meter. With overlapping windows
+// the highest peak usually falls in two of them, so the score effectively
+// uses the highest peak. Lower scores mean a more compressed master.
+func (ac *ActivitiesClient) ComputeDynamicRange(ctx context.Context, input ComputeDynamicRangeInput) (*ComputeDynamicRangeOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	windowSeconds := input.WindowSeconds
+	if windowSeconds == 0 {
+		windowSeconds = defaultDRWindowSeconds
+	}
+	hopSeconds := input.HopSeconds
+	if hopSeconds == 0 {
+		hopSeconds = windowSeconds
+	}
+	if windowSeconds < 0 || hopSeconds < 0 {
+		return nil, invalidInputError("window and hop must be positive, got %.3fs and %.3fs", windowSeconds, hopSeconds)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	channels := format.NumChannels
+	sampleRate := float64(format.SampleRate)
+
+	frames := len(samples) / channels
+	windowFrames := max(1, int(windowSeconds*sampleRate))
+	hopFrames := max(1, int(hopSeconds*sampleRate))
+	if frames > windowFrames && (frames-windowFrames)/hopFrames+1 > maxDynamicRangeWindows {
+		return nil, invalidInputError("a %.3fs hop gives more than %d windows, use a longer hop", hopSeconds, maxDynamicRangeWindows)
+	}
+
+	output := &ComputeDynamicRangeOutput{
+		WindowSeconds: windowSeconds,
+		HopSeconds:    hopSeconds,
+	}
+	// Window peaks and mean squares of each channel, for the DR score
+	peaks := make([][]float64, channels)
+	meanSquares := make([][]float64, channels)
+	crestSum, crestWindows := 0.0, 0
+	for start := 0; start == 0 || start+windowFrames <= frames; start += hopFrames {
+		end := min(start+windowFrames, frames)
+		peak, sumSquares := 0.0, 0.0
+		for ch := 0; ch < channels; ch++ {
+			channelPeak, channelSumSquares := 0.0, 0.0
+			for i := start*channels + ch; i < end*channels; i += channels {
+				x := float64(samples[i]) / 32768.0
+				channelPeak = math.Max(channelPeak, math.Abs(x))
+				channelSumSquares += x * x
+			}
+			peaks[ch] = append(peaks[ch], channelPeak)
+			meanSquares[ch] = append(meanSquares[ch], channelSumSquares/float64(end-start))
+			peak = math.Max(peak, channelPeak)
+			sumSquares += channelSumSquares
+		}
+
+		rms := math.Sqrt(sumSquares / float64((end-start)*channels))
+		window := DynamicRangeWindow{
+			StartTime: float64(start) / sampleRate,
+			EndTime:   float64(end) / sampleRate,
+			PeakDBFS:  peakDB(peak),
+			RMSDBFS:   peakDB(rms),
+		}
+		if rms > 0 {
+			window.CrestDB = window.PeakDBFS - window.RMSDBFS
+			crestSum += window.CrestDB
+			crestWindows++
+		}
+		output.Windows = append(output.Windows, window)
+	}
+	if crestWindows > 0 {
+		output.MeanCrestDB = crestSum / float64(crestWindows)
+	}
+	output.DRScore = drScore(peaks, meanSquares)
+	output.DRValue = int(math.Round(output.DRScore))
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "dynamic_range",
+		map[string]interface{}{
+			"dr_score":      output.DRScore,
+			"dr_value":      output.DRValue,
+			"mean_crest_db": output.MeanCrestDB,
+			"windows":       output.Windows,
+		},
+		map[string]interface{}{
+			"window_seconds": windowSeconds,
+			"hop_seconds":    hopSeconds,
+			"sample_rate":    format.SampleRate,
+			"channels":       channels,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// drScore computes the DR meter's score from the window peaks and mean squares
+// of each channel (linear, -1..1 scale). The meter's RMS is sqrt(2) times the
+// usual one, so a sine scores 0 dB rather than its 3 dB crest factor. Silent
+// channels are left out of the average, and a silent file scores 0.
+func drScore(peaks, meanSquares [][]float64) float64 {
+	sum, counted := 0.0, 0
+	for ch := range peaks {
+		sortedPeaks := slices.Sorted(slices.Values(peaks[ch]))
+		peak := sortedPeaks[len(sortedPeaks)-1]
+		if len(sortedPeaks) > 1 {
+			peak = sortedPeaks[len(sortedPeaks)-2]
+		}
+
+		sortedMeanSquares := slices.Sorted(slices.Values(meanSquares[ch]))
+		loudest := max(1, int(float64(len(sortedMeanSquares))*drLoudestFraction))
+		total := 0.0
+		for _, meanSquare := range sortedMeanSquares[len(sortedMeanSquares)-loudest:] {
+			total += meanSquare
+		}
+		rms := math.Sqrt(2 * total / float64(loudest))
+		if peak == 0 || rms == 0 {
+			continue
+		}
+		sum += 20 * math.Log10(peak/rms)
+		counted++
+	}
+	if counted == 0 {
+		return 0
+	}
+	return sum / float64(counted)
+}
@@ -2,149 +2,843 @@ package activities
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
-	"os"
-	"path/filepath"
+	"strconv"
 	"time"
 
-	"github.com/go-audio/wav"
+	"github.com/go-audio/audio"
 	"github.com/google/uuid"
 	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/metrics"
+	"github.com/pphelan007/davidAI/internal/storage"
 	"go.temporal.io/sdk/activity"
 )
 
+// Zero parameters in the feature inputs take each activity's defaults
+func init() {
+	RegisterFeature(Feature{
+		Name:     "snr",
+		Activity: "ComputeSNR",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeSNRInput{AssetID: req.AssetID, FilePath: req.FilePath, UseSilentSegments: true}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeSNR },
+	})
+	RegisterFeature(Feature{
+		Name:     "snr_profile",
+		Activity: "ComputeSNRProfile",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeSNRProfileInput{AssetID: req.AssetID, FilePath: req.FilePath, UseSilentSegments: true}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeSNRProfile },
+	})
+	RegisterFeature(Feature{
+		Name:     "spectral_flatness",
+		Activity: "ComputeSpectralFlatness",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeSpectralFlatnessInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeSpectralFlatness },
+	})
+	RegisterFeature(Feature{
+		Name:     "true_peak",
+		Activity: "ComputeTruePeak",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeTruePeakInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeTruePeak },
+	})
+	RegisterFeature(Feature{
+		Name:     "mfcc",
+		Activity: "ComputeMFCC",
+		Input: func(req FeatureRequest) interface{} {
+			return ComputeMFCCInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ComputeMFCC },
+	})
+}
+
 // ComputeSNR computes the Signal-to-Noise Ratio (SNR) of an audio file in dB.
 // SNR is calculated as 10 * log10(signal_power / noise_power).
 // Signal power is computed from the RMS of all samples.
 // Noise power is estimated from samples below a threshold or from silent segments.
+// StartSeconds and EndSeconds restrict the analysis to a time window, e.g. a
+// section known to be representative; it must lie within the file.
+// The result of an earlier run on the same content with the same params is
+// returned from the features table instead, unless ForceRecompute is set.
 func (ac *ActivitiesClient) ComputeSNR(ctx context.Context, input ComputeSNRInput) (*ComputeSNROutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	windowed := input.StartSeconds != 0 || input.EndSeconds != 0
+	if input.StartSeconds < 0 || input.EndSeconds < 0 || (input.EndSeconds != 0 && input.EndSeconds <= input.StartSeconds) {
+		return nil, invalidInputError("invalid SNR window %gs to %gs: times must be non-negative and the end after the start",
+			input.StartSeconds, input.EndSeconds)
+	}
+
 	// Default noise threshold if not provided
 	noiseThreshold := input.NoiseThreshold
 	if noiseThreshold == 0 {
 		noiseThreshold = 0.01 // Default 1% threshold
 	}
 
-	// Open and decode the audio file
-	// Convert to absolute path to avoid working directory issues
+	// Open and decode the audio file (storage resolves relative paths
+	// against the data directory)
 	filePath := input.FilePath
-	if !filepath.IsAbs(filePath) {
-		// Try to get absolute path
-		absPath, err := filepath.Abs(filePath)
-		if err == nil {
-			filePath = absPath
-		}
-	}
-
-	file, err := os.Open(filePath)
+	file, err := ac.storage.Open(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open audio file (path: %s, original: %s): %w", filePath, input.FilePath, err)
+		return nil, fmt.Errorf("failed to open audio file %s: %w", filePath, err)
 	}
 	defer file.Close()
 
-	// Get file info for better error messages
-	fileInfo, err := file.Stat()
+	// Get file size for better error messages and reject files over the
+	// maximum size (this also rewinds the file)
+	fileSize, err := ac.checkFileSize(file, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat audio file: %w", err)
+		return nil, err
+	}
+
+	params := map[string]interface{}{
+		"noise_threshold":     noiseThreshold,
+		"use_silent_segments": input.UseSilentSegments,
+		"per_channel":         input.PerChannel,
+	}
+	if windowed {
+		// Only set for windows, so results cached before windows existed still match
+		params["start_seconds"] = input.StartSeconds
+		params["end_seconds"] = input.EndSeconds
+	}
+	if ac.dbClient != nil {
+		// The hash of the analyzed file is recorded with the params, so a cached
+		// result is only reused for the same content even when it was stored
+		// against an asset with different content (e.g. the untrimmed original)
+		contentHash, err := ac.hashContent(file)
+		if err != nil {
+			return nil, err
+		}
+		params["content_hash"] = contentHash
+		if !input.ForceRecompute {
+			if output, ok := ac.cachedSNR(ctx, input.AssetID, contentHash, params); ok {
+				return output, nil
+			}
+		}
 	}
-	fileSize := fileInfo.Size()
 
-	// Ensure file is at beginning (should be, but just to be safe)
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek to beginning of file: %w", err)
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	// Create decoder - use exact same pattern as TrimSilence
-	decoder := wav.NewDecoder(file)
-	if !decoder.IsValidFile() {
-		return nil, fmt.Errorf("file is not a valid WAV file (file: %s, size: %d bytes)", filePath, fileSize)
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return nil, audioFormatError(filePath, fmt.Errorf("%w (file: %s, size: %d bytes)", err, filePath, fileSize))
 	}
 
 	format := decoder.Format()
 	channels := int(format.NumChannels)
 
-	// Read all audio samples using FullPCMBuffer which allocates the buffer for us
-	buf, err := decoder.FullPCMBuffer()
+	// Convert threshold to sample value (assuming 16-bit audio, range -32768 to 32767)
+	maxSampleValue := 32767.0
+	acc := &snrAccumulator{
+		channels:          channels,
+		thresholdValue:    int(noiseThreshold * maxSampleValue),
+		useSilentSegments: input.UseSilentSegments,
+	}
+
+	// In per-channel mode every chunk also goes to an accumulator per channel;
+	// the combined values are computed either way
+	add := acc.add
+	var channelAcc *channelSNRAccumulator
+	if input.PerChannel {
+		channelAcc = newChannelSNRAccumulator(channels, acc.thresholdValue, input.UseSilentSegments)
+		add = func(samples []int) {
+			acc.add(samples)
+			channelAcc.add(samples)
+		}
+	}
+
+	// A time window passes on only the samples inside it. totalSamples counts
+	// every sample decoded, to check the window against the file's duration.
+	totalSamples := 0
+	if windowed {
+		windowStart := int(input.StartSeconds*float64(format.SampleRate)) * channels
+		windowEnd := math.MaxInt
+		if input.EndSeconds != 0 {
+			windowEnd = int(input.EndSeconds*float64(format.SampleRate)) * channels
+		}
+		addAll := add
+		add = func(samples []int) {
+			from := totalSamples
+			totalSamples += len(samples)
+			if lo, hi := max(from, windowStart), min(totalSamples, windowEnd); lo < hi {
+				addAll(samples[lo-from : hi-from])
+			}
+		}
+	}
+
+	// The sample loops run under the hang detector, which cancels them if
+	// they stall
+	hangCtx, cancelHang := ac.detectHang(ctx, "ComputeSNR")
+	defer cancelHang()
+
+	// Large files are decoded in fixed-size chunks so we never hold every sample in memory
+	if input.Streaming || fileSize > streamingDecodeThreshold {
+		err = decodeStreaming(hangCtx, decoder, channels, add)
+	} else {
+		// Read all audio samples at once, scaled to 16-bit
+		var samples []int
+		samples, err = decodeSamples(decoder)
+		if err == nil {
+			err = addChunked(hangCtx, samples, hangCheckFrames*channels, add)
+		}
+	}
 	if err != nil {
+		if hangErr := hangError(ctx, err, "ComputeSNR"); hangErr != nil {
+			return nil, hangErr
+		}
 		return nil, fmt.Errorf("failed to decode audio (file: %s, size: %d bytes): %w", filePath, fileSize, err)
 	}
-	samples := buf.Data
+	acc.flush()
 
-	if len(samples) == 0 {
-		return nil, fmt.Errorf("audio file contains no samples (file: %s, size: %d bytes, sample rate: %d, channels: %d). "+
-			"Please verify the file is a valid PCM WAV file", filePath, fileSize, format.SampleRate, format.NumChannels)
+	if windowed && totalSamples > 0 {
+		duration := float64(totalSamples/channels) / float64(format.SampleRate)
+		if input.StartSeconds >= duration || input.EndSeconds > duration {
+			return nil, invalidInputError("SNR window %gs to %gs is outside the file's %gs duration",
+				input.StartSeconds, input.EndSeconds, duration)
+		}
+		if acc.signalCount == 0 {
+			return nil, invalidInputError("SNR window %gs to %gs holds no samples", input.StartSeconds, input.EndSeconds)
+		}
+	}
+	if acc.signalCount == 0 {
+		return nil, emptyAudioError(filePath)
 	}
 
-	// Convert threshold to sample value (assuming 16-bit audio, range -32768 to 32767)
-	maxSampleValue := 32767.0
-	thresholdValue := int(noiseThreshold * maxSampleValue)
+	output := acc.result()
+	featureData := map[string]interface{}{
+		"snr":          output.SNR,
+		"signal_power": output.SignalPower,
+		"noise_power":  output.NoisePower,
+		"signal_rms":   output.SignalRMS,
+		"noise_rms":    output.NoiseRMS,
+	}
+	if channelAcc != nil {
+		output.ChannelSNR = channelAcc.snrs()
+		// JSON object keys are strings, so the channel index is stored as one
+		channelSNR := make(map[string]float64, len(output.ChannelSNR))
+		for ch, snr := range output.ChannelSNR {
+			channelSNR[strconv.Itoa(ch)] = snr
+		}
+		featureData["channel_snr"] = channelSNR
+	}
+	metrics.SNRDuration.Observe(time.Since(startTime).Seconds())
 
-	// Calculate signal power (mean of squares) and RMS
-	var signalSumSquared float64
-	for _, sample := range samples {
-		sampleFloat := float64(sample)
-		signalSumSquared += sampleFloat * sampleFloat
+	// Store feature in database if asset ID is provided and db client is available
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "snr", featureData, params); err != nil {
+		return nil, err
 	}
 
-	signalPower := 0.0
-	signalRMS := 0.0
-	if len(samples) > 0 {
-		// Signal power is the mean of squares
-		signalPower = signalSumSquared / float64(len(samples))
-		// RMS is the square root of the mean of squares
-		if signalPower > 0 {
-			signalRMS = math.Sqrt(signalPower)
+	return output, nil
+}
+
+// cachedSNR returns the SNR stored by an earlier ComputeSNR run on content
+// with the given hash and params, recording it for assetID too if it was
+// stored for another asset. Lookup errors are logged and reported as a miss,
+// so the SNR is computed as if there were no cache.
+func (ac *ActivitiesClient) cachedSNR(ctx context.Context, assetID, contentHash string, params map[string]interface{}) (*ComputeSNROutput, bool) {
+	logger := activity.GetLogger(ctx)
+	feature, err := ac.dbClient.GetFeatureByHashAndType(ctx, contentHash, "snr", params)
+	if err != nil {
+		if !errors.Is(err, database.ErrFeatureNotFound) {
+			logger.Warn("Failed to look up cached SNR, computing it", "error", err)
 		}
+		return nil, false
+	}
+	output, err := snrFromFeatureData(feature.FeatureData)
+	if err != nil {
+		logger.Warn("Ignoring unreadable cached SNR", "feature_id", feature.ID, "error", err)
+		return nil, false
 	}
 
-	// Calculate noise power
-	var noiseSamples []int
-	if input.UseSilentSegments {
-		// Estimate noise from silent segments (consecutive samples below threshold)
-		// For simplicity, we'll use all samples below threshold
-		for i := 0; i < len(samples); i += channels {
-			isSilent := true
-			for ch := 0; ch < channels && i+ch < len(samples); ch++ {
-				absValue := samples[i+ch]
-				if absValue < 0 {
-					absValue = -absValue
-				}
-				if absValue > thresholdValue {
-					isSilent = false
-					break
-				}
-			}
-			if isSilent {
-				for ch := 0; ch < channels && i+ch < len(samples); ch++ {
-					noiseSamples = append(noiseSamples, samples[i+ch])
-				}
-			}
+	logger.Info("Reusing cached SNR", "feature_id", feature.ID, "computed_at", feature.ComputedAt)
+	metrics.FeatureCacheHits.WithLabelValues("snr").Inc()
+	if assetID != feature.AssetID {
+		// Under the fail policy, falling back to computing the SNR leaves
+		// ComputeSNR's own insert to fail the activity
+		if err = ac.recordFeature(ctx, &output.DBStatus, assetID, "snr", feature.FeatureData, params); err != nil {
+			return nil, false
 		}
-	} else {
+	}
+	return output, true
+}
+
+// snrFromFeatureData rebuilds a ComputeSNR result from the feature_data it
+// stored
+func snrFromFeatureData(data map[string]interface{}) (*ComputeSNROutput, error) {
+	output := &ComputeSNROutput{}
+	for key, field := range map[string]*float64{
+		"snr":          &output.SNR,
+		"signal_power": &output.SignalPower,
+		"noise_power":  &output.NoisePower,
+		"signal_rms":   &output.SignalRMS,
+		"noise_rms":    &output.NoiseRMS,
+	} {
+		value, ok := data[key].(float64)
+		if !ok {
+			return nil, fmt.Errorf("missing or non-numeric %q", key)
+		}
+		*field = value
+	}
+
+	channelSNR, ok := data["channel_snr"]
+	if !ok {
+		return output, nil
+	}
+	byChannel, ok := channelSNR.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("channel_snr is not an object")
+	}
+	output.ChannelSNR = make([]float64, len(byChannel))
+	for ch := range output.ChannelSNR {
+		value, ok := byChannel[strconv.Itoa(ch)].(float64)
+		if !ok {
+			return nil, fmt.Errorf("missing or non-numeric SNR of channel %d", ch)
+		}
+		output.ChannelSNR[ch] = value
+	}
+	return output, nil
+}
+
+// ComputeSNRProfile computes SNR over sliding windows, so noisy sections of a
+// recording can be located instead of being averaged into one value. Each
+// window is measured the same way ComputeSNR measures a whole file. A file
+// shorter than one window yields a single window covering all of it.
+func (ac *ActivitiesClient) ComputeSNRProfile(ctx context.Context, input ComputeSNRProfileInput) (*ComputeSNRProfileOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	noiseThreshold := input.NoiseThreshold
+	if noiseThreshold == 0 {
+		noiseThreshold = 0.01 // Default 1% threshold
+	}
+	windowSeconds := input.WindowSeconds
+	if windowSeconds == 0 {
+		windowSeconds = 1.0
+	}
+	hopSeconds := input.HopSeconds
+	if hopSeconds == 0 {
+		hopSeconds = 0.5
+	}
+	if windowSeconds < 0 || hopSeconds < 0 {
+		return nil, invalidInputError("window and hop must be positive, got %.3fs and %.3fs", windowSeconds, hopSeconds)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	channels := format.NumChannels
+	sampleRate := float64(format.SampleRate)
+
+	frames := len(samples) / channels
+	windowFrames := max(1, int(windowSeconds*sampleRate))
+	hopFrames := max(1, int(hopSeconds*sampleRate))
+
+	output := &ComputeSNRProfileOutput{
+		WindowSeconds: windowSeconds,
+		HopSeconds:    hopSeconds,
+		MinSNR:        math.Inf(1),
+		MaxSNR:        math.Inf(-1),
+	}
+	sum := 0.0
+	for start := 0; start == 0 || start+windowFrames <= frames; start += hopFrames {
+		end := min(start+windowFrames, frames)
+		acc := &snrAccumulator{
+			channels:          channels,
+			thresholdValue:    int(noiseThreshold * 32767),
+			useSilentSegments: input.UseSilentSegments,
+		}
+		acc.add(samples[start*channels : end*channels])
+		acc.flush()
+		snr := acc.result().SNR
+
+		output.Windows = append(output.Windows, SNRWindow{
+			StartTime: float64(start) / sampleRate,
+			EndTime:   float64(end) / sampleRate,
+			SNR:       snr,
+		})
+		output.MinSNR = math.Min(output.MinSNR, snr)
+		output.MaxSNR = math.Max(output.MaxSNR, snr)
+		sum += snr
+	}
+	output.MeanSNR = sum / float64(len(output.Windows))
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "snr_profile",
+		map[string]interface{}{
+			"windows":  output.Windows,
+			"min_snr":  output.MinSNR,
+			"max_snr":  output.MaxSNR,
+			"mean_snr": output.MeanSNR,
+		},
+		map[string]interface{}{
+			"window_seconds":      windowSeconds,
+			"hop_seconds":         hopSeconds,
+			"noise_threshold":     noiseThreshold,
+			"use_silent_segments": input.UseSilentSegments,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// ComputeSpectralFlatness computes the spectral flatness (Wiener entropy) of an
+// audio file: per STFT frame, the geometric mean of the power spectrum divided
+// by its arithmetic mean. Values near 0 indicate tonal content and values near
+// 1 indicate noise-like content. Silent frames are skipped.
+func (ac *ActivitiesClient) ComputeSpectralFlatness(ctx context.Context, input ComputeSpectralFlatnessInput) (*ComputeSpectralFlatnessOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	frameSize := input.FrameSize
+	if frameSize == 0 {
+		frameSize = defaultFrameSize
+	}
+	if !isPowerOfTwo(frameSize) {
+		return nil, invalidInputError("frame size must be a power of two, got %d", frameSize)
+	}
+	hopSize := input.HopSize
+	if hopSize <= 0 {
+		hopSize = defaultHopSize
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var frameFlatness []float64
+	sum := 0.0
+	for _, power := range powerSpectra(mixToMono(samples, format.NumChannels), frameSize, hopSize) {
+		logSum := 0.0
+		powerSum := 0.0
+		for _, p := range power {
+			logSum += math.Log(p + minFramePower)
+			powerSum += p
+		}
+		arithmeticMean := powerSum / float64(len(power))
+		if arithmeticMean < minFramePower {
+			continue
+		}
+		flatness := math.Exp(logSum/float64(len(power))) / arithmeticMean
+		frameFlatness = append(frameFlatness, flatness)
+		sum += flatness
+	}
+
+	output := &ComputeSpectralFlatnessOutput{
+		FrameFlatness: frameFlatness,
+		FrameSize:     frameSize,
+		HopSize:       hopSize,
+	}
+	if len(frameFlatness) > 0 {
+		output.MeanFlatness = sum / float64(len(frameFlatness))
+	}
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "spectral_flatness",
+		map[string]interface{}{
+			"mean_flatness":  output.MeanFlatness,
+			"frame_flatness": output.FrameFlatness,
+		},
+		map[string]interface{}{
+			"frame_size":  frameSize,
+			"hop_size":    hopSize,
+			"window":      "hann",
+			"sample_rate": format.SampleRate,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// ComputeTruePeak measures the sample peak and the true (inter-sample) peak of
+// an audio file. The true peak is found by oversampling each channel with a
+// polyphase interpolation filter, since reconstructed analog peaks can exceed
+// the largest sample. Both are reported relative to full scale.
+func (ac *ActivitiesClient) ComputeTruePeak(ctx context.Context, input ComputeTruePeakInput) (*ComputeTruePeakOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	factor := input.OversamplingFactor
+	if factor == 0 {
+		factor = defaultOversamplingFactor
+	}
+	if factor < 1 {
+		return nil, invalidInputError("oversampling factor must be positive, got %d", factor)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	samplePeak, truePeak := samplePeaks(samples, format.NumChannels, factor)
+	output := &ComputeTruePeakOutput{
+		SamplePeak:         samplePeak,
+		SamplePeakDBFS:     peakDB(samplePeak),
+		TruePeak:           truePeak,
+		TruePeakDBTP:       peakDB(truePeak),
+		OversamplingFactor: factor,
+	}
+
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "true_peak",
+		map[string]interface{}{
+			"sample_peak":      output.SamplePeak,
+			"sample_peak_dbfs": output.SamplePeakDBFS,
+			"true_peak":        output.TruePeak,
+			"true_peak_dbtp":   output.TruePeakDBTP,
+		},
+		map[string]interface{}{
+			"oversampling_factor": factor,
+			"taps_per_phase":      oversamplingTapsPerPhase,
+			"sample_rate":         format.SampleRate,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// maxInlineMFCCFrames is the largest MFCC matrix returned in the activity
+// result; longer files are only available through the matrix file
+const maxInlineMFCCFrames = 2000
+
+// ComputeMFCC computes Mel-frequency cepstral coefficients for each STFT frame
+// of an audio file (mel filterbank, log, DCT). The full matrix can be large, so
+// it is written to a JSON file and only per-coefficient summary statistics and
+// the file path are stored in the "mfcc" feature.
+func (ac *ActivitiesClient) ComputeMFCC(ctx context.Context, input ComputeMFCCInput) (*ComputeMFCCOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	frameSize := input.FrameSize
+	if frameSize == 0 {
+		frameSize = defaultFrameSize
+	}
+	if !isPowerOfTwo(frameSize) {
+		return nil, invalidInputError("frame size must be a power of two, got %d", frameSize)
+	}
+	hopSize := input.HopSize
+	if hopSize <= 0 {
+		hopSize = defaultHopSize
+	}
+	numMels := input.NumMels
+	if numMels <= 0 {
+		numMels = defaultNumMels
+	}
+	numMFCC := input.NumMFCC
+	if numMFCC <= 0 {
+		numMFCC = defaultNumMFCC
+	}
+	if numMFCC > numMels {
+		return nil, invalidInputError("n_mfcc (%d) must not exceed n_mels (%d)", numMFCC, numMels)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := melFilterbank(numMels, frameSize, format.SampleRate)
+	var matrix [][]float64
+	for _, power := range powerSpectra(mixToMono(samples, format.NumChannels), frameSize, hopSize) {
+		matrix = append(matrix, mfcc(power, filters, numMFCC))
+	}
+
+	output := &ComputeMFCCOutput{
+		NumFrames: len(matrix),
+		Mean:      make([]float64, numMFCC),
+		StdDev:    make([]float64, numMFCC),
+		FrameSize: frameSize,
+		HopSize:   hopSize,
+		NumMels:   numMels,
+		NumMFCC:   numMFCC,
+	}
+	for _, row := range matrix {
+		for k, c := range row {
+			output.Mean[k] += c / float64(len(matrix))
+		}
+	}
+	for _, row := range matrix {
+		for k, c := range row {
+			d := c - output.Mean[k]
+			output.StdDev[k] += d * d / float64(len(matrix))
+		}
+	}
+	for k := range output.StdDev {
+		output.StdDev[k] = math.Sqrt(output.StdDev[k])
+	}
+	if len(matrix) <= maxInlineMFCCFrames {
+		output.Coefficients = matrix
+	}
+
+	outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	output.MatrixPath = storage.Join(outputDir, fmt.Sprintf("mfcc_%s_%s.json", input.AssetID, time.Now().Format("20060102_150405")))
+	if output.MatrixPath, err = ac.writeJSON(ctx, output.MatrixPath, matrix); err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{
+		"frame_size":  frameSize,
+		"hop_size":    hopSize,
+		"n_mels":      numMels,
+		"n_mfcc":      numMFCC,
+		"window":      "hann",
+		"mel_scale":   "htk",
+		"dct":         "ortho",
+		"sample_rate": format.SampleRate,
+	}
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "mfcc",
+		map[string]interface{}{
+			"n_frames":    output.NumFrames,
+			"mean":        output.Mean,
+			"std_dev":     output.StdDev,
+			"matrix_path": output.MatrixPath,
+		},
+		params,
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// writeJSON encodes v as JSON to a file in storage and returns the path it was
+// written to, which the overwrite policy may have changed, removing the file if
+// writing fails
+func (ac *ActivitiesClient) writeJSON(ctx context.Context, path string, v interface{}) (writtenPath string, err error) {
+	file, writtenPath, err := ac.createOutput(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer ac.closeOutput(ctx, file, writtenPath, &err)
+
+	if err = json.NewEncoder(file).Encode(v); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", writtenPath, err)
+	}
+	return writtenPath, nil
+}
+
+// recordFeature stores a computed feature for an asset. It is skipped when no
+// asset ID or database is available. A database error is handled by the
+// failure policy (see dbError), which flags status or returns the error.
+func (ac *ActivitiesClient) recordFeature(ctx context.Context, status *DBStatus, assetID, featureType string,
+	data, params map[string]interface{}) error {
+	if assetID == "" || ac.dbClient == nil {
+		return nil
+	}
+
+	dbFeature := &database.Feature{
+		ID:                uuid.New().String(),
+		AssetID:           assetID,
+		FeatureType:       featureType,
+		FeatureData:       data,
+		ComputationParams: params,
+		ComputedAt:        time.Now(),
+	}
+	if err := ac.dbClient.InsertFeatureContext(ctx, dbFeature); err != nil {
+		metrics.DBInsertErrors.WithLabelValues("features").Inc()
+		return ac.dbError(ctx, status, "insert "+featureType+" feature", err)
+	}
+	return nil
+}
+
+// streamingDecodeThreshold is the file size above which ComputeSNR decodes in chunks
+const streamingDecodeThreshold = 64 << 20 // 64MB
+
+// streamingChunkFrames is the number of frames decoded per chunk when
+// streaming, large enough for sumSquares to split a chunk across CPUs
+const streamingChunkFrames = 1 << 16
+
+// decodeStreaming reads the decoder's PCM data in fixed-size chunks and passes
+// each chunk, scaled to 16-bit, to fn. The chunk slice is reused between calls.
+// It stops with ctx's error once ctx is done.
+func decodeStreaming(ctx context.Context, decoder *pcmDecoder, channels int, fn func(samples []int)) error {
+	buf := &audio.IntBuffer{Data: make([]int, streamingChunkFrames*channels)}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := decoder.PCMBuffer(buf)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		normalizeSamples(decoder, buf.Data[:n])
+		fn(buf.Data[:n])
+	}
+}
+
+// addChunked passes samples to fn in chunks of at most chunkSize samples,
+// stopping with ctx's error once ctx is done
+func addChunked(ctx context.Context, samples []int, chunkSize int, fn func(samples []int)) error {
+	for len(samples) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n := min(chunkSize, len(samples))
+		fn(samples[:n])
+		samples = samples[n:]
+	}
+	return nil
+}
+
+// snrAccumulator accumulates signal and noise power incrementally so SNR can be
+// computed over a full buffer or over a stream of chunks with identical results
+type snrAccumulator struct {
+	channels          int
+	thresholdValue    int
+	useSilentSegments bool
+
+	signalSumSquared float64
+	signalBlockSum   int64 // exact sum of squares since the last whole sumSquaresBlock
+	signalCount      int
+	noiseSumSquared  float64
+	noiseCount       int
+
+	pending []int // partial frame carried over to the next chunk
+}
+
+// add accumulates a chunk of interleaved samples
+func (a *snrAccumulator) add(samples []int) {
+	a.addSignal(samples)
+
+	if !a.useSilentSegments {
 		// Use all samples below threshold as noise
 		for _, sample := range samples {
-			absValue := sample
-			if absValue < 0 {
-				absValue = -absValue
-			}
-			if absValue <= thresholdValue {
-				noiseSamples = append(noiseSamples, sample)
+			if absInt(sample) <= a.thresholdValue {
+				a.addNoise(sample)
 			}
 		}
+		return
+	}
+
+	// Estimate noise from silent frames (every channel below threshold).
+	// Complete a partial frame left over from the previous chunk first.
+	if len(a.pending) > 0 {
+		need := a.channels - len(a.pending)
+		if len(samples) < need {
+			a.pending = append(a.pending, samples...)
+			return
+		}
+		a.pending = append(a.pending, samples[:need]...)
+		a.addFrame(a.pending)
+		a.pending = a.pending[:0]
+		samples = samples[need:]
+	}
+
+	i := 0
+	for ; i+a.channels <= len(samples); i += a.channels {
+		a.addFrame(samples[i : i+a.channels])
+	}
+	a.pending = append(a.pending, samples[i:]...)
+}
+
+// addSignal adds samples to the signal power. Squares are summed exactly in
+// blocks of sumSquaresBlock samples counted from the start of the audio, and
+// each whole block is added to the float64 total in order, so the total
+// doesn't depend on how the audio was split into chunks or across CPUs.
+func (a *snrAccumulator) addSignal(samples []int) {
+	for len(samples) > 0 {
+		n := min(len(samples), sumSquaresBlock-a.signalCount%sumSquaresBlock)
+		a.signalBlockSum += sumSquares(samples[:n])
+		a.signalCount += n
+		if a.signalCount%sumSquaresBlock == 0 {
+			a.signalSumSquared += float64(a.signalBlockSum)
+			a.signalBlockSum = 0
+		}
+		samples = samples[n:]
+	}
+}
+
+// flush accumulates a trailing partial frame, if any
+func (a *snrAccumulator) flush() {
+	if len(a.pending) > 0 {
+		a.addFrame(a.pending)
+		a.pending = nil
+	}
+}
+
+// addFrame adds a frame to the noise estimate if it is silent
+func (a *snrAccumulator) addFrame(frame []int) {
+	for _, sample := range frame {
+		if absInt(sample) > a.thresholdValue {
+			return
+		}
+	}
+	for _, sample := range frame {
+		a.addNoise(sample)
+	}
+}
+
+func (a *snrAccumulator) addNoise(sample int) {
+	sampleFloat := float64(sample)
+	a.noiseSumSquared += sampleFloat * sampleFloat
+	a.noiseCount++
+}
+
+// result computes the SNR in dB and the signal/noise power and RMS
+func (a *snrAccumulator) result() *ComputeSNROutput {
+	signalPower := 0.0
+	signalRMS := 0.0
+	if a.signalCount > 0 {
+		// Signal power is the mean of squares
+		signalPower = (a.signalSumSquared + float64(a.signalBlockSum)) / float64(a.signalCount)
+		// RMS is the square root of the mean of squares
+		if signalPower > 0 {
+			signalRMS = math.Sqrt(signalPower)
+		}
 	}
 
 	// Calculate noise power (mean of squares) and RMS
 	noisePower := 0.0
 	noiseRMS := 0.0
-	if len(noiseSamples) > 0 {
-		var noiseSumSquared float64
-		for _, sample := range noiseSamples {
-			sampleFloat := float64(sample)
-			noiseSumSquared += sampleFloat * sampleFloat
-		}
-		// Noise power is the mean of squares
-		noisePower = noiseSumSquared / float64(len(noiseSamples))
-		// RMS is the square root of the mean of squares
+	if a.noiseCount > 0 {
+		noisePower = a.noiseSumSquared / float64(a.noiseCount)
 		if noisePower > 0 {
 			noiseRMS = math.Sqrt(noisePower)
 		}
@@ -168,47 +862,67 @@ func (ac *ActivitiesClient) ComputeSNR(ctx context.Context, input ComputeSNRInpu
 		snr = 120.0
 	}
 
-	output := &ComputeSNROutput{
+	return &ComputeSNROutput{
 		SNR:         snr,
 		SignalPower: signalPower,
 		NoisePower:  noisePower,
 		SignalRMS:   signalRMS,
 		NoiseRMS:    noiseRMS,
 	}
+}
 
-	// Store feature in database if asset ID is provided and db client is available
-	if input.AssetID != "" && ac.dbClient != nil {
-		featureID := uuid.New().String()
-
-		// Prepare feature data
-		featureData := map[string]interface{}{
-			"snr":          snr,
-			"signal_power": signalPower,
-			"noise_power":  noisePower,
-			"signal_rms":   signalRMS,
-			"noise_rms":    noiseRMS,
-		}
+// channelSNRAccumulator deinterleaves samples and accumulates SNR for each
+// channel separately, treating every channel as a mono signal. With silent
+// segments, a channel's noise comes from the stretches where that channel
+// alone is silent.
+type channelSNRAccumulator struct {
+	channels []*snrAccumulator
+	scratch  [][]int // per-channel samples of the current chunk, reused between chunks
+	next     int     // channel of the next sample, since chunks needn't end on a frame boundary
+}
 
-		// Prepare computation parameters
-		computationParams := map[string]interface{}{
-			"noise_threshold":     noiseThreshold,
-			"use_silent_segments": input.UseSilentSegments,
+func newChannelSNRAccumulator(channels, thresholdValue int, useSilentSegments bool) *channelSNRAccumulator {
+	c := &channelSNRAccumulator{
+		channels: make([]*snrAccumulator, channels),
+		scratch:  make([][]int, channels),
+	}
+	for ch := range c.channels {
+		c.channels[ch] = &snrAccumulator{
+			channels:          1,
+			thresholdValue:    thresholdValue,
+			useSilentSegments: useSilentSegments,
 		}
+	}
+	return c
+}
 
-		dbFeature := &database.Feature{
-			ID:                featureID,
-			AssetID:           input.AssetID,
-			FeatureType:       "snr",
-			FeatureData:       featureData,
-			ComputationParams: computationParams,
-			ComputedAt:        time.Now(),
-		}
+// add deinterleaves a chunk of interleaved samples into the channel accumulators
+func (c *channelSNRAccumulator) add(samples []int) {
+	for ch := range c.scratch {
+		c.scratch[ch] = c.scratch[ch][:0]
+	}
+	for _, sample := range samples {
+		c.scratch[c.next] = append(c.scratch[c.next], sample)
+		c.next = (c.next + 1) % len(c.scratch)
+	}
+	for ch, acc := range c.channels {
+		acc.add(c.scratch[ch])
+	}
+}
 
-		if err := ac.dbClient.InsertFeature(dbFeature); err != nil {
-			// Log error but don't fail the activity
-			activity.GetLogger(ctx).Error("Failed to insert feature into database", "error", err)
-		}
+// snrs returns the SNR in dB of each channel, indexed by channel
+func (c *channelSNRAccumulator) snrs() []float64 {
+	snrs := make([]float64, len(c.channels))
+	for ch, acc := range c.channels {
+		snrs[ch] = acc.result().SNR
 	}
+	return snrs
+}
 
-	return output, nil
+// absInt returns the absolute value of a sample
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
 }
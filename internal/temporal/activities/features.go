@@ -0,0 +1,70 @@
+package activities
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Feature describes a feature extraction activity that can be requested by
+// name, e.g. by FeatureExtractionWorkflow or the reprocess command. Features
+// register themselves with RegisterFeature from an init function next to
+// their activity, and RegisterActivities registers every one with the worker,
+// so adding a feature needs no other wiring.
+type Feature struct {
+	Name     string // feature type requested by callers, e.g. "snr"
+	Activity string // activity name the feature is registered and executed under
+
+	// Input builds the activity's input for a request, filling in the
+	// feature's default parameters
+	Input func(req FeatureRequest) interface{}
+	// Handler returns the activity function bound to ac
+	Handler func(ac *ActivitiesClient) interface{}
+}
+
+// FeatureRequest identifies the audio a feature is computed for
+type FeatureRequest struct {
+	AssetID  string // asset the feature is stored against (optional)
+	FilePath string // path to the audio file
+}
+
+// features holds the registered features by name. It is only written by
+// RegisterFeature during package initialization, so reads need no locking.
+var features = make(map[string]Feature)
+
+// RegisterFeature adds a feature to the registry. It must be called from an
+// init function and panics if the feature is incomplete or its name is taken,
+// since either is a programming error.
+func RegisterFeature(f Feature) {
+	if f.Name == "" || f.Activity == "" || f.Input == nil || f.Handler == nil {
+		panic(fmt.Sprintf("incomplete feature registration %q", f.Name))
+	}
+	if _, exists := features[f.Name]; exists {
+		panic(fmt.Sprintf("feature %q registered twice", f.Name))
+	}
+	features[f.Name] = f
+}
+
+// LookupFeature returns the registered feature with the given name
+func LookupFeature(name string) (Feature, bool) {
+	f, ok := features[name]
+	return f, ok
+}
+
+// Features returns every registered feature, sorted by name
+func Features() []Feature {
+	all := make([]Feature, 0, len(features))
+	for _, f := range features {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// FeatureNames returns the names of every registered feature in sorted order
+func FeatureNames() []string {
+	names := make([]string, 0, len(features))
+	for _, f := range Features() {
+		names = append(names, f.Name)
+	}
+	return names
+}
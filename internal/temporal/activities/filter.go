@@ -0,0 +1,89 @@
+package activities
+
+import "math"
+
+// Filter types accepted by ApplyFilter
+const (
+	FilterHighPass = "highpass"
+	FilterLowPass  = "lowpass"
+)
+
+// Butterworth orders accepted by ApplyFilter
+const (
+	defaultFilterOrder = 2
+	maxFilterOrder     = 8
+)
+
+// biquad is one second-order IIR section, with coefficients normalized so
+// a0 is 1. A first-order section has b2 and a2 zero.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+}
+
+// butterworthSections designs a Butterworth high-pass or low-pass filter of the
+// given order as a cascade of biquads, using the bilinear transform with the
+// cutoff prewarped so the -3 dB point lands exactly on cutoffHz. Pairs of poles
+// become second-order sections with the Butterworth Q of their pole angle; an
+// odd order adds one first-order section for the real pole.
+func butterworthSections(filterType string, order int, cutoffHz, sampleRate float64) []biquad {
+	w0 := 2 * math.Pi * cutoffHz / sampleRate
+	cosW0, sinW0 := math.Cos(w0), math.Sin(w0)
+	highPass := filterType == FilterHighPass
+
+	var sections []biquad
+	for k := 0; k < order/2; k++ {
+		q := 1 / (2 * math.Sin(float64(2*k+1)*math.Pi/float64(2*order)))
+		alpha := sinW0 / (2 * q)
+		a0 := 1 + alpha
+		s := biquad{a1: -2 * cosW0 / a0, a2: (1 - alpha) / a0}
+		if highPass {
+			s.b0, s.b1, s.b2 = (1+cosW0)/2/a0, -(1+cosW0)/a0, (1+cosW0)/2/a0
+		} else {
+			s.b0, s.b1, s.b2 = (1-cosW0)/2/a0, (1-cosW0)/a0, (1-cosW0)/2/a0
+		}
+		sections = append(sections, s)
+	}
+	if order%2 == 1 {
+		k := math.Tan(w0 / 2)
+		s := biquad{a1: (k - 1) / (k + 1)}
+		if highPass {
+			s.b0, s.b1 = 1/(1+k), -1/(1+k)
+		} else {
+			s.b0, s.b1 = k/(1+k), k/(1+k)
+		}
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+// applyFilter runs each channel of the interleaved samples through the cascade
+// of sections in place, starting from silence, and clamps the results to the
+// 16-bit range. It returns the number of samples that were clamped.
+func applyFilter(samples []int, channels int, sections []biquad) (clipped int) {
+	// Transposed direct form II state: two values per section per channel
+	state := make([][2]float64, len(sections)*channels)
+	for i, sample := range samples {
+		ch := i % channels
+		value := float64(sample)
+		for j, s := range sections {
+			z := &state[ch*len(sections)+j]
+			out := s.b0*value + z[0]
+			z[0] = s.b1*value - s.a1*out + z[1]
+			z[1] = s.b2*value - s.a2*out
+			value = out
+		}
+
+		value = math.Round(value)
+		switch {
+		case value > math.MaxInt16:
+			value = math.MaxInt16
+			clipped++
+		case value < math.MinInt16:
+			value = math.MinInt16
+			clipped++
+		}
+		samples[i] = int(value)
+	}
+	return clipped
+}
@@ -0,0 +1,76 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/pphelan007/davidAI/internal/utils"
+)
+
+// ErrTypeHangDetected is the application error type activities use when a
+// sample-processing loop runs past the hang timeout. It is retryable: a stall
+// is usually a starved or wedged worker, and another attempt may land on a
+// healthy one.
+const ErrTypeHangDetected = "HangDetected"
+
+// hangCheckFrames is how many frames sample loops process between checks of
+// the hang detector, so the check stays cheap next to the work it guards
+const hangCheckFrames = 1 << 16
+
+// hangFraction is how far into an attempt, as a share of the time from its
+// start to its deadline, the hang detector fires. It has to fire before the
+// deadline, or Temporal times the attempt out first and the detector never
+// helps.
+const hangFraction = 0.8
+
+// detectHang returns a context that utils.DetectAndKillHang cancels once
+// hangFraction of the attempt's time has passed, or the configured hang
+// timeout if that comes first. Sample-processing loops run under it and check
+// it every hangCheckFrames frames. The attempt's deadline comes from the
+// activity options the workflow was started with, so a caller that raises
+// the start-to-close timeout for long files raises the hang timeout with it.
+// With hang detection off, or outside an activity without a hang timeout,
+// ctx itself is returned.
+func (ac *ActivitiesClient) detectHang(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if !ac.hangDetection {
+		return ctx, func() {}
+	}
+	timeout, limited := ac.hangTimeout, ac.hangTimeout > 0
+	if activity.IsActivity(ctx) {
+		if deadline, ok := hangDeadline(activity.GetInfo(ctx)); ok {
+			if untilDeadline := time.Until(deadline); !limited || untilDeadline < timeout {
+				timeout, limited = untilDeadline, true
+			}
+		}
+	}
+	if !limited {
+		return ctx, func() {}
+	}
+	return utils.DetectAndKillHang(ctx, timeout, operation)
+}
+
+// hangDeadline returns the time hangFraction of the way from an attempt's
+// start to its deadline, and false if the attempt has no deadline
+func hangDeadline(info activity.Info) (time.Time, bool) {
+	if info.Deadline.IsZero() || info.StartedTime.IsZero() {
+		return time.Time{}, false
+	}
+	budget := info.Deadline.Sub(info.StartedTime)
+	return info.StartedTime.Add(time.Duration(float64(budget) * hangFraction)), true
+}
+
+// hangError returns the retryable HangDetected error if err came from the
+// detector returned by detectHang, and nil otherwise. ctx is the activity's own
+// context: if it is done too, the activity was cancelled or hit its
+// start-to-close timeout, and err is returned to Temporal as is. The result
+// must not be wrapped, or Temporal loses its error type.
+func hangError(ctx context.Context, err error, operation string) error {
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return nil
+	}
+	return temporal.NewApplicationErrorWithCause(operation+" exceeded the hang timeout", ErrTypeHangDetected, err)
+}
@@ -0,0 +1,79 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// The test environment runs activities with a 600s start-to-close timeout
+const testAttemptTimeout = 600 * time.Second
+
+func TestTrimSilenceHangDetected(t *testing.T) {
+	dir := t.TempDir()
+	// A hang timeout that has passed before the scan's first check stalls it
+	// as a wedged worker would
+	env, _ := newTestEnv(t, dir, ActivitiesConfig{HangDetection: true, HangTimeout: time.Nanosecond})
+
+	samples := append(make([]int, 2*hangCheckFrames), tone(1000, 1, 10000)...)
+	source := writeTestWAV(t, filepath.Join(dir, "source.wav"), samples, 44100, 1, 16)
+
+	_, err := env.ExecuteActivity("TrimSilence", TrimSilenceInput{SourcePath: source, SilenceThreshold: 0.01})
+	require.Error(t, err)
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &appErr), "got %v", err)
+	assert.Equal(t, ErrTypeHangDetected, appErr.Type())
+	assert.False(t, appErr.NonRetryable())
+}
+
+func TestDetectHangFollowsAttemptDeadline(t *testing.T) {
+	tests := []struct {
+		name      string
+		detection bool
+		timeout   time.Duration
+		want      time.Duration // time left until the returned context's deadline
+	}{
+		{"attempt deadline", true, 0, testAttemptTimeout * 4 / 5},
+		{"shorter hang timeout", true, time.Minute, time.Minute},
+		{"longer hang timeout", true, time.Hour, testAttemptTimeout * 4 / 5},
+		{"disabled", false, 0, testAttemptTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, ac := newTestEnv(t, t.TempDir(), ActivitiesConfig{HangDetection: tt.detection, HangTimeout: tt.timeout})
+			detect := func(ctx context.Context) (time.Duration, error) {
+				hangCtx, cancel := ac.detectHang(ctx, "test")
+				defer cancel()
+				deadline, ok := hangCtx.Deadline()
+				if !ok {
+					return 0, errors.New("no deadline")
+				}
+				return time.Until(deadline), nil
+			}
+			env.RegisterActivityWithOptions(detect, activity.RegisterOptions{Name: "detect"})
+
+			result, err := env.ExecuteActivity("detect")
+			require.NoError(t, err)
+			var left time.Duration
+			require.NoError(t, result.Get(&left))
+			assert.InDelta(t, tt.want.Seconds(), left.Seconds(), 5)
+		})
+	}
+}
+
+func TestHangDeadline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deadline, ok := hangDeadline(activity.Info{StartedTime: start, Deadline: start.Add(10 * time.Minute)})
+	require.True(t, ok)
+	assert.Equal(t, start.Add(8*time.Minute), deadline)
+
+	_, ok = hangDeadline(activity.Info{StartedTime: start})
+	assert.False(t, ok)
+}
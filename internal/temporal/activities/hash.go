@@ -0,0 +1,54 @@
+package activities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Content hash algorithms
+const (
+	HashSHA256 = "sha256" // SHA-256 (default)
+	HashXXHash = "xxhash" // 64-bit xxHash, much faster but not collision resistant
+)
+
+// NewContentHash returns a hash for algorithm, one of the Hash* constants,
+// and the prefix its hex digest is recorded with. A hash records its
+// algorithm this way so hashes from different algorithms never compare equal,
+// in lookups or in the feature cache; SHA-256 hashes stay unprefixed, as they
+// were before the algorithm was configurable, so existing assets still match.
+// An empty or unknown algorithm behaves like HashSHA256.
+func NewContentHash(algorithm string) (h hash.Hash, prefix string) {
+	if algorithm == HashXXHash {
+		return xxhash.New(), HashXXHash + ":"
+	}
+	return sha256.New(), ""
+}
+
+// HashFile returns the content hash of a local file as the activities record
+// it with algorithm, for callers outside the worker that derive workflow IDs
+// from content
+func HashFile(path, algorithm string) (string, error) {
+	file, err := os.Open(path) // #nosec G304 -- callers pass paths they have checked
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash, prefix := NewContentHash(algorithm)
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to compute hash: %w", err)
+	}
+	return prefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// newContentHash returns a hash for the configured algorithm (see
+// NewContentHash)
+func (ac *ActivitiesClient) newContentHash() (h hash.Hash, prefix string) {
+	return NewContentHash(ac.hashAlgorithm)
+}
@@ -0,0 +1,71 @@
+package activities
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// newTestEnv returns an activity test environment running the activities of
+// a client without Temporal or database clients, with dataDir as its data
+// directory
+func newTestEnv(t *testing.T, dataDir string, cfg ActivitiesConfig) (*testsuite.TestActivityEnvironment, *ActivitiesClient) {
+	t.Helper()
+	cfg.DataDir = dataDir
+	ac := NewActivitiesClient(nil, nil, cfg)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(ac)
+	return env, ac
+}
+
+// writeTestWAV writes interleaved samples as a PCM WAV file of the given bit
+// depth and returns its path
+func writeTestWAV(t *testing.T, path string, samples []int, sampleRate, channels, bitDepth int) string {
+	t.Helper()
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	encoder := wav.NewEncoder(file, sampleRate, bitDepth, channels, 1)
+	format := &audio.Format{NumChannels: channels, SampleRate: sampleRate}
+	require.NoError(t, encoder.Write(&audio.IntBuffer{Format: format, Data: samples, SourceBitDepth: bitDepth}))
+	require.NoError(t, encoder.Close())
+	return path
+}
+
+// readTestWAV decodes a WAV file, relative paths being under dir, and returns
+// its samples at their own bit depth
+func readTestWAV(t *testing.T, dir, path string) (samples []int, format *audio.Format, bitDepth int) {
+	t.Helper()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	decoder := wav.NewDecoder(file)
+	buf, err := decoder.FullPCMBuffer()
+	require.NoError(t, err)
+	return buf.Data, buf.Format, int(decoder.BitDepth)
+}
+
+// tone returns frames frames of a square wave at amplitude on every channel
+func tone(frames, channels, amplitude int) []int {
+	samples := make([]int, frames*channels)
+	for i := range samples {
+		if i/channels%2 == 0 {
+			samples[i] = amplitude
+		} else {
+			samples[i] = -amplitude
+		}
+	}
+	return samples
+}
@@ -0,0 +1,34 @@
+package activities
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// waitRetryJitter delays a retried activity attempt by a random duration up to
+// the configured retry jitter before it touches the database.
+//
+// Temporal's retry policy backs off exponentially but without jitter, so when a
+// database outage fails many activities at once their retries all fire at the
+// same moments and hit the recovering database in lockstep. Spreading each
+// retry over the jitter window breaks up that thundering herd. First attempts
+// are never delayed, and a jitter of zero disables the delay entirely, which
+// keeps tests deterministic.
+func (ac *ActivitiesClient) waitRetryJitter(ctx context.Context) error {
+	if ac.retryJitter <= 0 || activity.GetInfo(ctx).Attempt <= 1 {
+		return nil
+	}
+
+	delay := rand.N(ac.retryJitter) // #nosec G404 -- scheduling jitter, not security sensitive
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,232 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-audio/wav"
+)
+
+// bextChunkID is the RIFF chunk ID of the Broadcast Wave Format extension
+var bextChunkID = [4]byte{'b', 'e', 'x', 't'}
+
+// bextFixedSize is the size of the bext fields up to and including TimeReference
+const bextFixedSize = 256 + 32 + 32 + 10 + 8 + 8
+
+func init() {
+	RegisterFeature(Feature{
+		Name:     "metadata",
+		Activity: "ReadMetadata",
+		Input: func(req FeatureRequest) interface{} {
+			return ReadMetadataInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.ReadMetadata },
+	})
+}
+
+// ReadMetadata reads the metadata carried by a WAV file beyond its format:
+// bit depth, LIST/INFO fields, cue points, and the BWF bext chunk with its
+// timecode. AIFF and Ogg files only report their bit depth and encoding. The
+// result is stored as the asset's "metadata" feature.
+func (ac *ActivitiesClient) ReadMetadata(ctx context.Context, input ReadMetadataInput) (*ReadMetadataOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	file, err := ac.storage.Open(ctx, input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	container, err := detectContainer(file)
+	if err != nil {
+		return nil, audioFormatError(input.FilePath, err)
+	}
+	if container != containerWAV {
+		// INFO, cue and bext chunks are WAV-only, which leaves the format
+		output, formatErr := readFormatMetadata(ctx, file, container)
+		if formatErr != nil {
+			return nil, audioFormatError(input.FilePath, formatErr)
+		}
+		if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "metadata", map[string]interface{}{
+			"bit_depth": output.BitDepth,
+			"encoding":  output.Encoding,
+		}, nil); err != nil {
+			return nil, err
+		}
+		return output, nil
+	}
+
+	decoder := wav.NewDecoder(file)
+	if !decoder.IsValidFile() {
+		return nil, invalidAudioError(input.FilePath, ErrInvalidWAV)
+	}
+
+	output := &ReadMetadataOutput{
+		BitDepth: int(decoder.BitDepth),
+		Encoding: encodingName(int(decoder.WavAudioFormat)),
+	}
+
+	decoder.ReadMetadata()
+	if err = decoder.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read metadata chunks: %w", err)
+	}
+	if md := decoder.Metadata; md != nil {
+		output.Info = infoFields(md)
+		for _, cue := range md.CuePoints {
+			output.CuePoints = append(output.CuePoints, CuePoint{
+				ID:       string(bytes.TrimRight(cue.ID[:], "\x00")),
+				Position: cue.Position,
+			})
+		}
+	}
+
+	// The decoder skips bext chunks, so look for one separately
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind audio file: %w", err)
+	}
+	output.Broadcast, err = readBroadcastExtension(file, int(decoder.SampleRate))
+	if err != nil {
+		return nil, audioFormatError(input.FilePath, fmt.Errorf("failed to read bext chunk: %w", err))
+	}
+
+	data := map[string]interface{}{
+		"bit_depth": output.BitDepth,
+		"encoding":  output.Encoding,
+	}
+	if len(output.Info) > 0 {
+		data["info"] = output.Info
+	}
+	if len(output.CuePoints) > 0 {
+		data["cue_points"] = output.CuePoints
+	}
+	if output.Broadcast != nil {
+		data["broadcast"] = output.Broadcast
+	}
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "metadata", data, nil); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// readFormatMetadata reads the bit depth and encoding of an AIFF, FLAC or Ogg file.
+// Ogg files are lossy and only report their codec, which saves decoding Opus.
+func readFormatMetadata(ctx context.Context, r io.ReadSeeker, container string) (*ReadMetadataOutput, error) {
+	if container == containerOgg {
+		codec, err := detectOggCodec(r)
+		if err != nil {
+			return nil, err
+		}
+		return &ReadMetadataOutput{Encoding: codec}, nil
+	}
+
+	decoder, err := newPCMDecoder(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadMetadataOutput{
+		BitDepth: decoder.BitDepth(),
+		Encoding: decoder.Encoding(),
+	}, nil
+}
+
+// infoFields returns the non-empty LIST/INFO fields keyed by lowercase name
+func infoFields(md *wav.Metadata) map[string]string {
+	fields := map[string]string{
+		"artist":        md.Artist,
+		"title":         md.Title,
+		"comments":      md.Comments,
+		"copyright":     md.Copyright,
+		"creation_date": md.CreationDate,
+		"engineer":      md.Engineer,
+		"technician":    md.Technician,
+		"genre":         md.Genre,
+		"keywords":      md.Keywords,
+		"medium":        md.Medium,
+		"product":       md.Product,
+		"subject":       md.Subject,
+		"software":      md.Software,
+		"source":        md.Source,
+		"location":      md.Location,
+		"track_number":  md.TrackNbr,
+	}
+	for name, value := range fields {
+		if value == "" {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// readBroadcastExtension walks the RIFF chunks of a WAV file and decodes the
+// bext chunk, returning nil if the file has none
+func readBroadcastExtension(r io.Reader, sampleRate int) (*BroadcastExtension, error) {
+	// Skip the RIFF header: "RIFF", size, "WAVE"
+	if _, err := io.CopyN(io.Discard, r, 12); err != nil {
+		return nil, err
+	}
+
+	var header struct {
+		ID   [4]byte
+		Size uint32
+	}
+	for {
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		// Chunks are padded to an even size
+		size := int64(header.Size) + int64(header.Size&1)
+		if header.ID != bextChunkID {
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				// Ran off the end of a truncated chunk before finding a bext chunk
+				return nil, nil
+			}
+			continue
+		}
+		if header.Size < bextFixedSize {
+			return nil, fmt.Errorf("%w: bext chunk too short: %d bytes", ErrInvalidAudio, header.Size)
+		}
+
+		buf := make([]byte, bextFixedSize)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		bext := &BroadcastExtension{
+			Description:         bextString(buf[0:256]),
+			Originator:          bextString(buf[256:288]),
+			OriginatorReference: bextString(buf[288:320]),
+			OriginationDate:     bextString(buf[320:330]),
+			OriginationTime:     bextString(buf[330:338]),
+			TimeReference:       binary.LittleEndian.Uint64(buf[338:346]),
+		}
+		bext.Timecode = formatTimecode(bext.TimeReference, sampleRate)
+		return bext, nil
+	}
+}
+
+// bextString decodes a fixed-size, NUL-padded ASCII field
+func bextString(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		field = field[:i]
+	}
+	return string(bytes.TrimSpace(field))
+}
+
+// formatTimecode converts a sample offset from midnight to hh:mm:ss.mmm
+func formatTimecode(samples uint64, sampleRate int) string {
+	if sampleRate <= 0 {
+		return ""
+	}
+	ms := samples * 1000 / uint64(sampleRate) // #nosec G115 -- sampleRate is positive
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,147 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-audio/audio"
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Codecs of the Ogg streams activities can decode. Both are lossy, so their
+// samples are decoded straight to 16-bit and have no source bit depth.
+const (
+	codecVorbis = "vorbis"
+	codecOpus   = "opus"
+)
+
+// opusdecCommand is the opus-tools decoder Opus streams are decoded with, as
+// there is no cgo-free Opus decoder. It must be on the worker's PATH.
+const opusdecCommand = "opusdec"
+
+// errNoOpusDecoder is returned for Opus files when opusdec isn't installed.
+// It is a problem with the worker rather than the file, so it stays retryable.
+var errNoOpusDecoder = errors.New("decoding Opus requires " + opusdecCommand + " from opus-tools")
+
+// oggPageHeaderSize is the size of an Ogg page header before its segment table
+const oggPageHeaderSize = 27
+
+// detectOggCodec reads the first packet of the Ogg stream at the start of r to
+// tell Vorbis from Opus, and seeks back to the start
+func detectOggCodec(r io.ReadSeeker) (string, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	header := make([]byte, oggPageHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+	// The first packet starts right after the segment table, and its first
+	// bytes identify the codec
+	segments := int(header[oggPageHeaderSize-1])
+	if _, err := r.Seek(int64(segments), io.SeekCurrent); err != nil {
+		return "", fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+	packet := make([]byte, 8)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return "", fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		return codecVorbis, nil
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		return codecOpus, nil
+	}
+	return "", fmt.Errorf("%w: Ogg codec, only Vorbis and Opus are supported", ErrUnsupportedFormat)
+}
+
+// decodeOpus decodes the Opus stream in r to a 16-bit WAV held in memory.
+// opusdec only writes a WAV header when its output file is named .wav, so it
+// writes to a temporary file that is read back and removed.
+func decodeOpus(ctx context.Context, r io.Reader) ([]byte, error) {
+	path, err := exec.LookPath(opusdecCommand)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errNoOpusDecoder, err)
+	}
+	dir, err := os.MkdirTemp("", "opusdec-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "decoded.wav")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "--quiet", "-", output) // #nosec G204 -- fixed command resolved from PATH
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", opusdecCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(output) // #nosec G304 -- path inside our own temporary directory
+}
+
+// vorbisDecoder adapts an Ogg Vorbis stream to integer sample reads. The
+// reader returns float samples in [-1, 1], which are scaled to 16-bit the same
+// way as float WAV samples.
+type vorbisDecoder struct {
+	reader  *oggvorbis.Reader
+	scratch []float32
+}
+
+func newVorbisDecoder(r io.ReadSeeker) (*vorbisDecoder, error) {
+	reader, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a valid Ogg Vorbis file: %w", ErrInvalidAudio, err)
+	}
+	return &vorbisDecoder{reader: reader}, nil
+}
+
+// read decodes up to len(samples) samples into samples, scaled to 16-bit. It
+// returns 0 once the stream is exhausted.
+func (v *vorbisDecoder) read(samples []int) (int, error) {
+	if cap(v.scratch) < len(samples) {
+		v.scratch = make([]float32, len(samples))
+	}
+	floats := v.scratch[:len(samples)]
+	n, err := v.reader.Read(floats)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	for i, value := range floats[:n] {
+		samples[i] = int(math.Round(float64(value) * 32767))
+	}
+	return n, nil
+}
+
+// readAll decodes every remaining sample, scaled to 16-bit
+func (v *vorbisDecoder) readAll() ([]int, error) {
+	channels := v.reader.Channels()
+	samples := make([]int, 0, int(v.reader.Length())*channels)
+	chunk := make([]int, streamingChunkFrames*channels)
+	for {
+		n, err := v.read(chunk)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return samples, nil
+		}
+		samples = append(samples, chunk[:n]...)
+	}
+}
+
+func (v *vorbisDecoder) format() *audio.Format {
+	return &audio.Format{NumChannels: v.reader.Channels(), SampleRate: v.reader.SampleRate()}
+}
@@ -0,0 +1,100 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/pphelan007/davidAI/internal/storage"
+)
+
+// Overwrite policies for output files that already exist
+const (
+	OverwriteUnique  = "unique"    // write to the first free name with a numeric suffix (default)
+	OverwriteReplace = "overwrite" // replace the existing file
+	OverwriteError   = "error"     // fail the activity
+)
+
+// ErrTypeOutputExists is the application error type activities use when an
+// output file exists and the overwrite policy is OverwriteError
+const ErrTypeOutputExists = "OutputExists"
+
+// maxUniqueSuffix bounds the names OverwriteUnique tries before giving up
+const maxUniqueSuffix = 1000
+
+// createOutput creates an output file according to the overwrite policy and
+// returns it with the path actually used, which differs from path when
+// OverwriteUnique had to pick a new name. Existing files are never truncated
+// unless the policy is OverwriteReplace, since another process may be reading
+// them. The returned error is not to be wrapped: Temporal only sees the
+// non-retryable OverwriteError failure at the top of the chain.
+func (ac *ActivitiesClient) createOutput(ctx context.Context, path string) (storage.File, string, error) {
+	switch ac.overwritePolicy {
+	case OverwriteReplace:
+		file, err := ac.storage.Create(ctx, path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create output file %s: %w", path, err)
+		}
+		return file, path, nil
+	case OverwriteError:
+		file, err := ac.storage.CreateNew(ctx, path)
+		if errors.Is(err, fs.ErrExist) {
+			// Retrying won't remove the file
+			return nil, "", temporal.NewNonRetryableApplicationError("output file already exists: "+path, ErrTypeOutputExists, err)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create output file %s: %w", path, err)
+		}
+		return file, path, nil
+	default:
+		for i := 0; i < maxUniqueSuffix; i++ {
+			candidate := uniquePath(path, i)
+			file, err := ac.storage.CreateNew(ctx, candidate)
+			if errors.Is(err, fs.ErrExist) {
+				continue
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to create output file %s: %w", candidate, err)
+			}
+			return file, candidate, nil
+		}
+		return nil, "", fmt.Errorf("no free file name for %s after %d attempts", path, maxUniqueSuffix)
+	}
+}
+
+// uniquePath returns path with a numeric suffix before its extension, e.g.
+// trimmed_x.wav becomes trimmed_x_2.wav. Attempt 0 is path itself.
+func uniquePath(path string, attempt int) string {
+	if attempt == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), attempt, ext)
+}
+
+// closeOutput closes a file from createOutput, keeping the close error if
+// nothing failed before it, and removes the file if *err is set by then. Close
+// persists the file (e.g. uploads it to S3), so its error matters. A file that
+// failed before it was closed is aborted instead where the backend can (see
+// storage.Aborter), so a partial S3 upload is dropped before it is sent rather
+// than uploaded and then deleted. A file that turned out to exist already
+// belongs to someone else and is left alone.
+func (ac *ActivitiesClient) closeOutput(ctx context.Context, file storage.File, path string, err *error) {
+	if aborter, ok := file.(storage.Aborter); ok && *err != nil {
+		if abortErr := aborter.Abort(); abortErr != nil {
+			*err = fmt.Errorf("%w (discarding output file failed: %v)", *err, abortErr)
+		}
+		return
+	}
+	if closeErr := file.Close(); closeErr != nil && *err == nil {
+		*err = fmt.Errorf("failed to close output file %s: %w", path, closeErr)
+	}
+	if *err != nil && !errors.Is(*err, fs.ErrExist) {
+		ac.storage.Remove(ctx, path)
+	}
+}
@@ -0,0 +1,77 @@
+package activities
+
+import "math"
+
+// Oversampling used for true-peak detection, following ITU-R BS.1770
+const (
+	defaultOversamplingFactor = 4
+	oversamplingTapsPerPhase  = 12 // FIR taps applied per output sample
+)
+
+// minPeakDB is the floor reported for digital silence instead of -Inf, which
+// can't be encoded as JSON
+const minPeakDB = -120.0
+
+// polyphaseFilter returns a Hann-windowed sinc low-pass filter for upsampling by
+// factor, laid out so that taps phase, phase+factor, phase+2*factor, ... form
+// the sub-filter for each output phase. The gain is scaled by factor to make up
+// for the zeros that upsampling inserts.
+func polyphaseFilter(factor int) []float64 {
+	n := factor * oversamplingTapsPerPhase
+	center := float64(n-1) / 2
+	cutoff := 0.5 / float64(factor) // Nyquist of the original rate, in cycles per oversampled sample
+
+	taps := make([]float64, n)
+	for i := range taps {
+		t := float64(i) - center
+		sinc := 1.0
+		if t != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*t) / (2 * math.Pi * cutoff * t)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		taps[i] = 2 * cutoff * sinc * window * float64(factor)
+	}
+	return taps
+}
+
+// samplePeaks returns the largest absolute sample value of each channel and the
+// largest absolute value of the signal interpolated by factor, both scaled to
+// -1..1 from the 16-bit range
+func samplePeaks(samples []int, channels, factor int) (samplePeak, truePeak float64) {
+	if channels <= 0 {
+		channels = 1
+	}
+	taps := polyphaseFilter(factor)
+	history := make([]float64, oversamplingTapsPerPhase)
+
+	for ch := 0; ch < channels; ch++ {
+		for i := range history {
+			history[i] = 0
+		}
+		for i := ch; i < len(samples); i += channels {
+			x := float64(samples[i]) / 32768.0
+			samplePeak = math.Max(samplePeak, math.Abs(x))
+
+			// history[k] holds the input k samples ago
+			copy(history[1:], history[:len(history)-1])
+			history[0] = x
+			for phase := 0; phase < factor; phase++ {
+				y := 0.0
+				for k, h := range history {
+					y += taps[phase+k*factor] * h
+				}
+				truePeak = math.Max(truePeak, math.Abs(y))
+			}
+		}
+	}
+	// Interpolation can only add peaks, never hide a sample peak
+	return samplePeak, math.Max(truePeak, samplePeak)
+}
+
+// peakDB converts a linear peak to decibels relative to full scale
+func peakDB(peak float64) float64 {
+	if peak <= 0 {
+		return minPeakDB
+	}
+	return math.Max(20*math.Log10(peak), minPeakDB)
+}
@@ -1,13 +1,33 @@
 // Package activities contains Temporal activity definitions and client.
 package activities
 
-import "go.temporal.io/sdk/worker"
+import (
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/worker"
+)
 
-// RegisterActivities registers all activities with the given Temporal worker
+// RegisterActivities registers all activities with the given Temporal worker.
+// Feature extraction activities come from the feature registry (see Feature).
 func RegisterActivities(w worker.Worker, activitiesClient *ActivitiesClient) {
 	// Register audio processing activities
 	// Temporal will use the method names as activity names
+	w.RegisterActivity(activitiesClient.ValidateAudio)
+	w.RegisterActivity(activitiesClient.FindExistingAsset)
 	w.RegisterActivity(activitiesClient.IngestRawAudio)
 	w.RegisterActivity(activitiesClient.TrimSilence)
-	w.RegisterActivity(activitiesClient.ComputeSNR)
+	w.RegisterActivity(activitiesClient.DiscardTrimmedOutput)
+	w.RegisterActivity(activitiesClient.TrimToRange)
+	w.RegisterActivity(activitiesClient.TrimByRanges)
+	w.RegisterActivity(activitiesClient.ExtractChannel)
+	w.RegisterActivity(activitiesClient.ApplyGain)
+	w.RegisterActivity(activitiesClient.ApplyFilter)
+	w.RegisterActivity(activitiesClient.NormalizeAudio)
+	w.RegisterActivity(activitiesClient.Resample)
+	w.RegisterActivity(activitiesClient.ConcatenateAudio)
+	w.RegisterActivity(activitiesClient.DetectSegments)
+	w.RegisterActivity(activitiesClient.SplitOnSilence)
+
+	for _, f := range Features() {
+		w.RegisterActivityWithOptions(f.Handler(activitiesClient), activity.RegisterOptions{Name: f.Activity})
+	}
 }
@@ -0,0 +1,70 @@
+package activities
+
+import "math"
+
+// maxResampleRate is the highest target sample rate Resample accepts
+const maxResampleRate = 384000
+
+// resampleZeroCrossings is the number of sinc zero crossings on each side of
+// an output sample that the interpolation kernel spans
+const resampleZeroCrossings = 16
+
+// resampleSamples converts interleaved samples from one sample rate to another
+// with Hann-windowed sinc interpolation. When downsampling, the kernel is
+// widened so its cutoff falls at the new Nyquist frequency, which keeps content
+// above it from aliasing. Frames past either end of the input count as
+// silence. Results are clamped to the 16-bit range; the number of samples
+// clamped is returned with the new samples.
+func resampleSamples(samples []int, channels, fromRate, toRate int) (resampled []int, clipped int) {
+	frames := len(samples) / channels
+	outFrames := int(int64(frames) * int64(toRate) / int64(fromRate))
+	resampled = make([]int, outFrames*channels)
+
+	step := float64(fromRate) / float64(toRate)
+	cutoff := math.Min(1, float64(toRate)/float64(fromRate)) // fraction of the input Nyquist frequency kept
+	halfWidth := resampleZeroCrossings / cutoff              // kernel half-width in input frames
+
+	weights := make([]float64, 0, 2*int(math.Ceil(halfWidth))+1)
+	values := make([]float64, channels)
+	for j := 0; j < outFrames; j++ {
+		// Position of the output frame in the input, in input frames
+		t := float64(j) * step
+		first := max(int(math.Ceil(t-halfWidth)), 0)
+		last := min(int(math.Floor(t+halfWidth)), frames-1)
+
+		weights = weights[:0]
+		for k := first; k <= last; k++ {
+			x := t - float64(k)
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*cutoff*x) / (math.Pi * cutoff * x)
+			}
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/halfWidth)
+			weights = append(weights, cutoff*sinc*window)
+		}
+
+		for ch := range values {
+			values[ch] = 0
+		}
+		for i, w := range weights {
+			frame := samples[(first+i)*channels : (first+i+1)*channels]
+			for ch, sample := range frame {
+				values[ch] += w * float64(sample)
+			}
+		}
+
+		for ch, value := range values {
+			value = math.Round(value)
+			switch {
+			case value > math.MaxInt16:
+				value = math.MaxInt16
+				clipped++
+			case value < math.MinInt16:
+				value = math.MinInt16
+				clipped++
+			}
+			resampled[j*channels+ch] = int(value)
+		}
+	}
+	return resampled, clipped
+}
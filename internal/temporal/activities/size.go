@@ -0,0 +1,47 @@
+package activities
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/pphelan007/davidAI/internal/storage"
+)
+
+// ErrFileTooLarge is the cause of the error activities return for audio files
+// over the configured maximum size
+var ErrFileTooLarge = errors.New("audio file exceeds the maximum file size")
+
+// ErrTypeFileTooLarge is the application error type activities use for
+// ErrFileTooLarge, so workflows can recognize it once it has been serialized
+const ErrTypeFileTooLarge = "FileTooLarge"
+
+// IsFileTooLarge reports whether err, returned directly by an activity or
+// received from one by a workflow, is ErrFileTooLarge
+func IsFileTooLarge(err error) bool {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == ErrTypeFileTooLarge {
+		return true
+	}
+	return errors.Is(err, ErrFileTooLarge)
+}
+
+// checkFileSize returns the size of an opened file, rewound to the start, or a
+// non-retryable FileTooLarge error if it is over the maximum file size.
+// Activities that decode every sample into memory check this first, so a huge
+// file fails the activity instead of running the worker out of memory. A
+// maximum of zero disables the check.
+func (ac *ActivitiesClient) checkFileSize(file io.Seeker, path string) (int64, error) {
+	size, err := storage.Size(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get audio file size: %w", err)
+	}
+	if ac.maxFileSize > 0 && size > ac.maxFileSize {
+		return 0, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("audio file %s is %d bytes, over the maximum of %d", path, size, ac.maxFileSize),
+			ErrTypeFileTooLarge, ErrFileTooLarge)
+	}
+	return size, nil
+}
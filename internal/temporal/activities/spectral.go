@@ -0,0 +1,165 @@
+package activities
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Default STFT framing for spectral features
+const (
+	defaultFrameSize = 2048 // samples per frame, must be a power of two
+	defaultHopSize   = 512  // samples between frame starts
+)
+
+// Default MFCC parameters
+const (
+	defaultNumMels = 40 // mel filterbank bands
+	defaultNumMFCC = 13 // cepstral coefficients kept per frame
+)
+
+// minFramePower is the mean power below which a frame is treated as silent and
+// skipped, since spectral shape is meaningless for digital silence
+const minFramePower = 1e-10
+
+// powerSpectra splits mono samples into Hann-windowed frames and returns the
+// power spectrum (bins 0..frameSize/2) of each frame. Samples are scaled to
+// -1..1 from the 16-bit range. The final partial frame is zero-padded.
+func powerSpectra(mono []float64, frameSize, hopSize int) [][]float64 {
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+
+	var spectra [][]float64
+	frame := make([]complex128, frameSize)
+	for start := 0; start < len(mono); start += hopSize {
+		for i := range frame {
+			value := 0.0
+			if start+i < len(mono) {
+				value = mono[start+i] / 32768.0 * window[i]
+			}
+			frame[i] = complex(value, 0)
+		}
+		fft(frame)
+
+		power := make([]float64, frameSize/2+1)
+		for bin := range power {
+			magnitude := cmplx.Abs(frame[bin])
+			power[bin] = magnitude * magnitude
+		}
+		spectra = append(spectra, power)
+	}
+	return spectra
+}
+
+// mixToMono averages interleaved channels into a single channel
+func mixToMono(samples []int, channels int) []float64 {
+	mono := make([]float64, len(samples)/channels)
+	for frame := range mono {
+		sum := 0
+		for ch := 0; ch < channels; ch++ {
+			sum += samples[frame*channels+ch]
+		}
+		mono[frame] = float64(sum) / float64(channels)
+	}
+	return mono
+}
+
+// fft computes an in-place iterative radix-2 FFT. len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+
+	// Bit-reversal permutation
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := x[start+k]
+				odd := w * x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// isPowerOfTwo reports whether n is a positive power of two
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// melFilterbank returns numMels triangular filters over the power spectrum bins
+// (frameSize/2+1 of them), spaced evenly on the HTK mel scale from 0 Hz to the
+// Nyquist frequency
+func melFilterbank(numMels, frameSize, sampleRate int) [][]float64 {
+	hzToMel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	melToHz := func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+
+	numBins := frameSize/2 + 1
+	maxMel := hzToMel(float64(sampleRate) / 2)
+
+	// Filter edges as fractional FFT bins: numMels+2 points define numMels triangles
+	edges := make([]float64, numMels+2)
+	for i := range edges {
+		hz := melToHz(maxMel * float64(i) / float64(numMels+1))
+		edges[i] = hz * float64(frameSize) / float64(sampleRate)
+	}
+
+	filters := make([][]float64, numMels)
+	for m := range filters {
+		left, center, right := edges[m], edges[m+1], edges[m+2]
+		filter := make([]float64, numBins)
+		for bin := range filter {
+			f := float64(bin)
+			switch {
+			case f > left && f <= center:
+				filter[bin] = (f - left) / (center - left)
+			case f > center && f < right:
+				filter[bin] = (right - f) / (right - center)
+			}
+		}
+		filters[m] = filter
+	}
+	return filters
+}
+
+// mfcc computes the cepstral coefficients of one power spectrum: mel band
+// energies, their log, then an orthonormal DCT-II keeping numMFCC coefficients
+func mfcc(power []float64, filters [][]float64, numMFCC int) []float64 {
+	logEnergies := make([]float64, len(filters))
+	for m, filter := range filters {
+		energy := 0.0
+		for bin, weight := range filter {
+			energy += weight * power[bin]
+		}
+		logEnergies[m] = math.Log(energy + minFramePower)
+	}
+
+	n := float64(len(logEnergies))
+	coefficients := make([]float64, numMFCC)
+	for k := range coefficients {
+		sum := 0.0
+		for m, e := range logEnergies {
+			sum += e * math.Cos(math.Pi*float64(k)*(float64(m)+0.5)/n)
+		}
+		scale := math.Sqrt(2 / n)
+		if k == 0 {
+			scale = math.Sqrt(1 / n)
+		}
+		coefficients[k] = sum * scale
+	}
+	return coefficients
+}
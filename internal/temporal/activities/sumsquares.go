@@ -0,0 +1,63 @@
+package activities
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelSumMinSamples is the smallest slice sumSquares splits across
+// goroutines; below it, starting them costs more than the loop they share
+const parallelSumMinSamples = 1 << 16
+
+// sumSquaresBlock is the number of samples whose squares are summed exactly as
+// an integer before being added to a float64 total. 2^23 squares of 16-bit
+// samples are at most 2^53, so the block sum converts to float64 exactly.
+const sumSquaresBlock = 1 << 23
+
+// sumSquares returns the sum of the squares of 16-bit samples, computed as an
+// exact integer. Slices of at least parallelSumMinSamples are partitioned
+// across runtime.NumCPU() goroutines and the partial sums added afterwards;
+// integer addition doesn't depend on order, so the result is the same for any
+// number of CPUs. The slice must be shorter than sumSquaresBlock for the sum
+// to fit a float64 exactly.
+func sumSquares(samples []int) int64 {
+	workers := min(runtime.NumCPU(), len(samples)/(parallelSumMinSamples/2))
+	if len(samples) < parallelSumMinSamples || workers < 2 {
+		return sumSquaresSerial(samples)
+	}
+
+	partial := make([]int64, workers)
+	var wg sync.WaitGroup
+	for w := range partial {
+		part := samples[w*len(samples)/workers : (w+1)*len(samples)/workers]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			partial[w] = sumSquaresSerial(part)
+		}()
+	}
+	wg.Wait()
+
+	var sum int64
+	for _, p := range partial {
+		sum += p
+	}
+	return sum
+}
+
+// sumSquaresSerial is sumSquares on the calling goroutine. Four independent
+// sums let the multiplies overlap, which a single running sum serializes.
+func sumSquaresSerial(samples []int) int64 {
+	var s0, s1, s2, s3 int64
+	i := 0
+	for ; i+4 <= len(samples); i += 4 {
+		s0 += int64(samples[i]) * int64(samples[i])
+		s1 += int64(samples[i+1]) * int64(samples[i+1])
+		s2 += int64(samples[i+2]) * int64(samples[i+2])
+		s3 += int64(samples[i+3]) * int64(samples[i+3])
+	}
+	for ; i < len(samples); i++ {
+		s0 += int64(samples[i]) * int64(samples[i])
+	}
+	return s0 + s1 + s2 + s3
+}
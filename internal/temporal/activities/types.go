@@ -9,38 +9,331 @@ type AssetInfo struct {
 	Metadata    AudioMetadata `json:"metadata"`
 }
 
+// DBStatus is embedded in the output of every activity that reads or writes
+// the database. Under DBFailureContinue a failed database call doesn't fail
+// the activity, which still returns its audio results, but it is flagged here
+// so callers know the asset or feature may be missing from the database.
+type DBStatus struct {
+	DBFailed bool `json:"db_failed,omitempty"` // true if a database call failed and was skipped
+}
+
 // AudioMetadata contains basic audio file metadata
 type AudioMetadata struct {
-	SampleRate int     `json:"sample_rate"` // samples per second
-	Duration   float64 `json:"duration"`    // duration in seconds
-	Channels   int     `json:"channels"`    // number of audio channels
+	SampleRate int       `json:"sample_rate"`         // samples per second
+	Duration   float64   `json:"duration"`            // duration in seconds
+	Channels   int       `json:"channels"`            // number of audio channels
+	DCOffset   []float64 `json:"dc_offset,omitempty"` // mean sample value of each channel (-1.0 to 1.0); far from 0 on DC-coupled hardware faults
+
+	// Timing from the BWF bext chunk, for syncing recordings made together.
+	// All empty for files without one.
+	OriginationDate string  `json:"origination_date,omitempty"` // yyyy-mm-dd
+	OriginationTime string  `json:"origination_time,omitempty"` // hh:mm:ss
+	TimeReference   *uint64 `json:"time_reference,omitempty"`   // samples since midnight of the first sample
+	StartTime       string  `json:"start_time,omitempty"`       // TimeReference as hh:mm:ss.mmm
+	EndTime         string  `json:"end_time,omitempty"`         // time of the end of the last sample as hh:mm:ss.mmm
 }
 
 // IngestRawAudioInput is the input for the IngestRawAudio activity
 type IngestRawAudioInput struct {
-	FilePath string `json:"file_path"`
+	FilePath  string `json:"file_path"`
+	DatasetID string `json:"dataset_id,omitempty"` // dataset the new asset belongs to
 }
 
 // IngestRawAudioOutput is the output from the IngestRawAudio activity
 type IngestRawAudioOutput struct {
 	Asset AssetInfo `json:"asset"`
+
+	DBStatus
+}
+
+// FindExistingAssetInput is the input for the FindExistingAsset activity
+type FindExistingAssetInput struct {
+	FilePath  string `json:"file_path"`
+	DatasetID string `json:"dataset_id,omitempty"` // only assets in this dataset count as existing
 }
 
+// FindExistingAssetOutput is the output from the FindExistingAsset activity
+type FindExistingAssetOutput struct {
+	ContentHash   string     `json:"content_hash"`
+	ExistingAsset *AssetInfo `json:"existing_asset,omitempty"` // nil if no asset with this hash was ingested before
+
+	DBStatus
+}
+
+// Silence threshold modes for TrimSilenceInput.ThresholdMode
+const (
+	ThresholdModeAbsolute     = "absolute"      // threshold is a fraction of full scale
+	ThresholdModeRelativePeak = "relative_peak" // threshold is a fraction of the file's peak amplitude
+	ThresholdModeNoiseFloor   = "noise_floor"   // threshold is set a margin above the file's measured noise floor
+)
+
+// Formats of TrimSilence's trimmed file, for TrimSilenceInput.OutputFormat
+const (
+	OutputFormatWAV  = "wav"  // 16-bit PCM WAV (default)
+	OutputFormatFLAC = "flac" // 16-bit FLAC, lossless at roughly half the size
+)
+
 // TrimSilenceInput is the input for the TrimSilence activity
 type TrimSilenceInput struct {
 	AssetID            string  `json:"asset_id"`
 	SourcePath         string  `json:"source_path"`
-	SilenceThreshold   float64 `json:"silence_threshold"`    // threshold for silence detection (0.0-1.0)
-	MinSilenceDuration float64 `json:"min_silence_duration"` // minimum silence duration in seconds to trim
+	SilenceThreshold   float64 `json:"silence_threshold"`       // threshold for silence detection (0.0-1.0)
+	ThresholdMode      string  `json:"threshold_mode"`          // "absolute" (default), "relative_peak" or "noise_floor"
+	NoiseFloorMarginDB float64 `json:"noise_floor_margin_db"`   // in noise_floor mode, how far above the noise floor the threshold sits, defaults to 6 dB
+	MinSilenceDuration float64 `json:"min_silence_duration"`    // minimum silence duration in seconds to trim
+	OutputDir          string  `json:"output_dir,omitempty"`    // directory for the trimmed file, defaults to the source directory
+	FadeInMs           int     `json:"fade_in_ms,omitempty"`    // linear fade-in length applied to the trimmed output, 0 disables
+	FadeOutMs          int     `json:"fade_out_ms,omitempty"`   // linear fade-out length applied to the trimmed output, 0 disables
+	DryRun             bool    `json:"dry_run,omitempty"`       // if true, only report what would be trimmed without writing a file or asset
+	CopyOnNoOp         bool    `json:"copy_on_no_op,omitempty"` // if nothing needs trimming, copy the original to the output directory anyway
+	OutputFormat       string  `json:"output_format,omitempty"` // "wav" (default) or "flac"; the content hash is of the encoded file
 }
 
 // TrimSilenceOutput is the output from the TrimSilence activity
 type TrimSilenceOutput struct {
-	NewAssetID  string `json:"new_asset_id,omitempty"` // empty if no new asset was created
-	ContentHash string `json:"content_hash"`
-	WasTrimmed  bool   `json:"was_trimmed"`           // true if silence was actually trimmed
-	NoOp        bool   `json:"no_op"`                 // true if trimmed audio is identical to original
-	OutputPath  string `json:"output_path,omitempty"` // path to trimmed audio file if created
+	NewAssetID             string  `json:"new_asset_id,omitempty"` // empty if no new asset was created
+	ContentHash            string  `json:"content_hash"`
+	WasTrimmed             bool    `json:"was_trimmed"`              // true if silence was actually trimmed
+	NoOp                   bool    `json:"no_op"`                    // true if trimmed audio is identical to original
+	OutputPath             string  `json:"output_path,omitempty"`    // path to trimmed audio file if created, or the copy made by CopyOnNoOp
+	DryRun                 bool    `json:"dry_run,omitempty"`        // true if nothing was written; WasTrimmed reports whether it would have been
+	LeadingSamplesRemoved  int     `json:"leading_samples_removed"`  // frames of leading silence removed
+	TrailingSamplesRemoved int     `json:"trailing_samples_removed"` // frames of trailing silence removed
+	TrimmedDuration        float64 `json:"trimmed_duration"`         // duration in seconds after trimming
+	AllSilent              bool    `json:"all_silent,omitempty"`     // true if no audio is above the threshold; nothing is trimmed or written
+	SilenceThreshold       float64 `json:"silence_threshold"`        // threshold used, as a fraction of full scale, after applying the threshold mode
+	NoiseFloorDB           float64 `json:"noise_floor_db,omitempty"` // measured noise floor in dBFS, only set in noise_floor mode
+
+	DBStatus
+}
+
+// DiscardTrimmedOutputInput is the input for the DiscardTrimmedOutput activity
+type DiscardTrimmedOutputInput struct {
+	AssetID  string `json:"asset_id,omitempty"` // asset created for the trimmed file, empty if none
+	FilePath string `json:"file_path"`          // path to the trimmed file
+}
+
+// DetectSegmentsInput is the input for the DetectSegments activity
+type DetectSegmentsInput struct {
+	AssetID            string  `json:"asset_id"`
+	SourcePath         string  `json:"source_path"`
+	SilenceThreshold   float64 `json:"silence_threshold"`    // threshold for silence detection (0.0-1.0)
+	MinSilenceDuration float64 `json:"min_silence_duration"` // minimum gap in seconds that splits two segments
+	MinSegmentDuration float64 `json:"min_segment_duration"` // segments shorter than this many seconds are dropped
+	SplitFiles         bool    `json:"split_files"`          // if true, write each segment to its own WAV file and asset
+	OutputDir          string  `json:"output_dir,omitempty"` // directory for segment files, defaults to the source directory
+}
+
+// AudioSegment is a non-silent range of audio
+type AudioSegment struct {
+	StartSample int     `json:"start_sample"`           // first frame of the segment
+	EndSample   int     `json:"end_sample"`             // frame just past the end of the segment
+	StartTime   float64 `json:"start_time"`             // start in seconds
+	EndTime     float64 `json:"end_time"`               // end in seconds
+	AssetID     string  `json:"asset_id,omitempty"`     // set when the segment was split into its own file
+	FilePath    string  `json:"file_path,omitempty"`    // set when the segment was split into its own file
+	ContentHash string  `json:"content_hash,omitempty"` // set when the segment was split into its own file
+}
+
+// DetectSegmentsOutput is the output from the DetectSegments activity
+type DetectSegmentsOutput struct {
+	Segments []AudioSegment `json:"segments"`
+
+	DBStatus
+}
+
+// SplitOnSilenceInput is the input for the SplitOnSilence activity
+type SplitOnSilenceInput struct {
+	AssetID            string  `json:"asset_id"`
+	SourcePath         string  `json:"source_path"`
+	SilenceThreshold   float64 `json:"silence_threshold"`    // threshold for silence detection (0.0-1.0)
+	MinSilenceDuration float64 `json:"min_silence_duration"` // minimum gap in seconds that splits two clips
+	MinSegmentDuration float64 `json:"min_segment_duration"` // clips shorter than this many seconds are skipped
+	OutputDir          string  `json:"output_dir,omitempty"` // directory for clip files, defaults to the source directory
+}
+
+// SplitOnSilenceOutput is the output from the SplitOnSilence activity
+type SplitOnSilenceOutput struct {
+	Clips []AudioSegment `json:"clips"` // one per written clip, each with its new asset ID and file path
+
+	DBStatus
+}
+
+// TrimToRangeInput is the input for the TrimToRange activity
+type TrimToRangeInput struct {
+	AssetID      string  `json:"asset_id"`
+	SourcePath   string  `json:"source_path"`
+	StartSeconds float64 `json:"start_seconds"`        // start of the kept range
+	EndSeconds   float64 `json:"end_seconds"`          // end of the kept range, at most the file duration
+	OutputDir    string  `json:"output_dir,omitempty"` // directory for the clipped file, defaults to the source directory
+}
+
+// TrimToRangeOutput is the output from the TrimToRange activity
+type TrimToRangeOutput struct {
+	NewAssetID  string  `json:"new_asset_id"`
+	ContentHash string  `json:"content_hash"`
+	OutputPath  string  `json:"output_path"`
+	StartSample int     `json:"start_sample"` // first frame kept
+	EndSample   int     `json:"end_sample"`   // frame after the last one kept
+	Duration    float64 `json:"duration"`     // duration in seconds of the clipped file
+
+	DBStatus
+}
+
+// KeepRange is a range of an audio file for TrimByRanges to keep, given in
+// seconds or, when EndSample is set, in frames
+type KeepRange struct {
+	StartSeconds float64 `json:"start_seconds,omitempty"` // start of the range
+	EndSeconds   float64 `json:"end_seconds,omitempty"`   // end of the range, at most the file duration
+	StartSample  int     `json:"start_sample,omitempty"`  // first frame kept
+	EndSample    int     `json:"end_sample,omitempty"`    // frame after the last one kept
+}
+
+// TrimByRangesInput is the input for the TrimByRanges activity
+type TrimByRangesInput struct {
+	AssetID     string      `json:"asset_id"`
+	SourcePath  string      `json:"source_path"`
+	Ranges      []KeepRange `json:"ranges"`                 // ranges to keep, in order and not overlapping
+	CrossfadeMs int         `json:"crossfade_ms,omitempty"` // linear crossfade at each join, 0 butts the ranges together
+	OutputDir   string      `json:"output_dir,omitempty"`   // directory for the output file, defaults to the source directory
+}
+
+// TrimByRangesOutput is the output from the TrimByRanges activity
+type TrimByRangesOutput struct {
+	NewAssetID  string      `json:"new_asset_id"`
+	ContentHash string      `json:"content_hash"`
+	OutputPath  string      `json:"output_path"`
+	Ranges      []KeepRange `json:"ranges"`   // the kept ranges with both their times and frames filled in
+	Duration    float64     `json:"duration"` // duration in seconds of the output, after crossfades
+
+	DBStatus
+}
+
+// ExtractChannelInput is the input for the ExtractChannel activity
+type ExtractChannelInput struct {
+	AssetID    string `json:"asset_id"`
+	SourcePath string `json:"source_path"`
+	Channel    int    `json:"channel"`              // index of the channel to extract, 0 for the first
+	OutputDir  string `json:"output_dir,omitempty"` // directory for the mono file, defaults to the source directory
+}
+
+// ExtractChannelOutput is the output from the ExtractChannel activity
+type ExtractChannelOutput struct {
+	NewAssetID  string  `json:"new_asset_id"`
+	ContentHash string  `json:"content_hash"`
+	OutputPath  string  `json:"output_path"`
+	Channel     int     `json:"channel"`
+	Channels    int     `json:"channels"` // channels of the source file
+	BitDepth    int     `json:"bit_depth"`
+	Duration    float64 `json:"duration"`
+
+	DBStatus
+}
+
+// ApplyGainInput is the input for the ApplyGain activity
+type ApplyGainInput struct {
+	AssetID    string  `json:"asset_id"`
+	SourcePath string  `json:"source_path"`
+	GainDB     float64 `json:"gain_db"`              // gain to apply in dB, negative to attenuate
+	OutputDir  string  `json:"output_dir,omitempty"` // directory for the output file, defaults to the source directory
+}
+
+// ApplyGainOutput is the output from the ApplyGain activity
+type ApplyGainOutput struct {
+	NewAssetID     string  `json:"new_asset_id"`
+	ContentHash    string  `json:"content_hash"`
+	OutputPath     string  `json:"output_path"`
+	GainDB         float64 `json:"gain_db"`
+	Clipped        bool    `json:"clipped"`         // true if any sample had to be clamped
+	ClippedSamples int     `json:"clipped_samples"` // number of samples clamped to the range of the output bit depth
+
+	DBStatus
+}
+
+// ApplyFilterInput is the input for the ApplyFilter activity
+type ApplyFilterInput struct {
+	AssetID    string  `json:"asset_id"`
+	SourcePath string  `json:"source_path"`
+	FilterType string  `json:"filter_type"`          // FilterHighPass or FilterLowPass
+	CutoffHz   float64 `json:"cutoff_hz"`            // -3 dB frequency, below the Nyquist frequency of the file
+	Order      int     `json:"order,omitempty"`      // Butterworth order from 1 to 8, defaults to 2 (12 dB per octave)
+	OutputDir  string  `json:"output_dir,omitempty"` // directory for the output file, defaults to the source directory
+}
+
+// ApplyFilterOutput is the output from the ApplyFilter activity
+type ApplyFilterOutput struct {
+	NewAssetID     string  `json:"new_asset_id"`
+	ContentHash    string  `json:"content_hash"`
+	OutputPath     string  `json:"output_path"`
+	FilterType     string  `json:"filter_type"`
+	CutoffHz       float64 `json:"cutoff_hz"`
+	Order          int     `json:"order"`
+	ClippedSamples int     `json:"clipped_samples"` // number of samples clamped to the range of the output bit depth
+
+	DBStatus
+}
+
+// DefaultTargetPeakDB is the peak level NormalizeAudio normalizes to when the
+// input doesn't set one, leaving headroom for inter-sample peaks
+const DefaultTargetPeakDB = -1.0
+
+// NormalizeAudioInput is the input for the NormalizeAudio activity
+type NormalizeAudioInput struct {
+	AssetID      string   `json:"asset_id"`
+	SourcePath   string   `json:"source_path"`
+	TargetPeakDB *float64 `json:"target_peak_db,omitempty"` // peak level in dBFS, at most 0, defaults to DefaultTargetPeakDB
+	OutputDir    string   `json:"output_dir,omitempty"`     // directory for the output file, defaults to the source directory
+}
+
+// NormalizeAudioOutput is the output from the NormalizeAudio activity
+type NormalizeAudioOutput struct {
+	NewAssetID   string  `json:"new_asset_id"`
+	ContentHash  string  `json:"content_hash"`
+	OutputPath   string  `json:"output_path"`
+	SourcePeakDB float64 `json:"source_peak_db"` // sample peak of the source in dBFS
+	TargetPeakDB float64 `json:"target_peak_db"`
+	GainDB       float64 `json:"gain_db"` // gain applied to reach the target
+
+	DBStatus
+}
+
+// ResampleInput is the input for the Resample activity
+type ResampleInput struct {
+	AssetID          string `json:"asset_id"`
+	SourcePath       string `json:"source_path"`
+	TargetSampleRate int    `json:"target_sample_rate"`   // sample rate of the output in Hz
+	OutputDir        string `json:"output_dir,omitempty"` // directory for the output file, defaults to the source directory
+}
+
+// ResampleOutput is the output from the Resample activity
+type ResampleOutput struct {
+	NewAssetID       string  `json:"new_asset_id,omitempty"` // empty if the source was already at the target rate
+	ContentHash      string  `json:"content_hash,omitempty"`
+	OutputPath       string  `json:"output_path,omitempty"`
+	SourceSampleRate int     `json:"source_sample_rate"`
+	TargetSampleRate int     `json:"target_sample_rate"`
+	Duration         float64 `json:"duration"`        // duration of the output in seconds
+	NoOp             bool    `json:"no_op,omitempty"` // true if the source was already at the target rate and nothing was written
+	ClippedSamples   int     `json:"clipped_samples"` // number of samples clamped to the range of the output bit depth
+
+	DBStatus
+}
+
+// ConcatenateAudioInput is the input for the ConcatenateAudio activity
+type ConcatenateAudioInput struct {
+	AssetIDs    []string `json:"asset_ids"`            // asset of each source, in the same order as SourcePaths
+	SourcePaths []string `json:"source_paths"`         // files to join, in playback order
+	OutputDir   string   `json:"output_dir,omitempty"` // directory for the combined file, defaults to the first source's directory
+}
+
+// ConcatenateAudioOutput is the output from the ConcatenateAudio activity
+type ConcatenateAudioOutput struct {
+	NewAssetID  string  `json:"new_asset_id"`
+	ContentHash string  `json:"content_hash"`
+	OutputPath  string  `json:"output_path"`
+	Duration    float64 `json:"duration"` // total duration in seconds
+
+	DBStatus
 }
 
 // ComputeSNRInput is the input for the ComputeSNR activity
@@ -49,13 +342,218 @@ type ComputeSNRInput struct {
 	FilePath          string  `json:"file_path"`           // path to the audio file
 	NoiseThreshold    float64 `json:"noise_threshold"`     // threshold for noise detection (0.0-1.0), default 0.01
 	UseSilentSegments bool    `json:"use_silent_segments"` // if true, estimate noise from silent segments; if false, use all samples below threshold
+	Streaming         bool    `json:"streaming"`           // if true, decode in chunks instead of loading all samples (always on for large files)
+	PerChannel        bool    `json:"per_channel"`         // if true, also compute SNR for each channel on its own
+	ForceRecompute    bool    `json:"force_recompute"`     // if true, skip the cached result for the same content and params
+	StartSeconds      float64 `json:"start_seconds"`       // if set, only analyze audio from this time on
+	EndSeconds        float64 `json:"end_seconds"`         // if set, only analyze audio before this time; 0 means the end of the file
 }
 
-// ComputeSNROutput is the output from the ComputeSNR activity
+// ComputeSNROutput is the output from the ComputeSNR activity. The top-level
+// values are computed over all channels' samples together.
 type ComputeSNROutput struct {
-	SNR         float64 `json:"snr"`          // Signal-to-Noise Ratio in dB
-	SignalPower float64 `json:"signal_power"` // signal power (RMS squared)
-	NoisePower  float64 `json:"noise_power"`  // noise power (RMS squared)
-	SignalRMS   float64 `json:"signal_rms"`   // Root Mean Square of signal
-	NoiseRMS    float64 `json:"noise_rms"`    // Root Mean Square of noise
+	SNR         float64   `json:"snr"`                   // Signal-to-Noise Ratio in dB
+	SignalPower float64   `json:"signal_power"`          // signal power (RMS squared)
+	NoisePower  float64   `json:"noise_power"`           // noise power (RMS squared)
+	SignalRMS   float64   `json:"signal_rms"`            // Root Mean Square of signal
+	NoiseRMS    float64   `json:"noise_rms"`             // Root Mean Square of noise
+	ChannelSNR  []float64 `json:"channel_snr,omitempty"` // SNR in dB of each channel, indexed by channel; only set with PerChannel
+
+	DBStatus
+}
+
+// ComputeSNRProfileInput is the input for the ComputeSNRProfile activity
+type ComputeSNRProfileInput struct {
+	AssetID           string  `json:"asset_id"`            // ID of the asset to compute the profile for
+	FilePath          string  `json:"file_path"`           // path to the audio file
+	NoiseThreshold    float64 `json:"noise_threshold"`     // threshold for noise detection (0.0-1.0), default 0.01
+	UseSilentSegments bool    `json:"use_silent_segments"` // as for ComputeSNR, applied within each window
+	WindowSeconds     float64 `json:"window_seconds"`      // window length in seconds, default 1.0
+	HopSeconds        float64 `json:"hop_seconds"`         // time between window starts in seconds, default 0.5
+}
+
+// SNRWindow is the SNR of one window of a ComputeSNRProfile
+type SNRWindow struct {
+	StartTime float64 `json:"start_time"` // seconds
+	EndTime   float64 `json:"end_time"`   // seconds
+	SNR       float64 `json:"snr"`        // dB
+}
+
+// ComputeSNRProfileOutput is the output from the ComputeSNRProfile activity
+type ComputeSNRProfileOutput struct {
+	Windows       []SNRWindow `json:"windows"`
+	MinSNR        float64     `json:"min_snr"`
+	MaxSNR        float64     `json:"max_snr"`
+	MeanSNR       float64     `json:"mean_snr"`
+	WindowSeconds float64     `json:"window_seconds"`
+	HopSeconds    float64     `json:"hop_seconds"`
+
+	DBStatus
+}
+
+// ComputeSpectralFlatnessInput is the input for the ComputeSpectralFlatness activity
+type ComputeSpectralFlatnessInput struct {
+	AssetID   string `json:"asset_id"`   // ID of the asset to compute flatness for
+	FilePath  string `json:"file_path"`  // path to the audio file
+	FrameSize int    `json:"frame_size"` // FFT frame size in samples (power of two), default 2048
+	HopSize   int    `json:"hop_size"`   // samples between frame starts, default 512
+}
+
+// ComputeSpectralFlatnessOutput is the output from the ComputeSpectralFlatness activity
+type ComputeSpectralFlatnessOutput struct {
+	MeanFlatness  float64   `json:"mean_flatness"`  // mean over non-silent frames (0 = tonal, 1 = noise)
+	FrameFlatness []float64 `json:"frame_flatness"` // flatness of each non-silent frame
+	FrameSize     int       `json:"frame_size"`
+	HopSize       int       `json:"hop_size"`
+
+	DBStatus
+}
+
+// ValidateAudioInput is the input for the ValidateAudio activity
+type ValidateAudioInput struct {
+	FilePath           string `json:"file_path"`                      // path to the audio file
+	ExpectedSampleRate int    `json:"expected_sample_rate,omitempty"` // if set, any other sample rate fails with FormatMismatch
+	ExpectedChannels   int    `json:"expected_channels,omitempty"`    // if set, any other channel count fails with FormatMismatch
+}
+
+// ValidateAudioOutput is the output from the ValidateAudio activity
+type ValidateAudioOutput struct {
+	SampleRate int     `json:"sample_rate"` // samples per second
+	Channels   int     `json:"channels"`    // number of audio channels
+	BitDepth   int     `json:"bit_depth"`   // 0 for lossy codecs
+	Encoding   string  `json:"encoding"`    // "pcm", "float", "flac", "vorbis" or "opus"
+	Duration   float64 `json:"duration"`    // duration in seconds, from the sound data size
+}
+
+// ReadMetadataInput is the input for the ReadMetadata activity
+type ReadMetadataInput struct {
+	AssetID  string `json:"asset_id"`  // ID of the asset to store the metadata for
+	FilePath string `json:"file_path"` // path to the audio file
+}
+
+// CuePoint is a marker from a WAV cue chunk
+type CuePoint struct {
+	ID       string `json:"id"`
+	Position uint32 `json:"position"` // sample offset of the cue point
+}
+
+// BroadcastExtension holds the fields of a BWF bext chunk
+type BroadcastExtension struct {
+	Description         string `json:"description,omitempty"`
+	Originator          string `json:"originator,omitempty"`
+	OriginatorReference string `json:"originator_reference,omitempty"`
+	OriginationDate     string `json:"origination_date,omitempty"` // yyyy-mm-dd
+	OriginationTime     string `json:"origination_time,omitempty"` // hh:mm:ss
+	TimeReference       uint64 `json:"time_reference"`             // samples since midnight of the first sample
+	Timecode            string `json:"timecode"`                   // TimeReference as hh:mm:ss.mmm
+}
+
+// ReadMetadataOutput is the output from the ReadMetadata activity
+type ReadMetadataOutput struct {
+	BitDepth  int                 `json:"bit_depth"`      // 0 for lossy codecs
+	Encoding  string              `json:"encoding"`       // "pcm", "float", "flac", "vorbis" or "opus"
+	Info      map[string]string   `json:"info,omitempty"` // LIST/INFO fields, e.g. "artist", "title", "comments"
+	CuePoints []CuePoint          `json:"cue_points,omitempty"`
+	Broadcast *BroadcastExtension `json:"broadcast,omitempty"` // set for BWF files
+
+	DBStatus
+}
+
+// ComputeTruePeakInput is the input for the ComputeTruePeak activity
+type ComputeTruePeakInput struct {
+	AssetID            string `json:"asset_id"`            // ID of the asset to compute the peak for
+	FilePath           string `json:"file_path"`           // path to the audio file
+	OversamplingFactor int    `json:"oversampling_factor"` // interpolation factor for the true peak, default 4
+}
+
+// ComputeTruePeakOutput is the output from the ComputeTruePeak activity
+type ComputeTruePeakOutput struct {
+	SamplePeak         float64 `json:"sample_peak"`      // largest absolute sample (0.0-1.0)
+	SamplePeakDBFS     float64 `json:"sample_peak_dbfs"` // sample peak in dBFS
+	TruePeak           float64 `json:"true_peak"`        // largest absolute interpolated value, may exceed 1.0
+	TruePeakDBTP       float64 `json:"true_peak_dbtp"`   // true peak in dBTP
+	OversamplingFactor int     `json:"oversampling_factor"`
+
+	DBStatus
+}
+
+// GenerateWaveformInput is the input for the GenerateWaveform activity
+type GenerateWaveformInput struct {
+	AssetID     string `json:"asset_id"`               // ID of the asset to store the waveform for
+	FilePath    string `json:"file_path"`              // path to the audio file
+	Buckets     int    `json:"buckets"`                // number of time buckets, default 1000, at most 10000
+	WritePNG    bool   `json:"write_png,omitempty"`    // if true, also render the waveform to a PNG one pixel wide per bucket
+	ImageHeight int    `json:"image_height,omitempty"` // PNG height in pixels, default 128
+	OutputDir   string `json:"output_dir,omitempty"`   // directory for the PNG, defaults to the source directory
+}
+
+// GenerateWaveformOutput is the output from the GenerateWaveform activity.
+// Each slice has one value per bucket, across all channels, scaled to -1..1.
+type GenerateWaveformOutput struct {
+	Buckets   int       `json:"buckets"`              // fewer than requested if the file has fewer frames
+	Duration  float64   `json:"duration"`             // duration in seconds covered by the buckets
+	Min       []float64 `json:"min"`                  // lowest sample in each bucket
+	Max       []float64 `json:"max"`                  // highest sample in each bucket
+	RMS       []float64 `json:"rms"`                  // RMS level of each bucket
+	ImagePath string    `json:"image_path,omitempty"` // path to the PNG, if one was written
+
+	DBStatus
+}
+
+// ComputeMFCCInput is the input for the ComputeMFCC activity
+type ComputeMFCCInput struct {
+	AssetID   string `json:"asset_id"`             // ID of the asset to compute MFCCs for
+	FilePath  string `json:"file_path"`            // path to the audio file
+	FrameSize int    `json:"frame_size"`           // FFT frame size in samples (power of two), default 2048
+	HopSize   int    `json:"hop_size"`             // samples between frame starts, default 512
+	NumMels   int    `json:"n_mels"`               // mel filterbank bands, default 40
+	NumMFCC   int    `json:"n_mfcc"`               // coefficients per frame, default 13
+	OutputDir string `json:"output_dir,omitempty"` // directory for the full matrix file, defaults to the source directory
+}
+
+// ComputeMFCCOutput is the output from the ComputeMFCC activity. The full
+// matrix is always written to MatrixPath; Coefficients is only filled in for
+// short files, to keep the activity result within Temporal's payload limits.
+type ComputeMFCCOutput struct {
+	NumFrames    int         `json:"n_frames"`
+	Mean         []float64   `json:"mean"`                   // mean of each coefficient over all frames
+	StdDev       []float64   `json:"std_dev"`                // standard deviation of each coefficient
+	MatrixPath   string      `json:"matrix_path"`            // JSON file holding the frames x coefficients matrix
+	Coefficients [][]float64 `json:"coefficients,omitempty"` // frames x coefficients, omitted above maxInlineMFCCFrames
+	FrameSize    int         `json:"frame_size"`
+	HopSize      int         `json:"hop_size"`
+	NumMels      int         `json:"n_mels"`
+	NumMFCC      int         `json:"n_mfcc"`
+
+	DBStatus
+}
+
+// ComputeDynamicRangeInput is the input for the ComputeDynamicRange activity
+type ComputeDynamicRangeInput struct {
+	AssetID       string  `json:"asset_id"`       // ID of the asset to store the dynamic range for
+	FilePath      string  `json:"file_path"`      // path to the audio file
+	WindowSeconds float64 `json:"window_seconds"` // window length in seconds, default 3.0
+	HopSeconds    float64 `json:"hop_seconds"`    // time between window starts in seconds, defaults to the window length
+}
+
+// DynamicRangeWindow is the level of one window of a ComputeDynamicRange,
+// across all channels
+type DynamicRangeWindow struct {
+	StartTime float64 `json:"start_time"` // seconds
+	EndTime   float64 `json:"end_time"`   // seconds
+	PeakDBFS  float64 `json:"peak_dbfs"`  // largest absolute sample in dBFS
+	RMSDBFS   float64 `json:"rms_dbfs"`   // RMS level in dBFS
+	CrestDB   float64 `json:"crest_db"`   // peak minus RMS level, 0 for silent windows
+}
+
+// ComputeDynamicRangeOutput is the output from the ComputeDynamicRange activity
+type ComputeDynamicRangeOutput struct {
+	DRScore       float64              `json:"dr_score"`      // dynamic range in dB as DR meters compute it, see ComputeDynamicRange
+	DRValue       int                  `json:"dr_value"`      // DRScore rounded, as DR meters display it (e.g. DR8)
+	MeanCrestDB   float64              `json:"mean_crest_db"` // mean crest factor over non-silent windows
+	Windows       []DynamicRangeWindow `json:"windows"`
+	WindowSeconds float64              `json:"window_seconds"`
+	HopSeconds    float64              `json:"hop_seconds"`
+
+	DBStatus
 }
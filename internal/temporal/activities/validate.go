@@ -0,0 +1,122 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// ErrTypeInvalidAudio is the application error type activities use for files
+// that are malformed or in an unsupported container or encoding
+const ErrTypeInvalidAudio = "InvalidAudio"
+
+// invalidAudioError returns a non-retryable error for a file that can't be
+// processed, since retrying won't change its format
+func invalidAudioError(path string, cause error) error {
+	return temporal.NewNonRetryableApplicationError("invalid audio file "+path, ErrTypeInvalidAudio, cause)
+}
+
+// audioFormatError returns err as a non-retryable InvalidAudio error if it
+// wraps ErrInvalidAudio or ErrUnsupportedFormat, and otherwise returns it
+// unchanged, so I/O errors are still retried. The result must not be wrapped,
+// or Temporal loses its error type.
+func audioFormatError(path string, err error) error {
+	if errors.Is(err, ErrInvalidAudio) || errors.Is(err, ErrUnsupportedFormat) {
+		return invalidAudioError(path, err)
+	}
+	return err
+}
+
+// ErrTypeInvalidInput is the application error type activities use for inputs
+// that can never succeed, like an empty time range or a negative window size
+const ErrTypeInvalidInput = "InvalidInput"
+
+// invalidInputError returns a non-retryable error for an activity input that
+// is wrong in itself, since every attempt would fail the same way
+func invalidInputError(format string, args ...interface{}) error {
+	return temporal.NewNonRetryableApplicationError(fmt.Sprintf(format, args...), ErrTypeInvalidInput, nil)
+}
+
+// IsInvalidInput reports whether err, returned directly by an activity or
+// received from one by a workflow, is an invalid input error
+func IsInvalidInput(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == ErrTypeInvalidInput
+}
+
+// ErrTypeFormatMismatch is the application error type ValidateAudio uses when
+// a file's sample rate or channel count isn't the one the caller expected
+const ErrTypeFormatMismatch = "FormatMismatch"
+
+// formatMismatchError returns a non-retryable error for a file whose format
+// differs from what the caller expected, since the file won't change on retry
+func formatMismatchError(path, format string, args ...interface{}) error {
+	return temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("unexpected format of %s: ", path)+fmt.Sprintf(format, args...), ErrTypeFormatMismatch, nil)
+}
+
+// IsFormatMismatch reports whether err, returned directly by an activity or
+// received from one by a workflow, is a format mismatch error
+func IsFormatMismatch(err error) bool {
+	var appErr *temporal.ApplicationError
+	return errors.As(err, &appErr) && appErr.Type() == ErrTypeFormatMismatch
+}
+
+// IsInvalidAudio reports whether err, returned directly by an activity or
+// received from one by a workflow, is a malformed or unsupported file error
+func IsInvalidAudio(err error) bool {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == ErrTypeInvalidAudio {
+		return true
+	}
+	return errors.Is(err, ErrInvalidAudio) || errors.Is(err, ErrUnsupportedFormat)
+}
+
+// ValidateAudio is a cheap pre-flight check: it reads only the file headers to
+// confirm the file is supported with non-empty sound data and returns its
+// format. Unlike IngestRawAudio it neither hashes nor decodes the samples,
+// except for Opus files, which can only be measured by decoding them.
+// Invalid and empty files fail with non-retryable errors, as do files whose
+// sample rate or channel count differs from ExpectedSampleRate or
+// ExpectedChannels when those are set, so a workflow can assert the format
+// between steps.
+func (ac *ActivitiesClient) ValidateAudio(ctx context.Context, input ValidateAudioInput) (*ValidateAudioOutput, error) {
+	file, err := ac.storage.Open(ctx, input.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return nil, audioFormatError(input.FilePath, err)
+	}
+
+	// Skip to the sound data without reading it
+	frames, err := decoder.Frames()
+	if err != nil {
+		return nil, invalidAudioError(input.FilePath, fmt.Errorf("no data chunk: %w", err))
+	}
+	if frames == 0 {
+		return nil, emptyAudioError(input.FilePath)
+	}
+
+	format := decoder.Format()
+	if input.ExpectedSampleRate != 0 && format.SampleRate != input.ExpectedSampleRate {
+		return nil, formatMismatchError(input.FilePath, "sample rate is %d Hz, expected %d Hz",
+			format.SampleRate, input.ExpectedSampleRate)
+	}
+	if input.ExpectedChannels != 0 && format.NumChannels != input.ExpectedChannels {
+		return nil, formatMismatchError(input.FilePath, "%d channels, expected %d",
+			format.NumChannels, input.ExpectedChannels)
+	}
+	return &ValidateAudioOutput{
+		SampleRate: format.SampleRate,
+		Channels:   format.NumChannels,
+		BitDepth:   decoder.BitDepth(),
+		Encoding:   decoder.Encoding(),
+		Duration:   float64(frames) / float64(format.SampleRate),
+	}, nil
+}
@@ -0,0 +1,214 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ErrEmptyAudio is the cause of the error activities return for audio files
+// with no PCM data (empty or header-only files)
+var ErrEmptyAudio = errors.New("audio contains no samples")
+
+// ErrTypeEmptyAudio is the application error type activities use for
+// ErrEmptyAudio, so workflows can recognize it once it has been serialized
+const ErrTypeEmptyAudio = "EmptyAudio"
+
+// emptyAudioError returns ErrEmptyAudio as a non-retryable application error,
+// since retrying can't add samples to the file
+func emptyAudioError(path string) error {
+	return temporal.NewNonRetryableApplicationError("empty audio file "+path, ErrTypeEmptyAudio, ErrEmptyAudio)
+}
+
+// IsEmptyAudio reports whether err, returned directly by an activity or
+// received from one by a workflow, is ErrEmptyAudio
+func IsEmptyAudio(err error) bool {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == ErrTypeEmptyAudio {
+		return true
+	}
+	return errors.Is(err, ErrEmptyAudio)
+}
+
+// WAV format tags from the fmt chunk
+const (
+	wavFormatPCM        = 1
+	wavFormatIEEEFloat  = 3
+	wavFormatExtensible = 0xFFFE // sub-format isn't exposed by the decoder, treated as PCM
+)
+
+// encodingName returns the name activities report for a WAV format tag
+func encodingName(formatTag int) string {
+	if formatTag == wavFormatIEEEFloat {
+		return "float"
+	}
+	return "pcm"
+}
+
+// checkWAVEncoding rejects encodings whose samples can't be scaled to the 16-bit
+// range the activities assume, instead of silently producing garbage
+func checkWAVEncoding(decoder *wav.Decoder) error {
+	switch decoder.WavAudioFormat {
+	case wavFormatPCM, wavFormatExtensible:
+		switch decoder.BitDepth {
+		case 8, 16, 24, 32:
+			return nil
+		}
+		return fmt.Errorf("%w: PCM bit depth %d", ErrUnsupportedFormat, decoder.BitDepth)
+	case wavFormatIEEEFloat:
+		if decoder.BitDepth == 32 {
+			return nil
+		}
+		return fmt.Errorf("%w: IEEE float bit depth %d, only 32-bit float is supported", ErrUnsupportedFormat, decoder.BitDepth)
+	default:
+		return fmt.Errorf("%w: WAV format tag %d, only PCM and IEEE float are supported", ErrUnsupportedFormat, decoder.WavAudioFormat)
+	}
+}
+
+// loadSamples opens an audio file from storage and decodes every sample, scaled to 16-bit
+func (ac *ActivitiesClient) loadSamples(ctx context.Context, path string) ([]int, *audio.Format, error) {
+	decoded, err := ac.loadAudio(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decoded.samples, decoded.format, nil
+}
+
+// loadNativeSamples is like loadSamples but keeps the source bit depth where
+// decodeNativeSamples does, and returns the depth the samples are at
+func (ac *ActivitiesClient) loadNativeSamples(ctx context.Context, path string) ([]int, *audio.Format, int, error) {
+	file, err := ac.storage.Open(ctx, path)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	decoder, err := newPCMDecoder(ctx, file)
+	if err != nil {
+		return nil, nil, 0, audioFormatError(path, err)
+	}
+	samples, bitDepth, err := decodeNativeSamples(decoder)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	if len(samples) == 0 {
+		return nil, nil, 0, emptyAudioError(path)
+	}
+	return samples, decoder.Format(), bitDepth, nil
+}
+
+// decodedAudio is a fully decoded audio file
+type decodedAudio struct {
+	samples  []int // interleaved, scaled to 16-bit
+	format   *audio.Format
+	bitDepth int    // bit depth of the source file, before scaling
+	encoding string // encoding of the source file, see pcmDecoder.Encoding
+}
+
+// loadAudio is like loadSamples but also reports the source encoding
+func (ac *ActivitiesClient) loadAudio(ctx context.Context, path string) (*decodedAudio, error) {
+	file, err := ac.storage.Open(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer file.Close()
+
+	return decodeAudio(ctx, file, path)
+}
+
+// decodeAudio decodes an audio stream from its current position. The
+// decoders seek between chunks, so non-seekable input (e.g. stdin) has to be
+// buffered to a file first. name identifies the stream in errors.
+func decodeAudio(ctx context.Context, r io.ReadSeeker, name string) (*decodedAudio, error) {
+	decoder, err := newPCMDecoder(ctx, r)
+	if err != nil {
+		return nil, audioFormatError(name, err)
+	}
+
+	samples, err := decodeSamples(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	if len(samples) == 0 {
+		return nil, emptyAudioError(name)
+	}
+	return &decodedAudio{
+		samples:  samples,
+		format:   decoder.Format(),
+		bitDepth: decoder.BitDepth(),
+		encoding: decoder.Encoding(),
+	}, nil
+}
+
+// normalizedBitDepth is the bit depth decodeSamples scales samples to
+const normalizedBitDepth = 16
+
+// decodeSamples reads every sample from the decoder, scaled to 16-bit
+func decodeSamples(decoder *pcmDecoder) ([]int, error) {
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, err
+	}
+	normalizeSamples(decoder, buf.Data)
+	return buf.Data, nil
+}
+
+// decodeNativeSamples reads every sample from the decoder at the source bit
+// depth, so they can be written back without losing precision, and returns
+// that depth. Only 24- and 32-bit integer PCM is kept as is; other encodings
+// are scaled to 16-bit like decodeSamples does, which for 8-bit is exact.
+func decodeNativeSamples(decoder *pcmDecoder) ([]int, int, error) {
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, 0, err
+	}
+	if depth := decoder.BitDepth(); !decoder.isFloat() && (depth == 24 || depth == 32) {
+		return buf.Data, depth, nil
+	}
+	normalizeSamples(decoder, buf.Data)
+	return buf.Data, normalizedBitDepth, nil
+}
+
+// normalizeSamples converts decoded samples in place to the signed 16-bit range
+// (-32768 to 32767) that the silence and noise thresholds are defined against.
+// The WAV decoder returns float samples as their raw 32-bit patterns. Lossy
+// codecs are decoded to 16-bit already and left as they are.
+func normalizeSamples(decoder *pcmDecoder, samples []int) {
+	if decoder.isFloat() {
+		for i, sample := range samples {
+			value := float64(math.Float32frombits(uint32(int32(sample)))) // #nosec G115 -- reinterpreting raw sample bits
+			samples[i] = int(math.Round(math.Max(-1, math.Min(1, value)) * 32767))
+		}
+		return
+	}
+
+	switch decoder.BitDepth() {
+	case 8:
+		if decoder.container != containerWAV {
+			// 8-bit AIFF and FLAC are signed, though the AIFF decoder returns
+			// the raw bytes
+			for i, sample := range samples {
+				samples[i] = int(int8(uint8(sample))) << 8 // #nosec G115 -- reinterpreting raw sample bits
+			}
+			return
+		}
+		// 8-bit WAV PCM is unsigned
+		for i, sample := range samples {
+			samples[i] = (sample - 128) << 8
+		}
+	case 24:
+		for i, sample := range samples {
+			samples[i] = sample >> 8
+		}
+	case 32:
+		for i, sample := range samples {
+			samples[i] = sample >> 16
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomSamples returns frames frames of full-range noise at bitDepth,
+// including both extremes
+func randomSamples(rng *rand.Rand, frames, channels, bitDepth int) []int {
+	minValue, maxValue := -1<<(bitDepth-1), 1<<(bitDepth-1)-1
+	samples := make([]int, frames*channels)
+	for i := range samples {
+		samples[i] = minValue + rng.Intn(maxValue-minValue+1)
+	}
+	samples[0], samples[len(samples)-1] = minValue, maxValue
+	return samples
+}
+
+// decodeTestFile decodes a file written by the activities the way they read
+// their sources
+func decodeTestFile(t *testing.T, path string) (samples []int, format *audio.Format, bitDepth int) {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	decoder, err := newPCMDecoder(context.Background(), file)
+	require.NoError(t, err)
+	samples, bitDepth, err = decodeNativeSamples(decoder)
+	require.NoError(t, err)
+	return samples, decoder.Format(), bitDepth
+}
+
+// TestWriteRoundTrip writes samples with writeWAV and writeFLAC and checks
+// they decode to exactly the same samples, at the same depth and format
+func TestWriteRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ac := NewActivitiesClient(nil, nil, ActivitiesConfig{DataDir: dir})
+	rng := rand.New(rand.NewSource(1))
+
+	writers := map[string]func(context.Context, string, *audio.Format, []int, int) (string, string, error){
+		"wav":  ac.writeWAV,
+		"flac": ac.writeFLAC,
+	}
+	for ext, write := range writers {
+		for _, bitDepth := range []int{16, 24, 32} {
+			for _, channels := range []int{1, 2, 6} {
+				// Odd frame counts give odd-sized 24-bit data chunks
+				for _, frames := range []int{1, 4097} {
+					name := fmt.Sprintf("%s/%d-bit/%dch/%d", ext, bitDepth, channels, frames)
+					t.Run(name, func(t *testing.T) {
+						samples := randomSamples(rng, frames, channels, bitDepth)
+						format := &audio.Format{NumChannels: channels, SampleRate: 48000}
+						path, hash, err := write(context.Background(), filepath.Join(dir, "out."+ext), format, samples, bitDepth)
+						require.NoError(t, err)
+						assert.NotEmpty(t, hash)
+						defer os.Remove(path)
+
+						decoded, decodedFormat, decodedDepth := decodeTestFile(t, path)
+						assert.Equal(t, bitDepth, decodedDepth)
+						assert.Equal(t, channels, decodedFormat.NumChannels)
+						assert.Equal(t, 48000, decodedFormat.SampleRate)
+						require.Equal(t, samples, decoded)
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestWriteWAVWithChunksRoundTrip checks the samples of a file written with
+// metadata chunks after an odd-sized data chunk, and the chunks themselves
+func TestWriteWAVWithChunksRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ac := NewActivitiesClient(nil, nil, ActivitiesConfig{DataDir: dir})
+	samples := randomSamples(rand.New(rand.NewSource(2)), 1001, 1, 24)
+	chunks := []wavChunk{
+		{id: [4]byte{'i', 'X', 'M', 'L'}, data: []byte("<BWFXML/>")}, // odd size, padded
+		{id: cueChunkID, data: []byte{0, 0, 0, 0}},
+	}
+
+	path, _, err := ac.writeWAVWithChunks(context.Background(), filepath.Join(dir, "out.wav"),
+		&audio.Format{NumChannels: 1, SampleRate: 44100}, samples, 24, chunks)
+	require.NoError(t, err)
+
+	decoded, _, bitDepth := decodeTestFile(t, path)
+	assert.Equal(t, 24, bitDepth)
+	require.Equal(t, samples, decoded)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	read, err := readExtraChunks(file)
+	require.NoError(t, err)
+	assert.Equal(t, chunks, read)
+}
@@ -0,0 +1,179 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"time"
+
+	"github.com/pphelan007/davidAI/internal/storage"
+)
+
+// Waveform defaults and limits
+const (
+	defaultWaveformBuckets = 1000
+	maxWaveformBuckets     = 10000 // keeps the three value arrays well within Temporal's payload limit
+	defaultWaveformHeight  = 128
+	maxWaveformHeight      = 4096
+)
+
+// Colors of the waveform PNG: the min/max envelope, with the RMS level drawn
+// darker inside it, on a transparent background
+var (
+	waveformPeakColor = color.RGBA{R: 0x4a, G: 0x90, B: 0xd9, A: 0xff}
+	waveformRMSColor  = color.RGBA{R: 0x1f, G: 0x4e, B: 0x8c, A: 0xff}
+)
+
+func init() {
+	RegisterFeature(Feature{
+		Name:     "waveform",
+		Activity: "GenerateWaveform",
+		Input: func(req FeatureRequest) interface{} {
+			return GenerateWaveformInput{AssetID: req.AssetID, FilePath: req.FilePath}
+		},
+		Handler: func(ac *ActivitiesClient) interface{} { return ac.GenerateWaveform },
+	})
+}
+
+// GenerateWaveform computes a downsampled waveform of an audio file for
+// display: the file is split into equal time buckets, and the lowest and
+// highest sample and the RMS level of each are returned, so a UI can draw the
+// waveform without fetching and decoding the file. Values are rounded to four
+// decimal places, which is finer than any display needs. The arrays are
+// stored as the "waveform" feature, with the PNG path if one was written.
+func (ac *ActivitiesClient) GenerateWaveform(ctx context.Context, input GenerateWaveformInput) (*GenerateWaveformOutput, error) {
+	if err := ac.waitRetryJitter(ctx); err != nil {
+		return nil, err
+	}
+	buckets := input.Buckets
+	if buckets == 0 {
+		buckets = defaultWaveformBuckets
+	}
+	if buckets < 1 || buckets > maxWaveformBuckets {
+		return nil, invalidInputError("buckets must be from 1 to %d, got %d", maxWaveformBuckets, buckets)
+	}
+	height := input.ImageHeight
+	if height == 0 {
+		height = defaultWaveformHeight
+	}
+	if height < 2 || height > maxWaveformHeight {
+		return nil, invalidInputError("image height must be from 2 to %d pixels, got %d", maxWaveformHeight, height)
+	}
+
+	release, err := ac.acquireDecode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	samples, format, err := ac.loadSamples(ctx, input.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	channels := format.NumChannels
+	frames := len(samples) / channels
+	if frames == 0 {
+		return nil, emptyAudioError(input.FilePath)
+	}
+
+	output := waveformBuckets(samples[:frames*channels], channels, min(buckets, frames))
+	output.Duration = float64(frames) / float64(format.SampleRate)
+
+	if input.WritePNG {
+		outputDir, err := ac.resolveOutputDir(ctx, input.OutputDir, input.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		imagePath := storage.Join(outputDir, fmt.Sprintf("waveform_%s_%s.png", input.AssetID, time.Now().Format("20060102_150405")))
+		if output.ImagePath, err = ac.writeWaveformPNG(ctx, imagePath, output, height); err != nil {
+			return nil, err
+		}
+	}
+
+	data := map[string]interface{}{
+		"buckets":  output.Buckets,
+		"duration": output.Duration,
+		"min":      output.Min,
+		"max":      output.Max,
+		"rms":      output.RMS,
+	}
+	if output.ImagePath != "" {
+		data["image_path"] = output.ImagePath
+	}
+	if err = ac.recordFeature(ctx, &output.DBStatus, input.AssetID, "waveform", data,
+		map[string]interface{}{
+			"buckets":     buckets,
+			"sample_rate": format.SampleRate,
+			"channels":    channels,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// waveformBuckets splits whole frames of interleaved 16-bit samples into the
+// given number of buckets of (nearly) equal length and measures each across
+// all channels
+func waveformBuckets(samples []int, channels, buckets int) *GenerateWaveformOutput {
+	frames := len(samples) / channels
+	output := &GenerateWaveformOutput{
+		Buckets: buckets,
+		Min:     make([]float64, buckets),
+		Max:     make([]float64, buckets),
+		RMS:     make([]float64, buckets),
+	}
+	for b := 0; b < buckets; b++ {
+		start := b * frames / buckets * channels
+		end := (b + 1) * frames / buckets * channels
+		lo, hi := math.MaxInt, math.MinInt
+		sumSquares := 0.0
+		for _, sample := range samples[start:end] {
+			lo, hi = min(lo, sample), max(hi, sample)
+			sumSquares += float64(sample) * float64(sample)
+		}
+		output.Min[b] = roundWaveform(float64(lo) / 32768.0)
+		output.Max[b] = roundWaveform(float64(hi) / 32768.0)
+		output.RMS[b] = roundWaveform(math.Sqrt(sumSquares/float64(end-start)) / 32768.0)
+	}
+	return output
+}
+
+// roundWaveform rounds a waveform value to four decimal places
+func roundWaveform(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
+
+// writeWaveformPNG renders a waveform one pixel column per bucket and writes
+// it as a PNG, returning the path it was written to, which the overwrite
+// policy may have changed
+func (ac *ActivitiesClient) writeWaveformPNG(ctx context.Context, path string, waveform *GenerateWaveformOutput,
+	height int) (writtenPath string, err error) {
+	img := image.NewRGBA(image.Rect(0, 0, waveform.Buckets, height))
+	// y maps a -1..1 value to a row, with +1 at the top
+	y := func(v float64) int {
+		return int(math.Round((1 - math.Max(-1, math.Min(1, v))) * float64(height-1) / 2))
+	}
+	for x := 0; x < waveform.Buckets; x++ {
+		for row := y(waveform.Max[x]); row <= y(waveform.Min[x]); row++ {
+			img.SetRGBA(x, row, waveformPeakColor)
+		}
+		for row := y(waveform.RMS[x]); row <= y(-waveform.RMS[x]); row++ {
+			img.SetRGBA(x, row, waveformRMSColor)
+		}
+	}
+
+	file, writtenPath, err := ac.createOutput(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer ac.closeOutput(ctx, file, writtenPath, &err)
+
+	if err = png.Encode(file, img); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", writtenPath, err)
+	}
+	return writtenPath, nil
+}
@@ -0,0 +1,74 @@
+package temporal
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+
+	"github.com/pphelan007/davidAI/internal/database"
+	"github.com/pphelan007/davidAI/internal/metrics"
+)
+
+// activityRunRecordTimeout bounds the insert of an activity_runs row, which
+// happens after the activity returns and delays its completion until done
+const activityRunRecordTimeout = 5 * time.Second
+
+// activityRunInterceptor records every activity attempt the worker executes in
+// the activity_runs table: its IDs, start time, duration and outcome
+type activityRunInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	dbClient *database.Client
+}
+
+func newActivityRunInterceptor(dbClient *database.Client) *activityRunInterceptor {
+	return &activityRunInterceptor{dbClient: dbClient}
+}
+
+func (i *activityRunInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityRunInbound{
+		ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next},
+		dbClient:                       i.dbClient,
+	}
+}
+
+type activityRunInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	dbClient *database.Client
+}
+
+func (a *activityRunInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	startedAt := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+
+	info := activity.GetInfo(ctx)
+	run := &database.ActivityRun{
+		ID:            uuid.New().String(),
+		WorkflowID:    info.WorkflowExecution.ID,
+		WorkflowRunID: info.WorkflowExecution.RunID,
+		ActivityID:    info.ActivityID,
+		ActivityType:  info.ActivityType.Name,
+		Attempt:       info.Attempt,
+		StartedAt:     startedAt,
+		Duration:      time.Since(startedAt),
+		Succeeded:     err == nil,
+	}
+	if err != nil {
+		run.ErrorMessage = err.Error()
+	}
+
+	// The attempt is recorded even if the activity was cancelled or timed out,
+	// which is when its own context is already done
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), activityRunRecordTimeout)
+	defer cancel()
+	if insertErr := a.dbClient.InsertActivityRun(recordCtx, run); insertErr != nil {
+		// Log error but don't fail the activity
+		log.Error().Err(insertErr).Str("activity_type", run.ActivityType).Msg("Failed to record activity run")
+		metrics.DBInsertErrors.WithLabelValues("activity_runs").Inc()
+	}
+
+	return result, err
+}
@@ -3,27 +3,78 @@ package temporal
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+
+	"github.com/pphelan007/davidAI/internal/config"
+	"github.com/pphelan007/davidAI/internal/logging"
+	"github.com/pphelan007/davidAI/internal/tracing"
 )
 
+// maxDialBackoff caps the delay between attempts to connect to Temporal
+const maxDialBackoff = 30 * time.Second
+
 type TemporalClient struct {
 	client client.Client
 }
 
-// NewTemporalClient creates a new Temporal client connected to the local dev server
-func NewTemporalClient(ctx context.Context, address, namespace string) (*TemporalClient, error) {
-	c, err := client.Dial(client.Options{
-		HostPort:  address,
-		Namespace: namespace,
-	})
+// NewTemporalClient creates a new Temporal client connected to the configured
+// server. Failed connection attempts (and failed health checks, if enabled)
+// are retried up to cfg.DialAttempts times with a doubling delay, so a worker
+// started alongside Temporal waits for it to come up.
+func NewTemporalClient(ctx context.Context, cfg *config.TemporalConfig) (*TemporalClient, error) {
+	tracingInterceptor, err := tracing.NewTemporalInterceptor()
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial Temporal server at %s: %w", address, err)
+		return nil, err
+	}
+	options := client.Options{
+		HostPort:     cfg.Address,
+		Namespace:    cfg.Namespace,
+		Identity:     cfg.Identity,
+		Logger:       logging.NewTemporalLogger(log.Logger),
+		Interceptors: []interceptor.ClientInterceptor{tracingInterceptor},
+	}
+
+	backoff := cfg.DialRetryInterval
+	for attempt := 1; ; attempt++ {
+		c, err := dial(ctx, options, cfg.CheckHealth)
+		if err == nil {
+			return &TemporalClient{
+				client: c,
+			}, nil
+		}
+		if attempt >= cfg.DialAttempts {
+			return nil, fmt.Errorf("failed to connect to Temporal server at %s after %d attempts: %w", cfg.Address, attempt, err)
+		}
+
+		log.Warn().Err(err).Str("address", cfg.Address).Int("attempt", attempt).
+			Int("max_attempts", cfg.DialAttempts).Dur("backoff", backoff).Msg("Temporal server not ready, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff = min(backoff*2, maxDialBackoff)
 	}
+}
 
-	return &TemporalClient{
-		client: c,
-	}, nil
+// dial connects to Temporal once and, if checkHealth is set, runs the server's
+// health check, closing the client again if it fails
+func dial(ctx context.Context, options client.Options, checkHealth bool) (client.Client, error) {
+	c, err := client.DialContext(ctx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial Temporal server: %w", err)
+	}
+	if checkHealth {
+		if _, err = c.CheckHealth(ctx, &client.CheckHealthRequest{}); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("health check of Temporal server failed: %w", err)
+		}
+	}
+	return c, nil
 }
 
 // GetClient returns the underlying Temporal client
@@ -3,10 +3,14 @@ package temporal
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 
 	"github.com/pphelan007/davidAI/internal/database"
@@ -14,49 +18,82 @@ import (
 	"github.com/pphelan007/davidAI/internal/temporal/workflows"
 )
 
-// Worker handles Temporal job processing
+// Worker handles Temporal job processing. It polls one or more task queues,
+// with a Temporal worker for each that runs every workflow and activity.
 type Worker struct {
-	client         client.Client
-	temporalWorker worker.Worker
-	ctx            context.Context
-	cancel         context.CancelFunc
-	wg             sync.WaitGroup
-	taskQueue      string
-	dbClient       *database.Client
+	client          client.Client
+	temporalWorkers []worker.Worker
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	taskQueues      []string
+	buildID         string
+	stopTimeout     time.Duration
+	ready           atomic.Bool
 }
 
-// NewWorker creates a new worker instance
-func NewWorker(c client.Client, taskQueue string, dbClient *database.Client) (*Worker, error) {
+// NewWorker creates a new worker instance polling taskQueues. On stop,
+// in-flight activities get up to stopTimeout to finish before their contexts
+// are cancelled. With a database client, every activity attempt is recorded
+// in activity_runs; the caller owns the client and closes it after the
+// worker has stopped. The worker reports buildID to Temporal, and with
+// useVersioning only takes the tasks the server's versioning rules assign to it.
+func NewWorker(c client.Client, taskQueues []string, dbClient *database.Client, stopTimeout time.Duration,
+	buildID string, useVersioning bool) (*Worker, error) {
+	if len(taskQueues) == 0 {
+		return nil, fmt.Errorf("no task queues to poll")
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Create Temporal worker
-	temporalWorker := worker.New(c, taskQueue, worker.Options{})
+	// Worker Deployment Versioning, which replaces these fields, is still
+	// experimental and needs a versioning behavior on every workflow
+	options := worker.Options{
+		WorkerStopTimeout:       stopTimeout,
+		BuildID:                 buildID,       //nolint:staticcheck // see above
+		UseBuildIDForVersioning: useVersioning, //nolint:staticcheck // see above
+	}
+	if dbClient != nil {
+		options.Interceptors = []interceptor.WorkerInterceptor{newActivityRunInterceptor(dbClient)}
+	}
+
+	// Create a Temporal worker per task queue
+	temporalWorkers := make([]worker.Worker, len(taskQueues))
+	for i, taskQueue := range taskQueues {
+		temporalWorkers[i] = worker.New(c, taskQueue, options)
+	}
 
 	return &Worker{
-		client:         c,
-		temporalWorker: temporalWorker,
-		ctx:            ctx,
-		cancel:         cancel,
-		taskQueue:      taskQueue,
-		dbClient:       dbClient,
+		client:          c,
+		temporalWorkers: temporalWorkers,
+		ctx:             ctx,
+		cancel:          cancel,
+		taskQueues:      taskQueues,
+		buildID:         buildID,
+		stopTimeout:     stopTimeout,
 	}, nil
 }
 
 // RegisterWorkflows registers all workflows with the worker
 func (w *Worker) RegisterWorkflows() {
-	workflows.RegisterWorkflows(w.temporalWorker)
-	log.Println("Workflows registered")
+	for _, temporalWorker := range w.temporalWorkers {
+		workflows.RegisterWorkflows(temporalWorker)
+	}
+	log.Info().Msg("Workflows registered")
 }
 
 // RegisterActivities registers all activities with the worker
 func (w *Worker) RegisterActivities(activitiesClient *activities.ActivitiesClient) {
-	activities.RegisterActivities(w.temporalWorker, activitiesClient)
-	log.Println("Activities registered")
+	for _, temporalWorker := range w.temporalWorkers {
+		activities.RegisterActivities(temporalWorker, activitiesClient)
+	}
+	log.Info().Msg("Activities registered")
 }
 
-// Start begins processing jobs
-func (w *Worker) Start(activitiesClient *activities.ActivitiesClient) {
-	log.Printf("Starting Temporal worker on task queue: %s", w.taskQueue)
+// Start registers the workflows and activities and starts polling every task
+// queue, returning once the pollers are running. If a Temporal worker fails
+// to start, the ones already started are stopped and the error is returned.
+func (w *Worker) Start(activitiesClient *activities.ActivitiesClient) error {
+	log.Info().Strs("task_queues", w.taskQueues).Str("build_id", w.buildID).Msg("Starting Temporal worker")
 
 	// Register workflows and activities
 	w.RegisterWorkflows()
@@ -64,33 +101,70 @@ func (w *Worker) Start(activitiesClient *activities.ActivitiesClient) {
 	// Register activities with the provided client
 	w.RegisterActivities(activitiesClient)
 
-	// Start the worker in a goroutine
-	w.wg.Add(1)
-	go func() {
-		defer w.wg.Done()
-		// Create interrupt channel that closes when context is cancelled
-		interruptCh := make(chan interface{})
+	// Temporal workers start their pollers in the background and return
+	for i, temporalWorker := range w.temporalWorkers {
+		if err := temporalWorker.Start(); err != nil {
+			for _, started := range w.temporalWorkers[:i] {
+				started.Stop()
+			}
+			return fmt.Errorf("failed to start Temporal worker for task queue %s: %w", w.taskQueues[i], err)
+		}
+	}
+	// The worker can take work once pollers are running on every queue
+	w.ready.Store(true)
+
+	for _, temporalWorker := range w.temporalWorkers {
+		w.wg.Add(1)
 		go func() {
+			defer w.wg.Done()
+			// Stop polling once the context is cancelled, waiting for in-flight activities
 			<-w.ctx.Done()
-			close(interruptCh)
+			w.ready.Store(false)
+			temporalWorker.Stop()
 		}()
-		if err := w.temporalWorker.Run(interruptCh); err != nil {
-			log.Printf("Temporal worker error: %v", err)
-		}
-	}()
+	}
+
+	log.Info().Msg("Temporal worker started and ready to process tasks")
+	return nil
+}
 
-	log.Println("Temporal worker started and ready to process tasks")
+// Ready reports whether the worker has started polling all its task queues and has
+// not begun shutting down
+func (w *Worker) Ready() bool {
+	return w.ready.Load()
 }
 
-// Stop stops the worker and waits for all jobs to complete
+// stopMargin is how long Stop waits beyond the worker's stop timeout, for
+// activities whose contexts were cancelled at the timeout to return
+const stopMargin = 10 * time.Second
+
+// Stop drains the worker: it stops polling for new tasks right away and lets
+// in-flight activities run for the stop timeout the worker was created with,
+// after which their contexts are cancelled. An error is returned if the
+// worker has not stopped within the stop timeout plus stopMargin; reporting
+// failure before the activities have returned would let the caller close
+// resources they still use.
 func (w *Worker) Stop() error {
-	log.Println("Stopping Temporal worker...")
+	log.Info().Dur("grace_period", w.stopTimeout).Msg("Draining Temporal worker...")
+
+	// Cancelling the context makes the worker stop polling and wait for
+	// in-flight activities before returning
 	w.cancel()
-	w.temporalWorker.Stop()
-	w.wg.Wait()
-	if w.dbClient != nil {
-		w.dbClient.Close()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	wait := w.stopTimeout + stopMargin
+	select {
+	case <-done:
+	case <-time.After(wait):
+		log.Warn().Dur("grace_period", w.stopTimeout).Dur("waited", wait).Msg("Temporal worker did not drain within grace period")
+		return fmt.Errorf("temporal worker did not stop within %s", wait)
 	}
-	log.Println("Temporal worker stopped")
+
+	log.Info().Msg("Temporal worker stopped")
 	return nil
 }
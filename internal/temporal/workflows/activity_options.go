@@ -0,0 +1,76 @@
+package workflows
+
+import (
+	"math"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
+)
+
+// Default activity timeout and retry policy, used for any ActivityOptions field left unset
+const (
+	DefaultStartToCloseTimeout = 5 * time.Minute
+	DefaultInitialInterval     = time.Second
+	DefaultMaximumInterval     = time.Minute
+	DefaultMaximumAttempts     = 3
+)
+
+// nonRetryableErrorTypes are the activity error types the retry policy never
+// retries. Activities already return them as non-retryable; listing them here
+// too keeps an invalid file or input failing fast if an activity ever returns
+// one as retryable by mistake.
+var nonRetryableErrorTypes = []string{
+	activities.ErrTypeInvalidAudio,
+	activities.ErrTypeInvalidInput,
+	activities.ErrTypeFormatMismatch,
+	activities.ErrTypeEmptyAudio,
+	activities.ErrTypeFileTooLarge,
+	activities.ErrTypeOutputExists,
+}
+
+// ActivityOptions is the timeout and retry policy a workflow applies to its
+// activities. It is passed in the workflow input so it can be tuned per
+// deployment without recompiling.
+type ActivityOptions struct {
+	StartToCloseTimeout time.Duration `json:"start_to_close_timeout,omitempty"` // maximum time per attempt
+	InitialInterval     time.Duration `json:"initial_interval,omitempty"`       // delay before the first retry
+	MaximumInterval     time.Duration `json:"maximum_interval,omitempty"`       // cap on the retry delay
+	MaximumAttempts     int           `json:"maximum_attempts,omitempty"`       // attempts including the first
+}
+
+// workflowOptions converts the options to Temporal activity options, filling in
+// defaults for unset fields. A nil receiver returns the defaults.
+func (o *ActivityOptions) workflowOptions() workflow.ActivityOptions {
+	var opts ActivityOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.StartToCloseTimeout <= 0 {
+		opts.StartToCloseTimeout = DefaultStartToCloseTimeout
+	}
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = DefaultInitialInterval
+	}
+	if opts.MaximumInterval <= 0 {
+		opts.MaximumInterval = DefaultMaximumInterval
+	}
+	if opts.MaximumAttempts <= 0 {
+		opts.MaximumAttempts = DefaultMaximumAttempts
+	}
+
+	// Temporal doesn't jitter retries; activities add a random delay to retried
+	// attempts themselves (see ACTIVITY_RETRY_JITTER) so they don't retry in lockstep
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: opts.StartToCloseTimeout,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        opts.InitialInterval,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        opts.MaximumInterval,
+			MaximumAttempts:        int32(min(opts.MaximumAttempts, math.MaxInt32)), // #nosec G115 -- clamped to int32
+			NonRetryableErrorTypes: nonRetryableErrorTypes,
+		},
+	}
+}
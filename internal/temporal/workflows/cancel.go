@@ -0,0 +1,61 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/workflow"
+)
+
+// CancelSignalName is the signal type that asks AudioProcessingWorkflow to stop
+const CancelSignalName = "cancel"
+
+// cancelSignal lets a workflow stop cleanly between steps when the cancel
+// signal arrives. Activities are started on its context, which the signal
+// cancels, and are still waited for, so none is left running with its output
+// unaccounted for. Activities don't heartbeat, so one that is already running
+// usually finishes anyway and its result is returned as normal.
+type cancelSignal struct {
+	channel   workflow.ReceiveChannel
+	ctx       workflow.Context // context to start activities on
+	cancel    workflow.CancelFunc
+	cancelled bool
+}
+
+func newCancelSignal(ctx workflow.Context) *cancelSignal {
+	activityCtx, cancel := workflow.WithCancel(ctx)
+	options := workflow.GetActivityOptions(activityCtx)
+	options.WaitForCancellation = true
+	return &cancelSignal{
+		channel: workflow.GetSignalChannel(ctx, CancelSignalName),
+		ctx:     workflow.WithActivityOptions(activityCtx, options),
+		cancel:  cancel,
+	}
+}
+
+// requested reports whether the cancel signal has arrived, without blocking
+func (c *cancelSignal) requested(ctx workflow.Context) bool {
+	if !c.cancelled && c.channel.ReceiveAsync(nil) {
+		c.request(ctx)
+	}
+	return c.cancelled
+}
+
+// await waits for an activity started on c.ctx and decodes its result into
+// valuePtr. If the cancel signal arrives first, the activity is cancelled and
+// waited for until it stops. A nil cancelSignal just waits for the future.
+func (c *cancelSignal) await(ctx workflow.Context, future workflow.Future, valuePtr interface{}) error {
+	if c != nil && !c.cancelled {
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(future, func(workflow.Future) {})
+		selector.AddReceive(c.channel, func(channel workflow.ReceiveChannel, _ bool) {
+			channel.Receive(ctx, nil)
+			c.request(ctx)
+		})
+		selector.Select(ctx)
+	}
+	return future.Get(ctx, valuePtr)
+}
+
+func (c *cancelSignal) request(ctx workflow.Context) {
+	workflow.GetLogger(ctx).Info("Cancel requested, stopping after the current step")
+	c.cancelled = true
+	c.cancel()
+}
@@ -3,87 +3,302 @@ package workflows
 
 import (
 	"fmt"
-	"time"
 
-	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 
 	"github.com/pphelan007/davidAI/internal/temporal/activities"
 )
 
+// ProgressQueryName is the query type used to read AudioProcessingWorkflow progress
+const ProgressQueryName = "progress"
+
+// Stages reported by the progress query
+const (
+	StageValidating = "validating"
+	StageIngesting  = "ingesting"
+	StageTrimming   = "trimming"
+	StageExtracting = "extracting"
+	StageDone       = "done"
+	StageCancelled  = "cancelled"
+)
+
+// AudioProcessingProgress is the state returned by the progress query
+type AudioProcessingProgress struct {
+	Stage           string `json:"stage"`
+	IngestedAssetID string `json:"ingested_asset_id,omitempty"`
+	TrimmedAssetID  string `json:"trimmed_asset_id,omitempty"` // empty until trimming creates a new asset
+}
+
+// Default processing parameters used when the workflow input leaves them unset
+const (
+	DefaultSilenceThreshold   = 0.01 // 1% threshold
+	DefaultMinSilenceDuration = 0.1  // 100ms minimum silence
+	DefaultNoiseThreshold     = 0.01 // 1% threshold
+)
+
 // AudioProcessingWorkflowInput is the input for the AudioProcessingWorkflow
 type AudioProcessingWorkflowInput struct {
-	FilePath string `json:"file_path"`
+	FilePath           string  `json:"file_path"`
+	Strict             bool    `json:"strict"`                         // if true, any feature extraction failure fails the workflow
+	SilenceThreshold   float64 `json:"silence_threshold,omitempty"`    // trim threshold (0.0-1.0), defaults to DefaultSilenceThreshold
+	ThresholdMode      string  `json:"threshold_mode,omitempty"`       // trim threshold mode, see activities.TrimSilenceInput; defaults to absolute
+	MinSilenceDuration float64 `json:"min_silence_duration,omitempty"` // trim minimum silence in seconds, defaults to DefaultMinSilenceDuration
+	NoiseThreshold     float64 `json:"noise_threshold,omitempty"`      // SNR noise threshold (0.0-1.0), defaults to DefaultNoiseThreshold
+	UseSilentSegments  *bool   `json:"use_silent_segments,omitempty"`  // SNR noise estimation from silent segments, defaults to true
+	PerChannelSNR      bool    `json:"per_channel_snr,omitempty"`      // if true, SNR is also computed for each channel
+	AllowDuplicate     bool    `json:"allow_duplicate,omitempty"`      // if true, reprocess content that was already ingested
+	DatasetID          string  `json:"dataset_id,omitempty"`           // dataset to store assets in; duplicates are detected per dataset
+	TrimOutputFormat   string  `json:"trim_output_format,omitempty"`   // trimmed file format ("wav" or "flac"), defaults to WAV
+	ForceRecompute     bool    `json:"force_recompute,omitempty"`      // if true, SNR is computed even if a cached result exists
+
+	ActivityOptions *ActivityOptions `json:"activity_options,omitempty"` // activity timeout and retries, defaults when nil
 }
 
 // AudioProcessingWorkflowOutput is the output from the AudioProcessingWorkflow
 type AudioProcessingWorkflowOutput struct {
-	IngestedAsset activities.AssetInfo         `json:"ingested_asset"`
-	TrimmedOutput activities.TrimSilenceOutput `json:"trimmed_output"`
-	SnrOutput     activities.ComputeSNROutput  `json:"snr_output"`
+	IngestedAsset activities.AssetInfo             `json:"ingested_asset"`
+	TrimmedOutput activities.TrimSilenceOutput     `json:"trimmed_output"`
+	SnrOutput     activities.ComputeSNROutput      `json:"snr_output"`
+	TruePeak      activities.ComputeTruePeakOutput `json:"true_peak"`
+	Metadata      activities.ReadMetadataOutput    `json:"metadata"`
+	FeatureErrors map[string]string                `json:"feature_errors,omitempty"` // feature name -> error, only set when not strict
+	Duplicate     bool                             `json:"duplicate,omitempty"`      // true if the content was already ingested and IngestedAsset is the existing asset
+	Cancelled     bool                             `json:"cancelled,omitempty"`      // true if the cancel signal stopped processing; the trimmed file is discarded
+}
+
+// AudioProcessingWorkflowIDPrefix is the default prefix of AudioProcessingWorkflow IDs
+const AudioProcessingWorkflowIDPrefix = "audio-processing"
+
+// AudioProcessingWorkflowID returns a deterministic workflow ID for the given
+// prefix, dataset and content hash. Starting a workflow with this ID while one
+// is already running for the same content returns the running workflow
+// instead of a new one. The same content in different datasets, or under
+// different prefixes, gets different IDs.
+func AudioProcessingWorkflowID(prefix, datasetID, contentHash string) string {
+	if datasetID == "" {
+		return prefix + "-" + contentHash
+	}
+	return prefix + "-" + datasetID + "-" + contentHash
 }
 
-// AudioProcessingWorkflow is a simple workflow that ingests raw audio and trims silence
+// AudioProcessingWorkflow ingests raw audio, trims silence, and then runs the
+// feature extraction activities concurrently on the result. The cancel signal
+// stops it after the current step: the trimmed file and its asset are
+// discarded, and the output reports the cancellation instead of an error.
 func AudioProcessingWorkflow(ctx workflow.Context, input AudioProcessingWorkflowInput) (*AudioProcessingWorkflowOutput, error) {
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: 5 * time.Minute,
-		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval:    time.Second,
-			BackoffCoefficient: 2.0,
-			MaximumInterval:    time.Minute,
-			MaximumAttempts:    3,
-		},
+	ctx = workflow.WithActivityOptions(ctx, input.ActivityOptions.workflowOptions())
+
+	// Expose progress so clients can see how far the workflow has gotten
+	progress := AudioProcessingProgress{Stage: StageValidating}
+	err := workflow.SetQueryHandler(ctx, ProgressQueryName, func() (AudioProcessingProgress, error) {
+		return progress, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register progress query: %w", err)
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	// Step 1: Ingest raw audio from the data folder
 	var ingestOutput *activities.IngestRawAudioOutput
-	err := workflow.ExecuteActivity(ctx, "IngestRawAudio", activities.IngestRawAudioInput{
-		FilePath: input.FilePath,
-	}).Get(ctx, &ingestOutput)
+	var trimOutput *activities.TrimSilenceOutput
+	cancel := newCancelSignal(ctx)
+	cancelled := func() (*AudioProcessingWorkflowOutput, error) {
+		progress.Stage = StageCancelled
+		output := &AudioProcessingWorkflowOutput{Cancelled: true}
+		if ingestOutput != nil {
+			output.IngestedAsset = ingestOutput.Asset
+		}
+		if trimOutput != nil && trimOutput.OutputPath != "" {
+			err := workflow.ExecuteActivity(ctx, "DiscardTrimmedOutput", activities.DiscardTrimmedOutputInput{
+				AssetID:  trimOutput.NewAssetID,
+				FilePath: trimOutput.OutputPath,
+			}).Get(ctx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to discard trimmed output: %w", err)
+			}
+			progress.TrimmedAssetID = ""
+		}
+		return output, nil
+	}
+	if cancel.requested(ctx) {
+		return cancelled()
+	}
+
+	// Reject unreadable and empty files from their headers alone, before
+	// hashing or decoding them. Versioned so workflows started before this
+	// step existed still replay.
+	if workflow.GetVersion(ctx, "validate-audio", workflow.DefaultVersion, 1) == 1 {
+		err = cancel.await(ctx, workflow.ExecuteActivity(cancel.ctx, "ValidateAudio", activities.ValidateAudioInput{
+			FilePath: input.FilePath,
+		}), nil)
+		if cancel.requested(ctx) {
+			return cancelled()
+		}
+		if err != nil {
+			if activities.IsInvalidAudio(err) || activities.IsEmptyAudio(err) {
+				return nil, fmt.Errorf("%s can't be processed: %w", input.FilePath, err)
+			}
+			return nil, fmt.Errorf("failed to validate audio: %w", err)
+		}
+	}
+	progress.Stage = StageIngesting
+
+	// Step 0: Skip processing if this content was already ingested
+	if !input.AllowDuplicate {
+		var existingOutput *activities.FindExistingAssetOutput
+		err = cancel.await(ctx, workflow.ExecuteActivity(cancel.ctx, "FindExistingAsset", activities.FindExistingAssetInput{
+			FilePath:  input.FilePath,
+			DatasetID: input.DatasetID,
+		}), &existingOutput)
+		if cancel.requested(ctx) {
+			return cancelled()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for existing asset: %w", err)
+		}
+		if existingOutput.ExistingAsset != nil {
+			workflow.GetLogger(ctx).Info("Content already ingested, returning existing asset",
+				"asset_id", existingOutput.ExistingAsset.AssetID, "content_hash", existingOutput.ContentHash)
+			progress.IngestedAssetID = existingOutput.ExistingAsset.AssetID
+			progress.Stage = StageDone
+			return &AudioProcessingWorkflowOutput{
+				IngestedAsset: *existingOutput.ExistingAsset,
+				Duplicate:     true,
+			}, nil
+		}
+	}
+
+	// Step 1: Ingest raw audio from the data folder
+	err = cancel.await(ctx, workflow.ExecuteActivity(cancel.ctx, "IngestRawAudio", activities.IngestRawAudioInput{
+		FilePath:  input.FilePath,
+		DatasetID: input.DatasetID,
+	}), &ingestOutput)
+	if cancel.requested(ctx) {
+		return cancelled()
+	}
 	if err != nil {
+		if activities.IsEmptyAudio(err) {
+			// Not retried, and no asset was created for it
+			return nil, fmt.Errorf("%s has no audio data to process: %w", input.FilePath, err)
+		}
+		if activities.IsFileTooLarge(err) {
+			return nil, fmt.Errorf("%s is too large to process: %w", input.FilePath, err)
+		}
 		return nil, fmt.Errorf("failed to ingest raw audio: %w", err)
 	}
 
+	progress.IngestedAssetID = ingestOutput.Asset.AssetID
+
 	// Step 2: Trim silence (which internally uses findNonSilentRange)
-	var trimOutput *activities.TrimSilenceOutput
-	err = workflow.ExecuteActivity(ctx, "TrimSilence", activities.TrimSilenceInput{
+	progress.Stage = StageTrimming
+	silenceThreshold := input.SilenceThreshold
+	if silenceThreshold == 0 {
+		silenceThreshold = DefaultSilenceThreshold
+	}
+	minSilenceDuration := input.MinSilenceDuration
+	if minSilenceDuration == 0 {
+		minSilenceDuration = DefaultMinSilenceDuration
+	}
+	err = cancel.await(ctx, workflow.ExecuteActivity(cancel.ctx, "TrimSilence", activities.TrimSilenceInput{
 		AssetID:            ingestOutput.Asset.AssetID,
 		SourcePath:         ingestOutput.Asset.FilePath,
-		SilenceThreshold:   0.01, // 1% threshold
-		MinSilenceDuration: 0.1,  // 100ms minimum silence
-	}).Get(ctx, &trimOutput)
+		SilenceThreshold:   silenceThreshold,
+		ThresholdMode:      input.ThresholdMode,
+		MinSilenceDuration: minSilenceDuration,
+		OutputFormat:       input.TrimOutputFormat,
+	}), &trimOutput)
+	if cancel.requested(ctx) {
+		return cancelled()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to trim silence: %w", err)
 	}
 
-	// Step 3: Compute SNR
+	progress.TrimmedAssetID = trimOutput.NewAssetID
+
+	// Features of pure silence are meaningless, so stop here and let the
+	// caller decide from TrimmedOutput.AllSilent whether to discard the asset
+	if trimOutput.AllSilent {
+		workflow.GetLogger(ctx).Warn("Audio is entirely silent, skipping feature extraction",
+			"asset_id", ingestOutput.Asset.AssetID)
+		progress.Stage = StageDone
+		return &AudioProcessingWorkflowOutput{
+			IngestedAsset: ingestOutput.Asset,
+			TrimmedOutput: *trimOutput,
+		}, nil
+	}
+
+	// Step 3: Extract features in parallel
+	progress.Stage = StageExtracting
 	// Use trimmed file path if available, otherwise use original
-	filePathForSNR := ingestOutput.Asset.FilePath
+	filePathForFeatures := ingestOutput.Asset.FilePath
 	if trimOutput.OutputPath != "" {
-		filePathForSNR = trimOutput.OutputPath
+		filePathForFeatures = trimOutput.OutputPath
+	}
+
+	output := &AudioProcessingWorkflowOutput{
+		IngestedAsset: ingestOutput.Asset,
+		TrimmedOutput: *trimOutput,
+	}
+
+	noiseThreshold := input.NoiseThreshold
+	if noiseThreshold == 0 {
+		noiseThreshold = DefaultNoiseThreshold
+	}
+	useSilentSegments := true
+	if input.UseSilentSegments != nil {
+		useSilentSegments = *input.UseSilentSegments
+	}
+
+	tasks := []featureTask{
+		{
+			name: "snr",
+			future: workflow.ExecuteActivity(cancel.ctx, "ComputeSNR", activities.ComputeSNRInput{
+				AssetID:           ingestOutput.Asset.AssetID,
+				FilePath:          filePathForFeatures,
+				NoiseThreshold:    noiseThreshold,
+				UseSilentSegments: useSilentSegments,
+				PerChannel:        input.PerChannelSNR,
+				ForceRecompute:    input.ForceRecompute,
+			}),
+			result: &output.SnrOutput,
+		},
+		{
+			name: "true_peak",
+			future: workflow.ExecuteActivity(cancel.ctx, "ComputeTruePeak", activities.ComputeTruePeakInput{
+				AssetID:  ingestOutput.Asset.AssetID,
+				FilePath: filePathForFeatures,
+			}),
+			result: &output.TruePeak,
+		},
+		{
+			// Read the original: the trimmed file only keeps the metadata chunks
+			// of WAV sources written back as WAV, and its cue points are shifted
+			// by the trim, while the metadata is recorded for the original asset
+			name: "metadata",
+			future: workflow.ExecuteActivity(cancel.ctx, "ReadMetadata", activities.ReadMetadataInput{
+				AssetID:  ingestOutput.Asset.AssetID,
+				FilePath: ingestOutput.Asset.FilePath,
+			}),
+			result: &output.Metadata,
+		},
 	}
 
-	var snrOutput *activities.ComputeSNROutput
-	err = workflow.ExecuteActivity(ctx, "ComputeSNR", activities.ComputeSNRInput{
-		AssetID:           ingestOutput.Asset.AssetID,
-		FilePath:          filePathForSNR,
-		NoiseThreshold:    0.01,
-		UseSilentSegments: true,
-	}).Get(ctx, &snrOutput)
+	output.FeatureErrors, err = awaitFeatures(ctx, cancel, tasks, input.Strict)
+	if cancel.requested(ctx) {
+		return cancelled()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to compute SNR: %w", err)
+		return nil, err
 	}
 
-	return &AudioProcessingWorkflowOutput{
-		IngestedAsset: ingestOutput.Asset,
-		TrimmedOutput: *trimOutput,
-		SnrOutput:     *snrOutput,
-	}, nil
+	progress.Stage = StageDone
+	return output, nil
 }
 
-// RegisterWorkflows registers all workflows with the given Temporal worker
-func RegisterWorkflows(w worker.Worker) {
+// RegisterWorkflows registers all workflows with the given Temporal worker or
+// workflow replayer
+func RegisterWorkflows(w worker.WorkflowRegistry) {
 	w.RegisterWorkflow(AudioProcessingWorkflow)
+	w.RegisterWorkflow(FeatureExtractionWorkflow)
+	w.RegisterWorkflow(MasteringWorkflow)
 }
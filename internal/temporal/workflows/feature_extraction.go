@@ -0,0 +1,115 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
+)
+
+// FeatureExtractionWorkflowInput is the input for the FeatureExtractionWorkflow
+type FeatureExtractionWorkflowInput struct {
+	AssetID  string   `json:"asset_id"`  // asset the features are stored against (optional)
+	FilePath string   `json:"file_path"` // path to the audio file
+	Features []string `json:"features"`  // feature types to compute, see activities.FeatureNames
+	Strict   bool     `json:"strict"`    // if true, any feature failure fails the workflow
+
+	ActivityOptions *ActivityOptions `json:"activity_options,omitempty"` // activity timeout and retries, defaults when nil
+}
+
+// FeatureExtractionWorkflowOutput is the output from the FeatureExtractionWorkflow
+type FeatureExtractionWorkflowOutput struct {
+	Results map[string]json.RawMessage `json:"results"`          // feature type -> activity output
+	Errors  map[string]string          `json:"errors,omitempty"` // feature type -> error, only set when not strict
+}
+
+// featureTask tracks a feature extraction activity started by a workflow
+type featureTask struct {
+	name   string
+	future workflow.Future
+	result interface{}
+}
+
+// FeatureExtractionWorkflow runs only the requested feature extraction
+// activities concurrently on a file and returns each result by feature type.
+// Unknown feature types are rejected before any activity is started.
+func FeatureExtractionWorkflow(ctx workflow.Context, input FeatureExtractionWorkflowInput) (*FeatureExtractionWorkflowOutput, error) {
+	if len(input.Features) == 0 {
+		return nil, fmt.Errorf("no features requested")
+	}
+	var unknown []string
+	for _, feature := range input.Features {
+		if _, ok := activities.LookupFeature(feature); !ok {
+			unknown = append(unknown, feature)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown feature types %s: supported types are %s",
+			strings.Join(unknown, ", "), strings.Join(activities.FeatureNames(), ", "))
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, input.ActivityOptions.workflowOptions())
+
+	output := &FeatureExtractionWorkflowOutput{
+		Results: make(map[string]json.RawMessage),
+	}
+
+	var tasks []featureTask
+	results := make(map[string]*json.RawMessage)
+	for _, feature := range input.Features {
+		if _, started := results[feature]; started {
+			continue
+		}
+
+		f, _ := activities.LookupFeature(feature)
+		req := activities.FeatureRequest{AssetID: input.AssetID, FilePath: input.FilePath}
+		result := new(json.RawMessage)
+		results[feature] = result
+		tasks = append(tasks, featureTask{
+			name:   feature,
+			future: workflow.ExecuteActivity(ctx, f.Activity, f.Input(req)),
+			result: result,
+		})
+	}
+
+	errs, err := awaitFeatures(ctx, nil, tasks, input.Strict)
+	if err != nil {
+		return nil, err
+	}
+	output.Errors = errs
+	for feature, result := range results {
+		if _, failed := errs[feature]; !failed {
+			output.Results[feature] = *result
+		}
+	}
+
+	return output, nil
+}
+
+// awaitFeatures waits for every feature task, even after a failure, so the
+// others can finish. In strict mode the first failure is returned; otherwise
+// failures are logged and returned as feature name -> error. Once cancel (which
+// may be nil) is signalled, the remaining tasks are waited for and their errors
+// are ignored.
+func awaitFeatures(ctx workflow.Context, cancel *cancelSignal, tasks []featureTask, strict bool) (map[string]string, error) {
+	var featureErrors map[string]string
+	for _, task := range tasks {
+		if err := cancel.await(ctx, task.future, task.result); err != nil {
+			if cancel != nil && cancel.cancelled {
+				continue
+			}
+			if strict {
+				return nil, fmt.Errorf("failed to compute %s: %w", task.name, err)
+			}
+			workflow.GetLogger(ctx).Error("Feature extraction failed", "feature", task.name, "error", err)
+			if featureErrors == nil {
+				featureErrors = make(map[string]string)
+			}
+			featureErrors[task.name] = err.Error()
+		}
+	}
+	return featureErrors, nil
+}
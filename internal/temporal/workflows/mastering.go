@@ -0,0 +1,181 @@
+package workflows
+
+import (
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/pphelan007/davidAI/internal/temporal/activities"
+)
+
+// DefaultTargetSampleRate is the delivery sample rate MasteringWorkflow
+// resamples to when the input leaves it unset
+const DefaultTargetSampleRate = 48000
+
+// MasteringWorkflowInput is the input for the MasteringWorkflow
+type MasteringWorkflowInput struct {
+	FilePath           string   `json:"file_path"`
+	AssetID            string   `json:"asset_id,omitempty"`             // asset of FilePath; if empty, the file is ingested first
+	DatasetID          string   `json:"dataset_id,omitempty"`           // dataset to ingest into when AssetID is empty
+	SilenceThreshold   float64  `json:"silence_threshold,omitempty"`    // trim threshold (0.0-1.0), defaults to DefaultSilenceThreshold
+	ThresholdMode      string   `json:"threshold_mode,omitempty"`       // trim threshold mode, see activities.TrimSilenceInput; defaults to absolute
+	MinSilenceDuration float64  `json:"min_silence_duration,omitempty"` // trim minimum silence in seconds, defaults to DefaultMinSilenceDuration
+	TargetPeakDB       *float64 `json:"target_peak_db,omitempty"`       // normalized peak in dBFS, defaults to activities.DefaultTargetPeakDB
+	TargetSampleRate   int      `json:"target_sample_rate,omitempty"`   // delivery sample rate in Hz, defaults to DefaultTargetSampleRate
+	OutputDir          string   `json:"output_dir,omitempty"`           // directory for every step's output, defaults to the source directory
+
+	ActivityOptions *ActivityOptions `json:"activity_options,omitempty"` // activity timeout and retries, defaults when nil
+}
+
+// MasteringWorkflowOutput is the output from the MasteringWorkflow
+type MasteringWorkflowOutput struct {
+	SourceAssetID   string                          `json:"source_asset_id"`
+	TrimOutput      activities.TrimSilenceOutput    `json:"trim_output"`
+	NormalizeOutput activities.NormalizeAudioOutput `json:"normalize_output"`
+	ResampleOutput  activities.ResampleOutput       `json:"resample_output"`
+	FinalAssetID    string                          `json:"final_asset_id"` // asset of the delivery file
+	FinalPath       string                          `json:"final_path"`     // path of the delivery file
+	Lineage         []string                        `json:"lineage"`        // asset IDs from the source to the final asset
+}
+
+// MasteringWorkflow prepares a file for delivery by trimming silence,
+// normalizing the peak level, and resampling to the delivery rate, each step
+// reading the previous step's output. Every file written is registered as a
+// child asset of the one it was made from, so the final asset traces back to
+// the source through parent_asset_id. A step with nothing to do (no silence to
+// trim, or already at the target rate) writes nothing, and the next step reads
+// the previous file instead. After each step the written file's header is
+// checked against the format that step should produce, so a step that changes
+// the sample rate or channel count by mistake fails the workflow instead of
+// passing on corrupted audio.
+func MasteringWorkflow(ctx workflow.Context, input MasteringWorkflowInput) (*MasteringWorkflowOutput, error) {
+	ctx = workflow.WithActivityOptions(ctx, input.ActivityOptions.workflowOptions())
+
+	assetID, filePath := input.AssetID, input.FilePath
+	if assetID == "" {
+		var ingestOutput *activities.IngestRawAudioOutput
+		err := workflow.ExecuteActivity(ctx, "IngestRawAudio", activities.IngestRawAudioInput{
+			FilePath:  input.FilePath,
+			DatasetID: input.DatasetID,
+		}).Get(ctx, &ingestOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ingest raw audio: %w", err)
+		}
+		assetID, filePath = ingestOutput.Asset.AssetID, ingestOutput.Asset.FilePath
+	}
+
+	// Versioned so workflows started before the format checks existed still replay
+	checkFormats := workflow.GetVersion(ctx, "check-format", workflow.DefaultVersion, 1) == 1
+	var source activities.ValidateAudioOutput
+	if checkFormats {
+		err := workflow.ExecuteActivity(ctx, "ValidateAudio", activities.ValidateAudioInput{
+			FilePath: filePath,
+		}).Get(ctx, &source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate audio: %w", err)
+		}
+	}
+	// expectFormat fails unless the file step wrote has the given format
+	expectFormat := func(step string, sampleRate, channels int) error {
+		if !checkFormats {
+			return nil
+		}
+		return checkFormat(ctx, step, filePath, sampleRate, channels)
+	}
+
+	output := &MasteringWorkflowOutput{
+		SourceAssetID: assetID,
+		Lineage:       []string{assetID},
+	}
+	// advance makes a step's output the input of the next step
+	advance := func(newAssetID, outputPath string) {
+		assetID, filePath = newAssetID, outputPath
+		output.Lineage = append(output.Lineage, newAssetID)
+	}
+
+	// Step 1: Trim silence
+	silenceThreshold := input.SilenceThreshold
+	if silenceThreshold == 0 {
+		silenceThreshold = DefaultSilenceThreshold
+	}
+	minSilenceDuration := input.MinSilenceDuration
+	if minSilenceDuration == 0 {
+		minSilenceDuration = DefaultMinSilenceDuration
+	}
+	err := workflow.ExecuteActivity(ctx, "TrimSilence", activities.TrimSilenceInput{
+		AssetID:            assetID,
+		SourcePath:         filePath,
+		SilenceThreshold:   silenceThreshold,
+		ThresholdMode:      input.ThresholdMode,
+		MinSilenceDuration: minSilenceDuration,
+		OutputDir:          input.OutputDir,
+	}).Get(ctx, &output.TrimOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trim silence: %w", err)
+	}
+	if output.TrimOutput.AllSilent {
+		return nil, fmt.Errorf("%s is entirely silent, there is nothing to master", input.FilePath)
+	}
+	if output.TrimOutput.NewAssetID != "" {
+		advance(output.TrimOutput.NewAssetID, output.TrimOutput.OutputPath)
+		if err = expectFormat("TrimSilence", source.SampleRate, source.Channels); err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 2: Normalize the peak level
+	err = workflow.ExecuteActivity(ctx, "NormalizeAudio", activities.NormalizeAudioInput{
+		AssetID:      assetID,
+		SourcePath:   filePath,
+		TargetPeakDB: input.TargetPeakDB,
+		OutputDir:    input.OutputDir,
+	}).Get(ctx, &output.NormalizeOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize audio: %w", err)
+	}
+	advance(output.NormalizeOutput.NewAssetID, output.NormalizeOutput.OutputPath)
+	if err = expectFormat("NormalizeAudio", source.SampleRate, source.Channels); err != nil {
+		return nil, err
+	}
+
+	// Step 3: Resample to the delivery rate
+	targetSampleRate := input.TargetSampleRate
+	if targetSampleRate == 0 {
+		targetSampleRate = DefaultTargetSampleRate
+	}
+	err = workflow.ExecuteActivity(ctx, "Resample", activities.ResampleInput{
+		AssetID:          assetID,
+		SourcePath:       filePath,
+		TargetSampleRate: targetSampleRate,
+		OutputDir:        input.OutputDir,
+	}).Get(ctx, &output.ResampleOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resample audio: %w", err)
+	}
+	if !output.ResampleOutput.NoOp {
+		advance(output.ResampleOutput.NewAssetID, output.ResampleOutput.OutputPath)
+		if err = expectFormat("Resample", targetSampleRate, source.Channels); err != nil {
+			return nil, err
+		}
+	}
+
+	output.FinalAssetID, output.FinalPath = assetID, filePath
+	return output, nil
+}
+
+// checkFormat runs ValidateAudio on the file a step wrote and fails unless it
+// has the given sample rate and channel count
+func checkFormat(ctx workflow.Context, step, filePath string, sampleRate, channels int) error {
+	err := workflow.ExecuteActivity(ctx, "ValidateAudio", activities.ValidateAudioInput{
+		FilePath:           filePath,
+		ExpectedSampleRate: sampleRate,
+		ExpectedChannels:   channels,
+	}).Get(ctx, nil)
+	if activities.IsFormatMismatch(err) {
+		return fmt.Errorf("%s wrote a file in the wrong format: %w", step, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check the output of %s: %w", step, err)
+	}
+	return nil
+}
@@ -4,13 +4,17 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ExecutionContext manages context and waitgroup for concurrent operations
@@ -40,24 +44,28 @@ func (ec *ExecutionContext) Cancel() {
 }
 
 // StartRoutineWithError starts a goroutine that can return an error
-// The error is sent to the provided error channel
+// The error is sent to the provided error channel. A panic in fn is recovered
+// and reported the same way, so it triggers the same shutdown path.
 func (ec *ExecutionContext) StartRoutineWithError(name string, fn func(context.Context) error, errChan chan<- error) {
 	ec.wg.Add(1)
 	go func() {
 		defer ec.wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				if errChan != nil {
-					errChan <- fmt.Errorf("panic in routine %s: %v", name, r)
-				}
-			}
-		}()
-		if err := fn(ec.ctx); err != nil {
-			if errChan != nil {
-				errChan <- fmt.Errorf("error in routine %s: %w", name, err)
-			}
+		if err := runRecovered(ec.ctx, name, fn); err != nil && errChan != nil {
+			errChan <- fmt.Errorf("error in routine %s: %w", name, err)
+		}
+	}()
+}
+
+// runRecovered calls fn and converts a panic into an error. Defers inside fn
+// still run while the panic unwinds, before the error is returned.
+func runRecovered(ctx context.Context, name string, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("routine", name).Str("stack", string(debug.Stack())).Msg("Routine panicked")
+			err = fmt.Errorf("panic: %v", r)
 		}
 	}()
+	return fn(ctx)
 }
 
 // Closeable represents a resource that can be closed/cleaned up
@@ -89,6 +97,7 @@ func StartRoutines(routines []Routine) (*sync.WaitGroup, []Closeable, error) {
 		closeables = append(closeables, routine)
 
 		ec.StartRoutineWithError(routine.Name(), func(ctx context.Context) error {
+			// Deferred so Done runs exactly once even if Start panics
 			defer mainWg.Done()
 			return routine.Start(ctx)
 		}, errChan)
@@ -98,7 +107,7 @@ func StartRoutines(routines []Routine) (*sync.WaitGroup, []Closeable, error) {
 	go func() {
 		for err := range errChan {
 			if err != nil {
-				log.Printf("Routine error: %v", err)
+				log.Error().Err(err).Msg("Routine error")
 				ec.Cancel()
 			}
 		}
@@ -107,24 +116,36 @@ func StartRoutines(routines []Routine) (*sync.WaitGroup, []Closeable, error) {
 	// Start signal handler to cancel context on interrupt
 	sigCtx, sigCancel := SetupInterruptHandler()
 	go func() {
-		<-sigCtx.Done()
-		log.Println("Interrupt signal received, initiating shutdown...")
-		ec.Cancel()
+		select {
+		case <-sigCtx.Done():
+			log.Info().Msg("Interrupt signal received, initiating shutdown...")
+			ec.Cancel()
+		case <-ec.ctx.Done():
+		}
 		sigCancel()
 	}()
 
+	// Once every routine has returned (and sent any error), close errChan and
+	// cancel the context so the monitor and signal goroutines exit
+	go func() {
+		ec.wg.Wait()
+		close(errChan)
+		ec.Cancel()
+	}()
+
 	return mainWg, closeables, nil
 }
 
 // WorkerRoutine implements Routine for a worker that needs to be started and stopped
 type WorkerRoutine struct {
 	name  string
-	start func() // Start function (doesn't return error)
+	start func() error // starts the worker without blocking
 	stop  func() error
 }
 
-// NewWorkerRoutine creates a new worker routine
-func NewWorkerRoutine(name string, start func(), stop func() error) *WorkerRoutine {
+// NewWorkerRoutine creates a new worker routine. start must return once the
+// worker is running; an error from it fails the routine.
+func NewWorkerRoutine(name string, start func() error, stop func() error) *WorkerRoutine {
 	return &WorkerRoutine{
 		name:  name,
 		start: start,
@@ -139,12 +160,14 @@ func (r *WorkerRoutine) Name() string {
 
 // Start starts the worker
 func (r *WorkerRoutine) Start(ctx context.Context) error {
-	log.Printf("Starting %s", r.name)
-	r.start()
+	log.Info().Str("routine", r.name).Msg("Starting routine")
+	if err := r.start(); err != nil {
+		return err
+	}
 
 	// Wait for context cancellation
 	<-ctx.Done()
-	log.Printf("Stopping %s...", r.name)
+	log.Info().Str("routine", r.name).Msg("Stopping routine...")
 	return nil
 }
 
@@ -158,6 +181,106 @@ func (r *WorkerRoutine) Close() error {
 	return nil
 }
 
+// RestartPolicy controls how a SupervisedRoutine restarts after a failure
+type RestartPolicy struct {
+	MaxRestarts    int           // restarts allowed before the failure is returned, 0 disables restarts
+	InitialBackoff time.Duration // delay before the first restart
+	MaxBackoff     time.Duration // cap for the delay, which doubles after each restart, 0 or less for no cap
+}
+
+// SupervisedRoutine wraps a Routine and restarts it with backoff when Start
+// returns an error or panics. Once MaxRestarts is exhausted the error is
+// returned, which shuts down the process as for any other routine.
+type SupervisedRoutine struct {
+	Routine
+	policy RestartPolicy
+}
+
+// NewSupervisedRoutine wraps a routine with a restart policy
+func NewSupervisedRoutine(routine Routine, policy RestartPolicy) *SupervisedRoutine {
+	return &SupervisedRoutine{
+		Routine: routine,
+		policy:  policy,
+	}
+}
+
+// Start runs the wrapped routine, restarting it on failure until the policy is exhausted
+func (r *SupervisedRoutine) Start(ctx context.Context) error {
+	backoff := r.policy.InitialBackoff
+	for restarts := 0; ; restarts++ {
+		err := runRecovered(ctx, r.Name(), r.Routine.Start)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if restarts >= r.policy.MaxRestarts {
+			return fmt.Errorf("giving up after %d restarts: %w", restarts, err)
+		}
+
+		log.Warn().Err(err).Str("routine", r.Name()).Int("restart", restarts+1).
+			Int("max_restarts", r.policy.MaxRestarts).Dur("backoff", backoff).Msg("Restarting routine")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		backoff *= 2
+		if r.policy.MaxBackoff > 0 {
+			backoff = min(backoff, r.policy.MaxBackoff)
+		}
+	}
+}
+
+// httpShutdownTimeout bounds how long an HTTP server waits for in-flight requests on Close
+const httpShutdownTimeout = 5 * time.Second
+
+// HTTPServerRoutine implements Routine for an HTTP server
+type HTTPServerRoutine struct {
+	name   string
+	server *http.Server
+}
+
+// NewHTTPServerRoutine creates a routine that serves the given HTTP server
+func NewHTTPServerRoutine(name string, server *http.Server) *HTTPServerRoutine {
+	return &HTTPServerRoutine{
+		name:   name,
+		server: server,
+	}
+}
+
+// Name returns the routine name
+func (r *HTTPServerRoutine) Name() string {
+	return r.name
+}
+
+// Start serves HTTP and blocks until the server fails or the context is cancelled
+func (r *HTTPServerRoutine) Start(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		log.Info().Str("routine", r.name).Str("addr", r.server.Addr).Msg("HTTP server listening")
+		if err := r.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// Close shuts down the server, waiting briefly for in-flight requests
+func (r *HTTPServerRoutine) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := r.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown %s: %w", r.name, err)
+	}
+	return nil
+}
+
 // SetupInterruptHandler sets up signal handlers for graceful shutdown
 // Returns a context that will be cancelled when an interrupt signal is received
 func SetupInterruptHandler() (context.Context, context.CancelFunc) {
@@ -167,11 +290,15 @@ func SetupInterruptHandler() (context.Context, context.CancelFunc) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start a goroutine to handle signals
+	// Start a goroutine to handle signals, stopping when the context is cancelled
 	go func() {
-		sig := <-sigChan
-		log.Printf("Received signal: %v, initiating graceful shutdown...", sig)
-		cancel()
+		defer signal.Stop(sigChan)
+		select {
+		case sig := <-sigChan:
+			log.Info().Str("signal", sig.String()).Msg("Received signal, initiating graceful shutdown...")
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
 	return ctx, cancel
@@ -185,7 +312,7 @@ func DetectAndKillHang(ctx context.Context, timeout time.Duration, operationName
 	go func() {
 		<-hangCtx.Done()
 		if hangCtx.Err() == context.DeadlineExceeded {
-			log.Printf("⚠️  HANG DETECTED: %s exceeded timeout of %v, cancelling...", operationName, timeout)
+			log.Warn().Str("operation", operationName).Dur("timeout", timeout).Msg("HANG DETECTED: operation exceeded timeout, cancelling...")
 		}
 	}()
 
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// testRoutine is a Routine running start
+type testRoutine struct {
+	name  string
+	start func(ctx context.Context) error
+}
+
+func (r *testRoutine) Name() string                    { return r.name }
+func (r *testRoutine) Start(ctx context.Context) error { return r.start(ctx) }
+func (r *testRoutine) Close() error                    { return nil }
+
+// waitTimeout calls wait, failing the test if it takes more than a second
+func waitTimeout(t *testing.T, wait func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("routines did not stop")
+	}
+}
+
+func TestStartRoutinesNoLeak(t *testing.T) {
+	tests := []struct {
+		name     string
+		routines []Routine
+	}{
+		{
+			name: "all routines return",
+			routines: []Routine{
+				&testRoutine{name: "a", start: func(context.Context) error { return nil }},
+				&testRoutine{name: "b", start: func(context.Context) error { return nil }},
+			},
+		},
+		{
+			name: "a routine fails",
+			routines: []Routine{
+				&testRoutine{name: "failing", start: func(context.Context) error { return errors.New("failed") }},
+				NewWorkerRoutine("worker", func() error { return nil }, nil),
+			},
+		},
+		{
+			name: "a routine panics",
+			routines: []Routine{
+				&testRoutine{name: "panicking", start: func(context.Context) error { panic("boom") }},
+				NewWorkerRoutine("worker", func() error { return nil }, nil),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// signal.Notify starts the runtime's signal loop, which lives as
+			// long as the process
+			defer goleak.VerifyNone(t, goleak.IgnoreTopFunction("os/signal.signal_recv"))
+
+			wg, closeables, err := StartRoutines(tt.routines)
+			require.NoError(t, err)
+			waitTimeout(t, wg.Wait)
+			for _, closeable := range closeables {
+				require.NoError(t, closeable.Close())
+			}
+		})
+	}
+}
+
+func TestStartRoutineWithErrorRecoversPanic(t *testing.T) {
+	ec := NewExecutionContext()
+	defer ec.Cancel()
+	errChan := make(chan error, 1)
+	deferred := false
+	ec.StartRoutineWithError("panicking", func(context.Context) error {
+		defer func() { deferred = true }()
+		panic("boom")
+	}, errChan)
+
+	waitTimeout(t, ec.wg.Wait)
+	err := <-errChan
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error in routine panicking: panic: boom")
+	assert.True(t, deferred, "defers in the routine run before the error is reported")
+}
+
+func TestWorkerRoutineStartError(t *testing.T) {
+	stopped := false
+	routine := NewWorkerRoutine("worker", func() error { return errors.New("failed to start") }, func() error {
+		stopped = true
+		return nil
+	})
+
+	// The routine fails without waiting for shutdown, and can still be closed
+	waitTimeout(t, func() {
+		assert.EqualError(t, routine.Start(context.Background()), "failed to start")
+	})
+	require.NoError(t, routine.Close())
+	assert.True(t, stopped)
+}
+
+func TestSupervisedRoutineRecoversPanic(t *testing.T) {
+	starts := 0
+	routine := NewSupervisedRoutine(&testRoutine{name: "panicking", start: func(context.Context) error {
+		starts++
+		panic("boom")
+	}}, RestartPolicy{MaxRestarts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	err := routine.Start(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "giving up after 2 restarts: panic: boom", err.Error())
+	assert.Equal(t, 3, starts)
+}
+
+func TestSupervisedRoutineRestartsAfterPanic(t *testing.T) {
+	starts := 0
+	routine := NewSupervisedRoutine(&testRoutine{name: "flaky", start: func(context.Context) error {
+		starts++
+		if starts == 1 {
+			panic("boom")
+		}
+		return nil
+	}}, RestartPolicy{MaxRestarts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	require.NoError(t, routine.Start(context.Background()))
+	assert.Equal(t, 2, starts)
+}
+
+func TestSupervisedRoutineUncappedBackoff(t *testing.T) {
+	// Without a cap the delay keeps doubling rather than dropping to zero
+	// after the first restart: 10ms, 20ms, then 40ms
+	starts := 0
+	routine := NewSupervisedRoutine(&testRoutine{name: "failing", start: func(context.Context) error {
+		starts++
+		return errors.New("boom")
+	}}, RestartPolicy{MaxRestarts: 3, InitialBackoff: 10 * time.Millisecond})
+
+	began := time.Now()
+	require.Error(t, routine.Start(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(began), 70*time.Millisecond)
+	assert.Equal(t, 4, starts)
+}